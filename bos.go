@@ -1,12 +1,22 @@
 package CloudStore
 
 import (
-	"fmt"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
+	"os"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/baidubce/bce-sdk-go/bce"
 	"github.com/baidubce/bce-sdk-go/services/bos"
 	"github.com/baidubce/bce-sdk-go/services/bos/api"
 )
@@ -37,47 +47,370 @@ func NewBOS(accessKey, secretKey, bucket, endpoint, domain string) (b *BOS, err
 }
 
 func (b *BOS) IsExist(object string) (err error) {
-	_, err = b.GetInfo(object)
+	return b.IsExistContext(context.Background(), object)
+}
+
+// 百度云 SDK 不支持 context，以下 XXXContext 方法在 ctx 结束时提前返回
+func (b *BOS) IsExistContext(ctx context.Context, object string) (err error) {
+	defer func() { err = bosError("IsExist", object, err) }()
+	_, err = b.GetInfoContext(ctx, object)
 	return
 }
 
 func (b *BOS) Upload(tmpFile, saveFile string, headers ...map[string]string) (err error) {
-	var args = &api.PutObjectArgs{
-		UserMeta: make(map[string]string),
-	}
-	for _, header := range headers {
-		for k, v := range header {
-			switch strings.ToLower(k) {
-			case "content-disposition":
-				args.ContentDisposition = v
-			case "content-type":
-				args.ContentType = v
-			//case "content-encoding":
-			//	args.ContentEncoding = v
-			default:
-				args.UserMeta[k] = v
+	return b.UploadContext(context.Background(), tmpFile, saveFile, headers...)
+}
+
+func (b *BOS) UploadContext(ctx context.Context, tmpFile, saveFile string, headers ...map[string]string) (err error) {
+	defer func() { err = bosError("Upload", saveFile, err) }()
+	if useMultipart(tmpFile) {
+		return b.UploadMultipartContext(ctx, tmpFile, saveFile, MultipartOptions{}, headers...)
+	}
+	var (
+		reader *os.File
+		info   os.FileInfo
+	)
+	reader, err = os.Open(tmpFile)
+	if err != nil {
+		return
+	}
+	defer reader.Close()
+	info, err = reader.Stat()
+	if err != nil {
+		return
+	}
+	return b.PutContext(ctx, saveFile, reader, info.Size(), headers...)
+}
+
+func (b *BOS) Put(object string, reader io.Reader, size int64, headers ...map[string]string) (err error) {
+	return b.PutContext(context.Background(), object, reader, size, headers...)
+}
+
+// 百度云的请求必须带上整个 body 的 Content-MD5 和 Content-Length，
+// reader 为普通文件时直接从文件中计算，其他 reader 先写入临时文件，size 小于 0 时读取到 EOF
+func (b *BOS) PutContext(ctx context.Context, object string, reader io.Reader, size int64, headers ...map[string]string) (err error) {
+	h := parseHeaders(headers...)
+	defer func() { err = uploadConditionError(bosError("Put", object, err), h) }()
+	var (
+		body    *bce.Body
+		cleanup func()
+	)
+	if body, cleanup, err = bosBody(ctx, reader, size); err != nil {
+		return
+	}
+	defer cleanup()
+	header := bosDialect.native(h)
+	// 条件上传的请求头由 BOS 检查
+	for k, v := range bosConditions(Conditions{IfMatch: h.IfMatch, IfNoneMatch: h.IfNoneMatch}) {
+		header[k] = v
+	}
+	return doContextReader(ctx, body.Stream(), func(reader io.Reader) error {
+		body.SetStream(ioutil.NopCloser(reader))
+		return b.send(http.MethodPut, objectRel(object), nil, header, body, nil)
+	})
+}
+
+// bosBody 生成可以计算 Content-MD5 的请求 body，cleanup 用于删除临时文件
+func bosBody(ctx context.Context, reader io.Reader, size int64) (body *bce.Body, cleanup func(), err error) {
+	cleanup = func() {}
+	if fp, ok := reader.(*os.File); ok {
+		if n := readerSize(fp); n >= 0 {
+			if size < 0 {
+				size = n
+			} else if size > n {
+				err = io.ErrUnexpectedEOF
+				return
+			}
+			var offset int64
+			if offset, err = fp.Seek(0, io.SeekCurrent); err != nil {
+				return
 			}
+			body, err = bce.NewBodyFromSectionFile(fp, offset, size)
+			return
 		}
 	}
-	_, err = b.Client.PutObjectFromFile(b.Bucket, objectRel(saveFile), tmpFile, args)
+
+	var tmp *os.File
+	if tmp, err = ioutil.TempFile("", "cloudstore-bos-*"); err != nil {
+		return
+	}
+	cleanup = func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}
+	defer func() {
+		if err != nil {
+			cleanup()
+			cleanup = func() {}
+		}
+	}()
+	if size >= 0 {
+		reader = io.LimitReader(reader, size)
+	}
+	var n int64
+	if n, err = io.Copy(tmp, &contextReader{ctx: ctx, ReadCloser: ioutil.NopCloser(reader)}); err != nil {
+		return
+	}
+	if size >= 0 && n < size {
+		err = io.ErrUnexpectedEOF
+		return
+	}
+	body, err = bce.NewBodyFromSectionFile(tmp, 0, n)
+	return
+}
+
+func (b *BOS) Get(object string) (reader io.ReadCloser, info File, err error) {
+	return b.GetContext(context.Background(), object)
+}
+
+func (b *BOS) GetContext(ctx context.Context, object string) (reader io.ReadCloser, info File, err error) {
+	defer func() { err = bosError("Get", object, err) }()
+	return b.get(ctx, object, Conditions{})
+}
+
+func (b *BOS) GetIf(object string, cond Conditions) (reader io.ReadCloser, info File, err error) {
+	return b.GetIfContext(context.Background(), object, cond)
+}
+
+func (b *BOS) GetIfContext(ctx context.Context, object string, cond Conditions) (reader io.ReadCloser, info File, err error) {
+	defer func() { err = bosError("GetIf", object, err) }()
+	return b.get(ctx, object, cond)
+}
+
+// SDK 下载时不能设置条件请求头，这里直接构造请求，条件由 BOS 检查
+func (b *BOS) get(ctx context.Context, object string, cond Conditions) (reader io.ReadCloser, info File, err error) {
+	var resp *bce.BceResponse
+	err = doContext(ctx, func() (e error) {
+		resp, e = b.request(http.MethodGet, objectRel(object), nil, bosConditions(cond), nil)
+		if e == nil && ctx.Err() != nil {
+			resp.Body().Close()
+		}
+		return
+	})
+	if err != nil {
+		return
+	}
+	info = bosFile(object, resp.Headers())
+	reader = &contextReader{ctx: ctx, ReadCloser: resp.Body()}
+	return
+}
+
+// 条件请求头
+func bosConditions(cond Conditions) map[string]string {
+	header := make(map[string]string)
+	for k, v := range cond.header() {
+		header[k] = v[0]
+	}
+	return header
+}
+
+// SDK 的上传参数中没有 Content-Encoding、Content-Language、访问权限以及条件请求头，上传、初始化以及完成分片上传时直接构造请求
+func (b *BOS) send(method, object string, params, header map[string]string, body *bce.Body, result interface{}) (err error) {
+	var resp *bce.BceResponse
+	if resp, err = b.request(method, object, params, header, body); err != nil {
+		return
+	}
+	defer resp.Body().Close()
+	if result != nil {
+		err = resp.ParseJsonBody(result)
+	}
 	return
 }
 
+// 直接构造请求，成功时由调用者关闭 resp.Body()；SDK 只把 4xx、5xx 作为错误，304 也作为错误返回
+func (b *BOS) request(method, object string, params, header map[string]string, body *bce.Body) (resp *bce.BceResponse, err error) {
+	req := &bce.BceRequest{}
+	req.SetUri(bce.URI_PREFIX + b.Bucket + "/" + object)
+	req.SetMethod(method)
+	for k, v := range params {
+		req.SetParam(k, v)
+	}
+	if body != nil {
+		req.SetBody(body)
+	}
+	// 在 SetBody 之后设置，使用调用者指定的 Content-MD5
+	for k, v := range header {
+		req.SetHeader(k, v)
+	}
+	resp = &bce.BceResponse{}
+	if err = api.SendRequest(b.Client, req, resp); err != nil {
+		return nil, err
+	}
+	if resp.IsFail() {
+		return nil, resp.ServiceError()
+	}
+	if resp.StatusCode() == http.StatusNotModified {
+		resp.Body().Close()
+		return nil, bce.NewBceServiceError("NotModified", resp.StatusText(), resp.RequestId(), resp.StatusCode())
+	}
+	return
+}
+
+func (b *BOS) UploadMultipart(tmpFile, saveFile string, opts MultipartOptions, headers ...map[string]string) (err error) {
+	return b.UploadMultipartContext(context.Background(), tmpFile, saveFile, opts, headers...)
+}
+
+func (b *BOS) UploadMultipartContext(ctx context.Context, tmpFile, saveFile string, opts MultipartOptions, headers ...map[string]string) (err error) {
+	h := parseHeaders(headers...)
+	defer func() { err = uploadConditionError(bosError("UploadMultipart", saveFile, err), h) }()
+	return uploadMultipart(ctx, b, tmpFile, saveFile, opts, headers...)
+}
+
+// 百度云的自定义元数据在完成分片上传的时候设置
+func (b *BOS) initMultipart(ctx context.Context, object string, size, partSize int64, header map[string]string) (uploadID string, err error) {
+	h := parseHeaders(header)
+	h.Metadata = nil
+	var res api.InitiateMultipartUploadResult
+	err = doContext(ctx, func() error {
+		return b.send(http.MethodPost, object, map[string]string{"uploads": ""}, bosDialect.native(h), nil, &res)
+	})
+	if err != nil {
+		return
+	}
+	uploadID = res.UploadId
+	return
+}
+
+// 百度云的分片需要带上 Content-MD5，这里先把分片读取到内存中
+func (b *BOS) uploadPart(ctx context.Context, object, uploadID string, number int, reader io.Reader, size int64) (etag string, err error) {
+	var content []byte
+	content, err = ioutil.ReadAll(reader)
+	if err != nil {
+		return
+	}
+	var partETag string
+	err = doContext(ctx, func() (e error) {
+		partETag, e = b.Client.UploadPartFromBytes(b.Bucket, object, uploadID, number, content, nil)
+		return
+	})
+	if err != nil {
+		return
+	}
+	etag = partETag
+	return
+}
+
+// SDK 完成分片上传时不能设置条件请求头，这里直接构造请求，自定义元数据和条件请求头一起发送
+func (b *BOS) completeMultipart(ctx context.Context, object, uploadID string, size int64, parts []uploadedPart, header map[string]string) (err error) {
+	h := parseHeaders(header)
+	args := &api.CompleteMultipartUploadArgs{}
+	for _, part := range parts {
+		args.Parts = append(args.Parts, api.UploadInfoType{PartNumber: part.Number, ETag: part.ETag})
+	}
+	var content []byte
+	if content, err = json.Marshal(args); err != nil {
+		return
+	}
+	var body *bce.Body
+	if body, err = bce.NewBodyFromBytes(content); err != nil {
+		return
+	}
+	nativeHeader := bosDialect.native(objectHeader{Metadata: h.Metadata})
+	for k, v := range bosConditions(Conditions{IfMatch: h.IfMatch, IfNoneMatch: h.IfNoneMatch}) {
+		nativeHeader[k] = v
+	}
+	var res api.CompleteMultipartUploadResult
+	return doContext(ctx, func() error {
+		return b.send(http.MethodPost, object, map[string]string{"uploadId": uploadID}, nativeHeader, body, &res)
+	})
+}
+
+func (b *BOS) listParts(ctx context.Context, object, uploadID string) (parts []uploadedPart, err error) {
+	args := &api.ListPartsArgs{}
+	for {
+		var res *api.ListPartsResult
+		err = doContext(ctx, func() (e error) {
+			res, e = b.Client.ListParts(b.Bucket, object, uploadID, args)
+			return
+		})
+		if err != nil {
+			return
+		}
+		for _, part := range res.Parts {
+			parts = append(parts, uploadedPart{Number: part.PartNumber, ETag: part.ETag})
+		}
+		if !res.IsTruncated {
+			return
+		}
+		args.PartNumberMarker = strconv.Itoa(res.NextPartNumberMarker)
+	}
+}
+
+func (b *BOS) abortMultipart(ctx context.Context, object, uploadID string) (err error) {
+	return doContext(ctx, func() error {
+		return b.Client.AbortMultipartUpload(b.Bucket, object, uploadID)
+	})
+}
+
 func (b *BOS) Delete(objects ...string) (err error) {
+	return b.DeleteContext(context.Background(), objects...)
+}
+
+func (b *BOS) DeleteContext(ctx context.Context, objects ...string) (err error) {
+	defer func() { err = bosError("Delete", strings.Join(objects, ", "), err) }()
 	if len(objects) == 0 {
 		return
 	}
+	// 不修改调用方的 objects，Mirror 会把同一个切片并发地传给各副本
+	keys := make([]string, len(objects))
 	for idx, object := range objects {
-		objects[idx] = objectRel(object)
+		keys[idx] = objectRel(object)
 	}
-	res, _ := b.Client.DeleteMultipleObjectsFromKeyList(b.Bucket, objects)
-	if res != nil && len(res.Errors) > 0 {
-		err = fmt.Errorf("%+v", res)
+	return doContext(ctx, func() (e error) {
+		var res *api.DeleteMultipleObjectsResult
+		res, e = b.Client.DeleteMultipleObjectsFromKeyList(b.Bucket, keys)
+		// 全部删除成功时 BOS 返回空的响应内容，SDK 解析 JSON 时返回 io.EOF
+		if e == io.EOF {
+			return nil
+		}
+		if e != nil || res == nil {
+			return
+		}
+		// 与其他云存储一致，删除不存在的文件不算失败
+		var errs []error
+		for _, r := range res.Errors {
+			if r.Code != "NoSuchKey" {
+				errs = append(errs, newStoreError("bos", "Delete", r.Key, 0, r.Code, "", errors.New(r.Code+": "+r.Message)))
+			}
+		}
+		return deleteError(errs)
+	})
+}
+
+func (b *BOS) Copy(src, dst string) (err error) {
+	return b.CopyContext(context.Background(), src, dst)
+}
+
+func (b *BOS) CopyContext(ctx context.Context, src, dst string) (err error) {
+	defer func() { err = bosError("Copy", src, err) }()
+	return doContext(ctx, func() (e error) {
+		_, e = b.Client.BasicCopyObject(b.Bucket, objectRel(dst), b.Bucket, objectRel(src))
+		return
+	})
+}
+
+func (b *BOS) Move(src, dst string) (err error) {
+	return b.MoveContext(context.Background(), src, dst)
+}
+
+// BOS 没有移动文件的接口，先复制再删除源文件
+func (b *BOS) MoveContext(ctx context.Context, src, dst string) (err error) {
+	defer func() { err = bosError("Move", src, err) }()
+	if err = b.CopyContext(ctx, src, dst); err != nil {
+		return
 	}
-	return
+	return b.DeleteContext(ctx, src)
 }
 
 func (b *BOS) GetSignURL(object string, expire int64) (link string, err error) {
+	return b.GetSignURLContext(context.Background(), object, expire)
+}
+
+func (b *BOS) GetSignURLContext(ctx context.Context, object string, expire int64) (link string, err error) {
+	defer func() { err = bosError("GetSignURL", object, err) }()
+	if err = ctx.Err(); err != nil {
+		return
+	}
 	if expire <= 0 {
 		link = b.Domain + objectAbs(object)
 	} else {
@@ -91,46 +424,243 @@ func (b *BOS) GetSignURL(object string, expire int64) (link string, err error) {
 	return
 }
 
-func (b *BOS) Download(object string, savePath string) (err error) {
-	err = b.Client.DownloadSuperFile(b.Bucket, objectRel(object), savePath)
+func (b *BOS) GetSignUploadURL(object string, expire int64, headers ...map[string]string) (upload SignedUpload, err error) {
+	return b.GetSignUploadURLContext(context.Background(), object, expire, headers...)
+}
+
+// 上传签名不替换为绑定的域名，CDN 域名一般不支持 PUT
+func (b *BOS) GetSignUploadURLContext(ctx context.Context, object string, expire int64, headers ...map[string]string) (upload SignedUpload, err error) {
+	defer func() { err = bosError("GetSignUploadURL", object, err) }()
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	if expire <= 0 {
+		expire = oneHour
+	}
+	upload = SignedUpload{
+		Method: http.MethodPut,
+		Header: bosDialect.native(parseHeaders(headers...)),
+	}
+	upload.URL = b.Client.GeneratePresignedUrl(b.Bucket, objectRel(object), int(expire), http.MethodPut, upload.Header, nil)
+	return
+}
+
+func (b *BOS) PostPolicy(keyPrefix string, maxSize int64, contentTypes []string, expire int64) (form PostForm, err error) {
+	return b.PostPolicyContext(context.Background(), keyPrefix, maxSize, contentTypes, expire)
+}
+
+// https://cloud.baidu.com/doc/BOS/s/Ekc4epuzv
+func (b *BOS) PostPolicyContext(ctx context.Context, keyPrefix string, maxSize int64, contentTypes []string, expire int64) (form PostForm, err error) {
+	defer func() { err = bosError("PostPolicy", keyPrefix, err) }()
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	if expire <= 0 {
+		expire = oneHour
+	}
+	var p *postPolicy
+	if p, err = newPostPolicy(keyPrefix, maxSize, contentTypes, expire, false); err != nil {
+		return
+	}
+	p.add(map[string]string{"bucket": b.Bucket})
+	policy := p.base64()
+	mac := hmac.New(sha256.New, []byte(b.SecretKey))
+	mac.Write([]byte(policy))
+
+	form = PostForm{
+		URL:    "https://" + b.Bucket + "." + b.Endpoint,
+		Fields: postFields(keyPrefix, contentTypes),
+	}
+	form.Fields["accessKey"] = b.AccessKey
+	form.Fields["policy"] = policy
+	form.Fields["signature"] = hex.EncodeToString(mac.Sum(nil))
 	return
 }
 
+func (b *BOS) Download(object string, savePath string) (err error) {
+	return b.DownloadContext(context.Background(), object, savePath)
+}
+
+func (b *BOS) DownloadContext(ctx context.Context, object string, savePath string) (err error) {
+	defer func() { err = bosError("Download", object, err) }()
+	// SDK 的 DownloadSuperFile 不支持 context，取消之后仍然会继续写入 savePath，这里使用 Get 的数据流
+	var reader io.ReadCloser
+	reader, _, err = b.GetContext(ctx, object)
+	if err != nil {
+		return
+	}
+	defer reader.Close()
+	return saveTo(reader, savePath)
+}
+
 func (b *BOS) GetInfo(object string) (info File, err error) {
-	var resp *api.GetObjectMetaResult
-	resp, err = b.Client.GetObjectMeta(b.Bucket, objectRel(object))
+	return b.GetInfoContext(context.Background(), object)
+}
+
+func (b *BOS) GetInfoContext(ctx context.Context, object string) (info File, err error) {
+	defer func() { err = bosError("GetInfo", object, err) }()
+	return b.getInfo(ctx, object, Conditions{})
+}
+
+// SDK 获取元数据时不能设置条件请求头，这里直接构造 HEAD 请求，条件由 BOS 检查
+func (b *BOS) getInfo(ctx context.Context, object string, cond Conditions) (info File, err error) {
+	var resp *bce.BceResponse
+	err = doContext(ctx, func() (e error) {
+		resp, e = b.request(http.MethodHead, objectRel(object), nil, bosConditions(cond), nil)
+		if e == nil {
+			resp.Body().Close()
+		}
+		return
+	})
 	if err != nil {
 		return
 	}
+	info = bosFile(object, resp.Headers())
+	return
+}
+
+// 根据响应头构造文件信息，与 SDK 的 GetObjectMeta 解析的字段相同
+func bosFile(object string, headers map[string]string) (info File) {
+	meta := api.ObjectMeta{
+		CacheControl:       headers["Cache-Control"],
+		ContentDisposition: headers["Content-Disposition"],
+		ContentEncoding:    headers["Content-Encoding"],
+		ContentType:        headers["Content-Type"],
+		ContentMD5:         headers["Content-Md5"],
+		Expires:            headers["Expires"],
+		LastModified:       headers["Last-Modified"],
+		ETag:               strings.Trim(headers["Etag"], `"`),
+		StorageClass:       headers["X-Bce-Storage-Class"],
+	}
+	meta.ContentLength, _ = strconv.ParseInt(headers["Content-Length"], 10, 64)
+	for k, v := range headers {
+		if strings.HasPrefix(k, "X-Bce-Meta-") {
+			if meta.UserMeta == nil {
+				meta.UserMeta = make(map[string]string)
+			}
+			meta.UserMeta[strings.TrimPrefix(k, "X-Bce-Meta-")] = v
+		}
+	}
 	info = File{
-		Name:   objectRel(object),
-		Size:   resp.ContentLength,
-		IsDir:  resp.ContentLength == 0,
-		Header: resp.UserMeta,
+		Name:  objectRel(object),
+		Size:  meta.ContentLength,
+		IsDir: isDirKey(object),
+	}
+	bosDialect.setHeader(&info, bosHeader(meta))
+	info.ModTime, _ = time.Parse(http.TimeFormat, meta.LastModified)
+	return
+}
+
+func (b *BOS) GetInfoIf(object string, cond Conditions) (info File, err error) {
+	return b.GetInfoIfContext(context.Background(), object, cond)
+}
+
+func (b *BOS) GetInfoIfContext(ctx context.Context, object string, cond Conditions) (info File, err error) {
+	defer func() { err = bosError("GetInfoIf", object, err) }()
+	return b.getInfo(ctx, object, cond)
+}
+
+// 自定义元数据之外，还需要返回上传时设置的标准请求头
+// SDK 返回的自定义元数据已经去掉了 x-bce-meta- 前缀
+func bosHeader(meta api.ObjectMeta) (header map[string]string) {
+	header = make(map[string]string)
+	for k, v := range meta.UserMeta {
+		header[MetaPrefix+http.CanonicalHeaderKey(k)] = v
+	}
+	for k, v := range map[string]string{
+		HeaderCacheControl:       meta.CacheControl,
+		HeaderContentDisposition: meta.ContentDisposition,
+		HeaderContentEncoding:    meta.ContentEncoding,
+		HeaderContentType:        meta.ContentType,
+		HeaderExpires:            meta.Expires,
+		HeaderContentMD5:         meta.ContentMD5,
+		"ETag":                   meta.ETag,
+		HeaderStorageClass:       bosDialect.canonicalStorageClass(meta.StorageClass),
+	} {
+		if v != "" {
+			header[k] = v
+		}
 	}
-	info.ModTime, _ = time.Parse(http.TimeFormat, resp.LastModified)
 	return
 }
 
 func (b *BOS) Lists(prefix string) (files []File, err error) {
+	return b.ListsContext(context.Background(), prefix)
+}
+
+func (b *BOS) ListsContext(ctx context.Context, prefix string) (files []File, err error) {
+	defer func() { err = bosError("Lists", prefix, err) }()
+	return listAll(ctx, b, prefix)
+}
+
+func (b *BOS) ListPage(prefix, marker string, limit int) (files []File, nextMarker string, err error) {
+	return b.ListPageContext(context.Background(), prefix, marker, limit)
+}
+
+func (b *BOS) ListPageContext(ctx context.Context, prefix, marker string, limit int) (files []File, nextMarker string, err error) {
+	defer func() { err = bosError("ListPage", prefix, err) }()
+	return b.list(ctx, objectRel(prefix), "", marker, limit)
+}
+
+func (b *BOS) ListDir(dir, marker string, limit int) (files []File, nextMarker string, err error) {
+	return b.ListDirContext(context.Background(), dir, marker, limit)
+}
+
+func (b *BOS) ListDirContext(ctx context.Context, dir, marker string, limit int) (files []File, nextMarker string, err error) {
+	defer func() { err = bosError("ListDir", dir, err) }()
+	return b.list(ctx, dirPrefix(dir), delimiter, marker, limit)
+}
+
+func (b *BOS) list(ctx context.Context, prefix, delim, marker string, limit int) (files []File, nextMarker string, err error) {
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
 	var resp *api.ListObjectsResult
 	args := &api.ListObjectsArgs{
-		Prefix:  objectRel(prefix),
-		MaxKeys: 1000,
+		Prefix:    prefix,
+		Delimiter: delim,
+		Marker:    marker,
+		MaxKeys:   limit,
 	}
-	resp, err = b.Client.ListObjects(b.Bucket, args)
+	err = doContext(ctx, func() (e error) {
+		resp, e = b.Client.ListObjects(b.Bucket, args)
+		return
+	})
 	if err != nil {
 		return
 	}
 
+	for _, dir := range resp.CommonPrefixes {
+		files = append(files, dirFile(dir.Prefix))
+	}
 	for _, object := range resp.Contents {
+		if delim != "" && object.Key == prefix {
+			continue
+		}
 		file := File{
-			Size:  int64(object.Size),
-			Name:  objectRel(object.Key),
-			IsDir: object.Size == 0,
+			Size:         int64(object.Size),
+			Name:         objectRel(object.Key),
+			IsDir:        isDirKey(object.Key),
+			ETag:         strings.Trim(object.ETag, `"`),
+			StorageClass: bosDialect.canonicalStorageClass(object.StorageClass),
 		}
-		file.ModTime, _ = time.Parse(http.TimeFormat, object.LastModified)
+		// 列表接口返回的时间为 ISO8601 格式
+		file.ModTime, _ = time.Parse(time.RFC3339, object.LastModified)
 		files = append(files, file)
 	}
+	if resp.IsTruncated {
+		nextMarker = resp.NextMarker
+	}
 	return
 }
+
+// 将 SDK 返回的错误转换为 StoreError
+func bosError(op, object string, err error) error {
+	if keepError(err) {
+		return err
+	}
+	if e, ok := err.(*bce.BceServiceError); ok {
+		return newStoreError("bos", op, object, e.StatusCode, e.Code, e.RequestId, err)
+	}
+	return newStoreError("bos", op, object, 0, "", "", err)
+}
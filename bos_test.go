@@ -23,6 +23,10 @@ func init() {
 	endpoint := beego.AppConfig.String("bos::endpoint")
 	bucket := beego.AppConfig.String("bos::bucket")
 	domain := strings.ToLower(beego.AppConfig.String("bos::domain"))
+	// 没有配置 bos 时跳过测试
+	if key == "" {
+		return
+	}
 
 	Bos, err = NewBOS(key, secret, bucket, endpoint, domain)
 	if err != nil {
@@ -31,6 +35,9 @@ func init() {
 }
 
 func TestBOS(t *testing.T) {
+	if Bos == nil {
+		t.Skip("bos is not configured")
+	}
 	// upload
 	t.Log("=====Upload=====", objectSVG, objectSVGGzip)
 	err = Bos.Upload(objectSVG, objectSVG, headerSVG)
@@ -75,10 +82,16 @@ func TestBOS(t *testing.T) {
 }
 
 func TestBOS_GetSignURL(t *testing.T) {
+	if Bos == nil {
+		t.Skip("bos is not configured")
+	}
 	t.Log(Bos.GetSignURL(objectSVG, 3600))
 }
 
 func TestBOS_Delete(t *testing.T) {
+	if Bos == nil {
+		t.Skip("bos is not configured")
+	}
 	if err := Bos.Delete(objectSVG, objectSVGGzip); err != nil {
 		t.Error(err)
 	} else {
@@ -0,0 +1,115 @@
+package CloudStore
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 不支持查询已上传分片的云存储（七牛、又拍云），checkpoint 超过 24 小时后不再使用，
+// 以免服务端已经清理了未完成的分片上传
+const checkpointExpire = 24 * time.Hour
+
+// 断点续传的进度，以 JSON 格式保存在 checkpoint 文件中
+type checkpoint struct {
+	Object    string         `json:"object"`
+	UploadID  string         `json:"upload_id"`
+	Size      int64          `json:"size"`
+	ModTime   int64          `json:"mod_time"` // 待上传文件的修改时间，文件变化之后不再续传
+	PartSize  int64          `json:"part_size"`
+	CreatedAt int64          `json:"created_at"`
+	Parts     []uploadedPart `json:"parts"`
+
+	file string
+	lock sync.Mutex
+}
+
+// checkpoint 文件路径：dir 为空时保存在待上传文件的同一目录下
+func checkpointFile(tmpFile, object, dir string) string {
+	if dir == "" {
+		return tmpFile + ".cp"
+	}
+	abs, _ := filepath.Abs(tmpFile)
+	return filepath.Join(dir, MD5Crypt(abs+"\n"+object)+".cp")
+}
+
+// 读取 checkpoint 文件，文件不存在或者内容损坏时返回 nil
+func loadCheckpoint(file string) *checkpoint {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil
+	}
+	cp := &checkpoint{}
+	if err = json.Unmarshal(b, cp); err != nil {
+		return nil
+	}
+	cp.file = file
+	return cp
+}
+
+// 是否可以继续上传：对应同一个文件、文件没有被修改、分片大小一致
+func (cp *checkpoint) valid(object string, info os.FileInfo, partSize int64) bool {
+	return cp.UploadID != "" &&
+		cp.Object == object &&
+		cp.Size == info.Size() &&
+		cp.ModTime == info.ModTime().UnixNano() &&
+		cp.PartSize == partSize
+}
+
+func (cp *checkpoint) expired() bool {
+	return time.Since(time.Unix(0, cp.CreatedAt)) > checkpointExpire
+}
+
+// 已上传的分片，以分片编号为 key
+func (cp *checkpoint) done() map[int]uploadedPart {
+	done := make(map[int]uploadedPart, len(cp.Parts))
+	for _, part := range cp.Parts {
+		done[part.Number] = part
+	}
+	return done
+}
+
+// 只保留服务端确认存在的分片
+func (cp *checkpoint) retain(remote []uploadedPart) {
+	etags := make(map[int]string, len(remote))
+	for _, part := range remote {
+		etags[part.Number] = strings.Trim(part.ETag, `"`)
+	}
+	parts := cp.Parts[:0]
+	for _, part := range cp.Parts {
+		if etag, ok := etags[part.Number]; ok && etag == strings.Trim(part.ETag, `"`) {
+			parts = append(parts, part)
+		}
+	}
+	cp.Parts = parts
+}
+
+// 记录一个上传成功的分片，并发调用安全
+func (cp *checkpoint) add(part uploadedPart) (err error) {
+	cp.lock.Lock()
+	defer cp.lock.Unlock()
+	cp.Parts = append(cp.Parts, part)
+	return cp.save()
+}
+
+// 先写入临时文件再重命名，避免进程中途退出时 checkpoint 文件不完整
+func (cp *checkpoint) save() (err error) {
+	var b []byte
+	b, err = json.Marshal(cp)
+	if err != nil {
+		return
+	}
+	tmp := cp.file + ".tmp"
+	if err = ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return
+	}
+	return os.Rename(tmp, cp.file)
+}
+
+func (cp *checkpoint) remove() {
+	os.Remove(cp.file)
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/TruthHun/CloudStore"
+)
+
+// 子命令的参数，出错时不输出 flag 包的默认帮助
+func (a *app) flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	return fs
+}
+
+// 可重复的 -H "Content-Type: text/plain"
+type headerFlag map[string]string
+
+func (h headerFlag) String() string { return fmt.Sprint(map[string]string(h)) }
+
+func (h headerFlag) Set(v string) error {
+	i := strings.Index(v, ":")
+	if i <= 0 {
+		return fmt.Errorf("invalid header %q, want Name: value", v)
+	}
+	h[strings.TrimSpace(v[:i])] = strings.TrimSpace(v[i+1:])
+	return nil
+}
+
+func cmdLs(a *app, args []string) (err error) {
+	fs := a.flags("ls")
+	long := fs.Bool("l", false, "")
+	recursive := fs.Bool("r", false, "")
+	if err = fs.Parse(args); err != nil || fs.NArg() != 1 {
+		return errUsage
+	}
+	l, err := a.remote(fs.Arg(0))
+	if err != nil {
+		return
+	}
+
+	w := tabwriter.NewWriter(a.stdout, 0, 4, 2, ' ', tabwriter.AlignRight)
+	defer w.Flush()
+	print := func(f CloudStore.File) {
+		name := f.Name
+		if f.IsDir && !strings.HasSuffix(name, "/") {
+			name += "/"
+		}
+		if !*long {
+			fmt.Fprintln(w, name)
+			return
+		}
+		size, modTime := fmt.Sprint(f.Size), ""
+		if f.IsDir {
+			size = "-"
+		}
+		if !f.ModTime.IsZero() {
+			modTime = f.ModTime.Local().Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%v\t%v\t  %v\n", size, modTime, name)
+	}
+
+	if *recursive {
+		it := CloudStore.NewIterator(context.Background(), l.store, l.key, 0)
+		for it.Next() {
+			print(it.File())
+		}
+		return it.Err()
+	}
+	marker := ""
+	for {
+		var files []CloudStore.File
+		files, marker, err = l.store.ListDir(l.key, marker, 0)
+		if err != nil {
+			return
+		}
+		for _, f := range files {
+			print(f)
+		}
+		if marker == "" {
+			return
+		}
+	}
+}
+
+func cmdStat(a *app, args []string) (err error) {
+	if len(args) != 1 {
+		return errUsage
+	}
+	l, err := a.remote(args[0])
+	if err != nil {
+		return
+	}
+	info, err := l.store.GetInfo(l.key)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(a.stdout, "Name:     %v\n", info.Name)
+	fmt.Fprintf(a.stdout, "Size:     %v\n", info.Size)
+	if !info.ModTime.IsZero() {
+		fmt.Fprintf(a.stdout, "ModTime:  %v\n", info.ModTime.Local().Format(time.RFC3339))
+	}
+	var keys []string
+	for k := range info.Header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(a.stdout, "%v: %v\n", k, info.Header[k])
+	}
+	return
+}
+
+func cmdCp(a *app, args []string) (err error) {
+	fs := a.flags("cp")
+	headers := make(headerFlag)
+	fs.Var(headers, "H", "")
+	if err = fs.Parse(args); err != nil || fs.NArg() != 2 {
+		return errUsage
+	}
+	src, err := a.parse(fs.Arg(0))
+	if err != nil {
+		return
+	}
+	dst, err := a.parse(fs.Arg(1))
+	if err != nil {
+		return
+	}
+
+	// 目标为目录时使用源文件的文件名
+	switch {
+	case dst.store == nil:
+		if info, e := os.Stat(dst.key); e == nil && info.IsDir() || strings.HasSuffix(dst.key, string(os.PathSeparator)) {
+			dst.key = filepath.Join(dst.key, path.Base(filepath.ToSlash(src.key)))
+		}
+	case dst.key == "" || strings.HasSuffix(dst.key, "/"):
+		dst.key += path.Base(filepath.ToSlash(src.key))
+	}
+
+	switch {
+	case src.store == nil && dst.store == nil:
+		return fmt.Errorf("at least one of %v and %v must be a remote path", src, dst)
+	case src.store == nil:
+		err = dst.store.Upload(src.key, dst.key, headers)
+	case dst.store == nil:
+		err = src.store.Download(src.key, dst.key)
+	case src.section == dst.section:
+		err = src.store.Copy(src.key, dst.key)
+	default:
+		_, err = CloudStore.CopyObject(src.store, src.key, dst.store, dst.key, headers)
+	}
+	if err == nil {
+		fmt.Fprintf(a.stderr, "%v -> %v\n", src, dst)
+	}
+	return
+}
+
+// 在不同的云存储之间以数据流的方式复制文件，保留源文件的 Content-Type 等 header 以及自定义元数据
+func cmdRm(a *app, args []string) (err error) {
+	if len(args) == 0 {
+		return errUsage
+	}
+	keys := make(map[string][]string)
+	var sections []string
+	for _, arg := range args {
+		var l location
+		if l, err = a.remote(arg); err != nil {
+			return
+		}
+		if _, ok := keys[l.section]; !ok {
+			sections = append(sections, l.section)
+		}
+		keys[l.section] = append(keys[l.section], l.key)
+	}
+	for _, section := range sections {
+		if err = a.stores[section].Delete(keys[section]...); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func cmdSign(a *app, args []string) (err error) {
+	fs := a.flags("sign")
+	expire := fs.Int64("e", 3600, "")
+	if err = fs.Parse(args); err != nil || fs.NArg() != 1 {
+		return errUsage
+	}
+	l, err := a.remote(fs.Arg(0))
+	if err != nil {
+		return
+	}
+	link, err := l.store.GetSignURL(l.key, *expire)
+	if err != nil {
+		return
+	}
+	fmt.Fprintln(a.stdout, link)
+	return
+}
+
+// Sync 不改变文件名，目标只需要写云存储的名称，也可以写与源相同的前缀
+func cmdSync(a *app, args []string) (err error) {
+	fs := a.flags("sync")
+	dryRun := fs.Bool("n", false, "")
+	del := fs.Bool("delete", false, "")
+	parallel := fs.Int("p", 4, "")
+	if err = fs.Parse(args); err != nil || fs.NArg() != 2 {
+		return errUsage
+	}
+	src, err := a.remote(fs.Arg(0))
+	if err != nil {
+		return
+	}
+	dst, err := a.remote(fs.Arg(1))
+	if err != nil {
+		return
+	}
+	if dst.key != "" && dst.key != src.key {
+		return fmt.Errorf("sync keeps file names, destination should be %v: or %v:%v", dst.section, dst.section, src.key)
+	}
+
+	opts := CloudStore.SyncOptions{
+		Parallel: *parallel,
+		DryRun:   *dryRun,
+		Delete:   *del,
+		OnFile: func(action, key string, err error) {
+			switch {
+			case err != nil:
+				fmt.Fprintf(a.stderr, "%v %v: %v\n", action, key, err)
+			case action != "skip":
+				fmt.Fprintf(a.stdout, "%v %v\n", action, key)
+			}
+		},
+	}
+	report, err := CloudStore.Sync(src.store, dst.store, src.key, opts)
+	fmt.Fprintln(a.stderr, report)
+	return
+}
+
+func cmdDu(a *app, args []string) (err error) {
+	fs := a.flags("du")
+	human := fs.Bool("h", false, "")
+	if err = fs.Parse(args); err != nil || fs.NArg() != 1 {
+		return errUsage
+	}
+	l, err := a.remote(fs.Arg(0))
+	if err != nil {
+		return
+	}
+	var size, count int64
+	it := CloudStore.NewIterator(context.Background(), l.store, l.key, 0)
+	for it.Next() {
+		if f := it.File(); !f.IsDir {
+			size += f.Size
+			count++
+		}
+	}
+	if err = it.Err(); err != nil {
+		return
+	}
+	s := fmt.Sprint(size)
+	if *human {
+		s = humanSize(size)
+	}
+	fmt.Fprintf(a.stdout, "%v\t%v files\t%v\n", s, count, l)
+	return
+}
+
+func humanSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%dB", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%c", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+func cmdCat(a *app, args []string) (err error) {
+	if len(args) == 0 {
+		return errUsage
+	}
+	for _, arg := range args {
+		var l location
+		if l, err = a.remote(arg); err != nil {
+			return
+		}
+		var reader io.ReadCloser
+		if reader, _, err = l.store.Get(l.key); err != nil {
+			return
+		}
+		_, err = io.Copy(a.stdout, reader)
+		reader.Close()
+		if err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,201 @@
+// cloudstore 是基于 CloudStore 的命令行工具，使用同一套命令管理各家云存储中的文件。
+//
+// 云存储的配置与 conf/app.conf.example 的格式相同，每一节为一个云存储，节的名称为驱动名称；
+// 同一种云存储有多个配置时，可以在节中使用 driver 指定驱动，如：
+//
+//	[backup]
+//	driver    = cos
+//	accessKey = ${COS_ACCESS_KEY}
+//	...
+//
+// 云存储中的文件写作 {节的名称}:{文件}，如 oss:documents/a.pdf，其他的为本地文件
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/TruthHun/CloudStore"
+	"github.com/astaxie/beego/config"
+)
+
+const usage = `Usage: cloudstore [-c config] <command> [flags] [args]
+
+Commands:
+  ls    [-l] [-r] store:[prefix]        list files
+  stat  store:key                       show file info and headers
+  cp    [-H header]... src dst          copy local<->remote or remote<->remote
+  rm    store:key...                    delete files
+  sign  [-e seconds] store:key          print a signed download URL
+  sync  [-n] [-delete] [-p n] store:[prefix] store:
+                                        copy new and changed files between stores
+  du    [-h] store:[prefix]             total size and number of files
+  cat   store:key                       write file content to stdout
+
+Remote paths are written as section:key, where section is a section of the
+config file (default $CLOUDSTORE_CONFIG or conf/app.conf).
+`
+
+// 命令的用法错误，退出码为 2
+var errUsage = errors.New("usage")
+
+type command func(app *app, args []string) error
+
+var commands = map[string]command{
+	"ls":   cmdLs,
+	"stat": cmdStat,
+	"cp":   cmdCp,
+	"rm":   cmdRm,
+	"sign": cmdSign,
+	"sync": cmdSync,
+	"du":   cmdDu,
+	"cat":  cmdCat,
+}
+
+type app struct {
+	stdout   io.Writer
+	stderr   io.Writer
+	confFile string
+	conf     config.Configer
+	stores   map[string]CloudStore.CloudStore
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("cloudstore", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() { fmt.Fprint(stderr, usage) }
+	confFile := fs.String("c", defaultConfigFile(), "config file")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		fmt.Fprintf(stderr, "cloudstore: unknown command %q\n\n%v", fs.Arg(0), usage)
+		return 2
+	}
+
+	conf, err := config.NewConfig("ini", *confFile)
+	if err != nil {
+		fmt.Fprintf(stderr, "cloudstore: %v\n", err)
+		return 1
+	}
+	a := &app{stdout: stdout, stderr: stderr, confFile: *confFile, conf: conf, stores: make(map[string]CloudStore.CloudStore)}
+	if err = cmd(a, fs.Args()[1:]); err != nil {
+		if errors.Is(err, errUsage) || errors.Is(err, flag.ErrHelp) {
+			fmt.Fprint(stderr, usage)
+			return 2
+		}
+		fmt.Fprintf(stderr, "cloudstore %v: %v\n", fs.Arg(0), err)
+		return 1
+	}
+	return 0
+}
+
+func defaultConfigFile() string {
+	if file := os.Getenv("CLOUDSTORE_CONFIG"); file != "" {
+		return file
+	}
+	return "conf/app.conf"
+}
+
+// location 为命令行中的文件，store 为空时是本地文件
+type location struct {
+	section string
+	store   CloudStore.CloudStore
+	key     string
+}
+
+func (l location) String() string {
+	if l.store == nil {
+		return l.key
+	}
+	return l.section + ":" + l.key
+}
+
+// parse 解析 section:key，冒号前不是配置文件中的节时视为本地文件（如 Windows 的 C:\tmp）
+func (a *app) parse(arg string) (l location, err error) {
+	i := strings.Index(arg, ":")
+	if i <= 0 {
+		return location{key: arg}, nil
+	}
+	section := strings.ToLower(arg[:i])
+	values, e := a.conf.GetSection(section)
+	if e != nil {
+		return location{key: arg}, nil
+	}
+	l = location{section: section, key: strings.TrimLeft(arg[i+1:], "/")}
+	if l.store, err = a.open(section, values); err != nil {
+		return
+	}
+	return
+}
+
+// remote 解析必须为云存储的参数
+func (a *app) remote(arg string) (l location, err error) {
+	if l, err = a.parse(arg); err != nil {
+		return
+	}
+	if l.store == nil {
+		err = fmt.Errorf("%q is not a remote path (section:key), configured sections: %v", arg, a.sections())
+	}
+	return
+}
+
+func (a *app) open(section string, values map[string]string) (store CloudStore.CloudStore, err error) {
+	if store, ok := a.stores[section]; ok {
+		return store, nil
+	}
+	cfg := CloudStore.ConfigFromMap(values)
+	driver := section
+	if d := cfg.Options["driver"]; d != "" {
+		driver = d
+	}
+	if store, err = CloudStore.Open(driver, cfg); err != nil {
+		return
+	}
+	store = CloudStore.WithRetry(store, CloudStore.RetryPolicy{})
+	a.stores[section] = store
+	return
+}
+
+// 配置文件中云存储的节：以驱动名称命名，或者使用 driver 指定驱动的节；
+// beego 的 config 不能列出所有的节，这里从配置文件中读取节的名称
+func (a *app) sections() (names []string) {
+	drivers := make(map[string]bool)
+	for _, driver := range CloudStore.Drivers() {
+		drivers[driver] = true
+	}
+	b, _ := ioutil.ReadFile(a.confFile)
+	found := make(map[string]bool)
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "[") || !strings.HasSuffix(line, "]") {
+			continue
+		}
+		section := strings.ToLower(strings.TrimSpace(line[1 : len(line)-1]))
+		values, err := a.conf.GetSection(section)
+		if err != nil || found[section] {
+			continue
+		}
+		if drivers[section] || CloudStore.ConfigFromMap(values).Options["driver"] != "" {
+			found[section] = true
+			names = append(names, section)
+		}
+	}
+	sort.Strings(names)
+	return
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 使用两个 Local 云存储，不需要访问网络
+func testConfig(t *testing.T) string {
+	dir := t.TempDir()
+	conf := filepath.Join(dir, "app.conf")
+	content := fmt.Sprintf(`[local]
+root   = %v
+domain = http://localhost:8080/files
+secret = 123456
+
+[backup]
+driver = local
+root   = %v
+secret = 123456
+`, filepath.Join(dir, "local"), filepath.Join(dir, "backup"))
+	if err := ioutil.WriteFile(conf, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return conf
+}
+
+func runCommand(t *testing.T, conf string, args ...string) (stdout string, code int) {
+	var out, errOut bytes.Buffer
+	code = run(append([]string{"-c", conf}, args...), &out, &errOut)
+	t.Logf("cloudstore %v: %v%v", strings.Join(args, " "), out.String(), errOut.String())
+	return out.String(), code
+}
+
+func TestCommands(t *testing.T) {
+	conf := testConfig(t)
+	tmp := filepath.Join(t.TempDir(), "hello.txt")
+	if err := ioutil.WriteFile(tmp, []byte("hello, cloud store"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, code := runCommand(t, conf, "cp", "-H", "Content-Type: text/plain", tmp, "local:docs/"); code != 0 {
+		t.Fatalf("cp exit code %v", code)
+	}
+	if out, _ := runCommand(t, conf, "cat", "local:docs/hello.txt"); out != "hello, cloud store" {
+		t.Errorf("cat = %q", out)
+	}
+	if out, _ := runCommand(t, conf, "stat", "local:docs/hello.txt"); !strings.Contains(out, "Size:     18") || !strings.Contains(out, "text/plain") {
+		t.Errorf("stat = %q", out)
+	}
+	if out, _ := runCommand(t, conf, "ls", "local:"); strings.TrimSpace(out) != "docs/" {
+		t.Errorf("ls = %q", out)
+	}
+	if out, _ := runCommand(t, conf, "ls", "-r", "-l", "local:"); !strings.Contains(out, "18") || !strings.Contains(out, "docs/hello.txt") {
+		t.Errorf("ls -r -l = %q", out)
+	}
+	if out, _ := runCommand(t, conf, "sign", "-e", "60", "local:docs/hello.txt"); !strings.HasPrefix(out, "http://localhost:8080/files/docs/hello.txt?") {
+		t.Errorf("sign = %q", out)
+	}
+
+	// 同一个云存储内复制，以及在不同的云存储之间复制
+	runCommand(t, conf, "cp", "local:docs/hello.txt", "local:docs/copy.txt")
+	runCommand(t, conf, "cp", "local:docs/hello.txt", "backup:other/hello.txt")
+	if out, _ := runCommand(t, conf, "du", "local:docs/"); !strings.HasPrefix(out, "36\t2 files") {
+		t.Errorf("du = %q", out)
+	}
+	if out, _ := runCommand(t, conf, "cat", "backup:other/hello.txt"); out != "hello, cloud store" {
+		t.Errorf("cat = %q", out)
+	}
+
+	if out, code := runCommand(t, conf, "sync", "local:docs/", "backup:"); code != 0 || !strings.Contains(out, "copy docs/copy.txt") {
+		t.Errorf("sync = %q, %v", out, code)
+	}
+	if out, _ := runCommand(t, conf, "du", "-h", "backup:"); !strings.HasPrefix(out, "54B\t3 files") {
+		t.Errorf("du -h = %q", out)
+	}
+
+	download := filepath.Join(t.TempDir(), "download.txt")
+	runCommand(t, conf, "cp", "backup:docs/copy.txt", download)
+	if b, _ := ioutil.ReadFile(download); string(b) != "hello, cloud store" {
+		t.Errorf("download = %q", b)
+	}
+
+	if _, code := runCommand(t, conf, "rm", "local:docs/hello.txt", "backup:docs/hello.txt"); code != 0 {
+		t.Errorf("rm exit code %v", code)
+	}
+	if _, code := runCommand(t, conf, "stat", "local:docs/hello.txt"); code != 1 {
+		t.Errorf("stat deleted file exit code %v", code)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	conf := testConfig(t)
+	for _, args := range [][]string{{}, {"unknown"}, {"cat"}, {"ls", "-x", "local:"}} {
+		if _, code := runCommand(t, conf, args...); code != 2 {
+			t.Errorf("cloudstore %v exit code %v, want 2", args, code)
+		}
+	}
+	if _, code := runCommand(t, conf, "cat", "missing:a.txt"); code != 1 {
+		t.Errorf("cat with unknown section exit code %v, want 1", code)
+	}
+	// 提示中包含使用 driver 指定驱动的节
+	var out, errOut bytes.Buffer
+	if code := run([]string{"-c", conf, "rm", "missing:a.txt"}, &out, &errOut); code != 1 || !strings.Contains(errOut.String(), "configured sections: [backup local]") {
+		t.Errorf("rm with unknown section = %v, %q", code, errOut.String())
+	}
+	if _, code := runCommand(t, filepath.Join(os.TempDir(), "missing.conf"), "ls", "local:"); code != 1 {
+		t.Errorf("missing config exit code %v, want 1", code)
+	}
+}
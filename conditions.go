@@ -0,0 +1,141 @@
+package CloudStore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Conditions 为 GetIf、GetInfoIf 的条件，与 HTTP 的条件请求相同，为空的字段不检查：
+// IfMatch、IfUnmodifiedSince 不满足时返回 ErrPreconditionFailed，IfNoneMatch、IfModifiedSince 不满足时返回 ErrNotModified。
+// OSS、COS、MinIO、OBS 以及 BOS 由云存储检查条件，七牛、又拍云、Local 以及 Memory 获取文件信息之后在客户端检查，结果相同
+type Conditions struct {
+	IfMatch           string    // 文件的 ETag 与之相同时才返回，可以是逗号分隔的多个 ETag，"*" 表示文件存在即可
+	IfNoneMatch       string    // 文件的 ETag 与之都不相同时才返回，格式同 IfMatch
+	IfModifiedSince   time.Time // 文件在这之后修改过才返回，精确到秒
+	IfUnmodifiedSince time.Time // 文件在这之后没有修改过才返回，精确到秒
+}
+
+// 按照 RFC 7232 的顺序检查条件：If-Match 存在时忽略 If-Unmodified-Since，If-None-Match 存在时忽略 If-Modified-Since
+func (c Conditions) check(info File) error {
+	if c.IfMatch != "" {
+		if !matchETag(c.IfMatch, info.ETag) {
+			return ErrPreconditionFailed
+		}
+	} else if !c.IfUnmodifiedSince.IsZero() && info.ModTime.Truncate(time.Second).After(c.IfUnmodifiedSince) {
+		return ErrPreconditionFailed
+	}
+	if c.IfNoneMatch != "" {
+		if matchETag(c.IfNoneMatch, info.ETag) {
+			return ErrNotModified
+		}
+	} else if !c.IfModifiedSince.IsZero() && !info.ModTime.Truncate(time.Second).After(c.IfModifiedSince) {
+		return ErrNotModified
+	}
+	return nil
+}
+
+// header 返回条件请求的请求头，ETag 带上引号
+func (c Conditions) header() http.Header {
+	header := make(http.Header)
+	if c.IfMatch != "" {
+		header.Set("If-Match", quoteETags(c.IfMatch))
+	}
+	if c.IfNoneMatch != "" {
+		header.Set("If-None-Match", quoteETags(c.IfNoneMatch))
+	}
+	if !c.IfModifiedSince.IsZero() {
+		header.Set("If-Modified-Since", c.IfModifiedSince.UTC().Format(http.TimeFormat))
+	}
+	if !c.IfUnmodifiedSince.IsZero() {
+		header.Set("If-Unmodified-Since", c.IfUnmodifiedSince.UTC().Format(http.TimeFormat))
+	}
+	return header
+}
+
+// etags 为逗号分隔的 ETag 或者 "*"，比较时忽略引号、弱校验前缀 W/ 以及大小写
+func matchETag(etags, etag string) bool {
+	for _, e := range strings.Split(etags, ",") {
+		e = strings.TrimSpace(e)
+		if e == "*" || e != "" && strings.EqualFold(trimETag(e), trimETag(etag)) {
+			return true
+		}
+	}
+	return false
+}
+
+func trimETag(etag string) string {
+	return strings.Trim(strings.TrimPrefix(etag, "W/"), `"`)
+}
+
+func quoteETags(etags string) string {
+	var quoted []string
+	for _, e := range strings.Split(etags, ",") {
+		if e = strings.TrimSpace(e); e == "*" {
+			quoted = append(quoted, e)
+		} else if e != "" {
+			quoted = append(quoted, `"`+trimETag(e)+`"`)
+		}
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// 不支持条件下载的云存储先下载，再根据返回的文件信息检查条件，条件不满足时关闭 reader
+func getIf(ctx context.Context, get func(ctx context.Context, object string) (io.ReadCloser, File, error), object string, cond Conditions) (reader io.ReadCloser, info File, err error) {
+	reader, info, err = get(ctx, object)
+	if err != nil {
+		return
+	}
+	if err = cond.check(info); err != nil {
+		reader.Close()
+		reader = nil
+	}
+	return
+}
+
+func getInfoIf(ctx context.Context, getInfo func(ctx context.Context, object string) (File, error), object string, cond Conditions) (info File, err error) {
+	info, err = getInfo(ctx, object)
+	if err == nil {
+		err = cond.check(info)
+	}
+	return
+}
+
+// 不支持条件上传的云存储（七牛、又拍云以及 Local、Memory）在上传之前获取文件信息并检查 If-Match、If-None-Match，
+// 检查之后、上传完成之前文件仍然可能被其他客户端修改
+func checkUploadConditions(ctx context.Context, getInfo func(ctx context.Context, object string) (File, error), object string, h objectHeader) error {
+	if h.IfMatch == "" && h.IfNoneMatch == "" {
+		return nil
+	}
+	info, err := getInfo(ctx, object)
+	if errors.Is(err, ErrNotExist) {
+		if h.IfMatch != "" {
+			return ErrPreconditionFailed
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if h.IfNoneMatch == "*" {
+		return ErrExist
+	}
+	// 上传时 If-None-Match 不满足也是 412
+	if (Conditions{IfMatch: h.IfMatch, IfNoneMatch: h.IfNoneMatch}).check(info) != nil {
+		return ErrPreconditionFailed
+	}
+	return nil
+}
+
+// 原生支持条件上传的云存储在 If-None-Match: * 不满足时返回 412，转换为 ErrExist；
+// 同时设置了 If-Match 时无法区分是哪个条件不满足，保留 ErrPreconditionFailed
+func uploadConditionError(err error, h objectHeader) error {
+	var e *StoreError
+	if h.IfNoneMatch == "*" && h.IfMatch == "" && errors.As(err, &e) && e.kind == ErrPreconditionFailed {
+		e.kind = ErrExist
+	}
+	return err
+}
@@ -0,0 +1,78 @@
+package CloudStore
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConditionsCheck(t *testing.T) {
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 600, time.UTC)
+	info := File{ETag: "abc", ModTime: modTime}
+	for _, c := range []struct {
+		cond Conditions
+		want error
+	}{
+		{Conditions{}, nil},
+		{Conditions{IfMatch: `"abc"`}, nil},
+		{Conditions{IfMatch: `"x", W/"ABC"`}, nil},
+		{Conditions{IfMatch: "*"}, nil},
+		{Conditions{IfMatch: "x"}, ErrPreconditionFailed},
+		{Conditions{IfNoneMatch: "x, y"}, nil},
+		{Conditions{IfNoneMatch: "abc"}, ErrNotModified},
+		{Conditions{IfNoneMatch: "*"}, ErrNotModified},
+		// 精确到秒，与 Last-Modified 一致
+		{Conditions{IfModifiedSince: modTime.Truncate(time.Second)}, ErrNotModified},
+		{Conditions{IfModifiedSince: modTime.Add(-time.Second)}, nil},
+		{Conditions{IfUnmodifiedSince: modTime.Truncate(time.Second)}, nil},
+		{Conditions{IfUnmodifiedSince: modTime.Add(-time.Second)}, ErrPreconditionFailed},
+		// If-Match 存在时忽略 If-Unmodified-Since，If-None-Match 存在时忽略 If-Modified-Since
+		{Conditions{IfMatch: "abc", IfUnmodifiedSince: modTime.Add(-time.Hour)}, nil},
+		{Conditions{IfNoneMatch: "x", IfModifiedSince: modTime.Add(time.Hour)}, nil},
+		{Conditions{IfMatch: "x", IfNoneMatch: "abc"}, ErrPreconditionFailed},
+	} {
+		if err := c.cond.check(info); err != c.want {
+			t.Errorf("%+v: check = %v, want %v", c.cond, err, c.want)
+		}
+	}
+}
+
+func TestConditionsHeader(t *testing.T) {
+	since := time.Date(2020, 1, 2, 3, 4, 5, 0, time.FixedZone("CST", 8*3600))
+	header := Conditions{IfMatch: `abc, "def"`, IfNoneMatch: "*", IfModifiedSince: since}.header()
+	for k, v := range map[string]string{
+		"If-Match":            `"abc", "def"`,
+		"If-None-Match":       "*",
+		"If-Modified-Since":   "Wed, 01 Jan 2020 19:04:05 GMT",
+		"If-Unmodified-Since": "",
+	} {
+		if got := header.Get(k); got != v {
+			t.Errorf("header %v = %q, want %q", k, got, v)
+		}
+	}
+}
+
+func TestCheckUploadConditions(t *testing.T) {
+	m := NewMemory("", "")
+	if err := m.Put("a.txt", strings.NewReader(""), 0, map[string]string{HeaderIfNoneMatch: "*"}); err != nil {
+		t.Fatalf("Put(If-None-Match: *): %v", err)
+	}
+	err := m.Put("a.txt", strings.NewReader(""), 0, map[string]string{HeaderIfNoneMatch: "*"})
+	if !errors.Is(err, ErrExist) || !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("Put(If-None-Match: *) again: err = %v, want ErrExist", err)
+	}
+	info, err := m.GetInfo("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = m.Put("a.txt", strings.NewReader(""), 0, map[string]string{HeaderIfNoneMatch: info.ETag}); !errors.Is(err, ErrPreconditionFailed) || errors.Is(err, ErrExist) {
+		t.Errorf("Put(If-None-Match: current ETag): err = %v, want ErrPreconditionFailed", err)
+	}
+	if err = m.Put("b.txt", strings.NewReader(""), 0, map[string]string{HeaderIfMatch: "*"}); !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("Put(If-Match: *, missing): err = %v, want ErrPreconditionFailed", err)
+	}
+	if err = m.Put("a.txt", strings.NewReader(""), 0, map[string]string{HeaderIfMatch: `"` + info.ETag + `"`}); err != nil {
+		t.Errorf("Put(If-Match: current ETag): %v", err)
+	}
+}
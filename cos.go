@@ -2,8 +2,12 @@ package CloudStore
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"os"
@@ -50,50 +54,239 @@ func NewCOS(accessKey, secretKey, bucket, appId, region, domain string) (c *COS,
 }
 
 func (c *COS) IsExist(object string) (err error) {
-	_, err = c.GetInfo(object)
+	return c.IsExistContext(context.Background(), object)
+}
+
+func (c *COS) IsExistContext(ctx context.Context, object string) (err error) {
+	defer func() { err = cosError("IsExist", object, err) }()
+	_, err = c.GetInfoContext(ctx, object)
 	return
 }
 
 func (c *COS) Upload(tmpFile, saveFile string, headers ...map[string]string) (err error) {
-	var reader *os.File
+	return c.UploadContext(context.Background(), tmpFile, saveFile, headers...)
+}
+
+func (c *COS) UploadContext(ctx context.Context, tmpFile, saveFile string, headers ...map[string]string) (err error) {
+	defer func() { err = cosError("Upload", saveFile, err) }()
+	if useMultipart(tmpFile) {
+		return c.UploadMultipartContext(ctx, tmpFile, saveFile, MultipartOptions{}, headers...)
+	}
+	var (
+		reader *os.File
+		info   os.FileInfo
+	)
 	reader, err = os.Open(tmpFile)
 	if err != nil {
 		return
 	}
 	defer reader.Close()
-	objHeader := &cos.ObjectPutHeaderOptions{}
-	for _, header := range headers {
-		for k, v := range header {
-			switch strings.ToLower(k) {
-			case "content-encoding":
-				objHeader.ContentEncoding = v
-			case "content-type":
-				objHeader.ContentType = v
-			case "content-disposition":
-				objHeader.ContentDisposition = v
-			}
+	info, err = reader.Stat()
+	if err != nil {
+		return
+	}
+	return c.PutContext(ctx, saveFile, reader, info.Size(), headers...)
+}
+
+func (c *COS) Put(object string, reader io.Reader, size int64, headers ...map[string]string) (err error) {
+	return c.PutContext(context.Background(), object, reader, size, headers...)
+}
+
+func (c *COS) PutContext(ctx context.Context, object string, reader io.Reader, size int64, headers ...map[string]string) (err error) {
+	defer func() { err = cosError("Put", object, err) }()
+	acl, objHeader := cosPutHeader(headers...)
+	objHeader.XOptionHeader = cosUploadConditions(parseHeaders(headers...))
+	if size > 0 {
+		objHeader.ContentLength = size
+	}
+	opt := &cos.ObjectPutOptions{ACLHeaderOptions: acl, ObjectPutHeaderOptions: objHeader}
+	_, err = c.Client.Object.Put(ctx, objectRel(object), reader, opt)
+	return
+}
+
+// 条件上传由 COS 检查：If-None-Match 为 "*" 时使用 x-cos-forbid-overwrite，文件已存在时返回 FileAlreadyExists；
+// 其他条件作为 If-Match、If-None-Match 请求头发送，简单上传和完成分片上传时使用
+func cosUploadConditions(h objectHeader) *http.Header {
+	cond := Conditions{IfMatch: h.IfMatch, IfNoneMatch: h.IfNoneMatch}
+	if h.IfNoneMatch == "*" {
+		cond.IfNoneMatch = ""
+	}
+	header := cond.header()
+	if h.IfNoneMatch == "*" {
+		header.Set("x-cos-forbid-overwrite", "true")
+	}
+	return &header
+}
+
+// 上传时的请求头，自定义元数据使用 x-cos-meta- 前缀
+func cosPutHeader(headers ...map[string]string) (acl *cos.ACLHeaderOptions, objHeader *cos.ObjectPutHeaderOptions) {
+	h := parseHeaders(headers...)
+	acl = &cos.ACLHeaderOptions{XCosACL: h.ACL}
+	objHeader = &cos.ObjectPutHeaderOptions{
+		CacheControl:       h.CacheControl,
+		ContentDisposition: h.ContentDisposition,
+		ContentEncoding:    h.ContentEncoding,
+		ContentLanguage:    h.ContentLanguage,
+		ContentType:        h.ContentType,
+		Expires:            h.Expires,
+		ContentMD5:         h.ContentMD5,
+		XCosMetaXXX:        &http.Header{},
+	}
+	if h.StorageClass != "" {
+		objHeader.XCosStorageClass = cosDialect.nativeStorageClass(h.StorageClass)
+	}
+	for k, v := range h.Metadata {
+		objHeader.XCosMetaXXX.Set(cosDialect.metaPrefix+k, v)
+	}
+	return
+}
+
+func (c *COS) Get(object string) (reader io.ReadCloser, info File, err error) {
+	return c.GetContext(context.Background(), object)
+}
+
+func (c *COS) GetContext(ctx context.Context, object string) (reader io.ReadCloser, info File, err error) {
+	defer func() { err = cosError("Get", object, err) }()
+	return c.get(ctx, object, nil)
+}
+
+func (c *COS) GetIf(object string, cond Conditions) (reader io.ReadCloser, info File, err error) {
+	return c.GetIfContext(context.Background(), object, cond)
+}
+
+func (c *COS) GetIfContext(ctx context.Context, object string, cond Conditions) (reader io.ReadCloser, info File, err error) {
+	defer func() { err = cosError("GetIf", object, err) }()
+	header := cond.header()
+	return c.get(ctx, object, &cos.ObjectGetOptions{XOptionHeader: &header})
+}
+
+func (c *COS) get(ctx context.Context, object string, opt *cos.ObjectGetOptions) (reader io.ReadCloser, info File, err error) {
+	var resp *cos.Response
+	path := objectRel(object)
+	resp, err = c.Client.Object.Get(ctx, path, opt)
+	if err != nil {
+		return
+	}
+	info = fileFromHeader(path, resp.Header, cosDialect)
+	reader = resp.Body
+	return
+}
+
+func (c *COS) UploadMultipart(tmpFile, saveFile string, opts MultipartOptions, headers ...map[string]string) (err error) {
+	return c.UploadMultipartContext(context.Background(), tmpFile, saveFile, opts, headers...)
+}
+
+func (c *COS) UploadMultipartContext(ctx context.Context, tmpFile, saveFile string, opts MultipartOptions, headers ...map[string]string) (err error) {
+	defer func() { err = cosError("UploadMultipart", saveFile, err) }()
+	return uploadMultipart(ctx, c, tmpFile, saveFile, opts, headers...)
+}
+
+func (c *COS) initMultipart(ctx context.Context, object string, size, partSize int64, header map[string]string) (uploadID string, err error) {
+	acl, objHeader := cosPutHeader(header)
+	var res *cos.InitiateMultipartUploadResult
+	res, _, err = c.Client.Object.InitiateMultipartUpload(ctx, object, &cos.InitiateMultipartUploadOptions{
+		ACLHeaderOptions:       acl,
+		ObjectPutHeaderOptions: objHeader,
+	})
+	if err != nil {
+		return
+	}
+	uploadID = res.UploadID
+	return
+}
+
+func (c *COS) uploadPart(ctx context.Context, object, uploadID string, number int, reader io.Reader, size int64) (etag string, err error) {
+	var resp *cos.Response
+	resp, err = c.Client.Object.UploadPart(ctx, object, uploadID, number, reader, &cos.ObjectUploadPartOptions{
+		ContentLength: size,
+	})
+	if err != nil {
+		return
+	}
+	etag = resp.Header.Get("ETag")
+	return
+}
+
+func (c *COS) completeMultipart(ctx context.Context, object, uploadID string, size int64, parts []uploadedPart, header map[string]string) (err error) {
+	opt := &cos.CompleteMultipartUploadOptions{XOptionHeader: cosUploadConditions(parseHeaders(header))}
+	for _, part := range parts {
+		opt.Parts = append(opt.Parts, cos.Object{PartNumber: part.Number, ETag: part.ETag})
+	}
+	_, _, err = c.Client.Object.CompleteMultipartUpload(ctx, object, uploadID, opt)
+	return
+}
+
+func (c *COS) listParts(ctx context.Context, object, uploadID string) (parts []uploadedPart, err error) {
+	opt := &cos.ObjectListPartsOptions{}
+	for {
+		var res *cos.ObjectListPartsResult
+		res, _, err = c.Client.Object.ListParts(ctx, object, uploadID, opt)
+		if err != nil {
+			return
+		}
+		for _, part := range res.Parts {
+			parts = append(parts, uploadedPart{Number: part.PartNumber, ETag: part.ETag})
+		}
+		if !res.IsTruncated {
+			return
 		}
+		opt.PartNumberMarker = res.NextPartNumberMarker
 	}
-	opt := &cos.ObjectPutOptions{ObjectPutHeaderOptions: objHeader}
-	_, err = c.Client.Object.Put(context.Background(), objectRel(saveFile), reader, opt)
+}
+
+func (c *COS) abortMultipart(ctx context.Context, object, uploadID string) (err error) {
+	_, err = c.Client.Object.AbortMultipartUpload(ctx, object, uploadID)
 	return
 }
 
 func (c *COS) Delete(objects ...string) (err error) {
-	var errs []string
+	return c.DeleteContext(context.Background(), objects...)
+}
+
+func (c *COS) DeleteContext(ctx context.Context, objects ...string) (err error) {
+	defer func() { err = cosError("Delete", strings.Join(objects, ", "), err) }()
+	var errs []error
 	for _, object := range objects {
-		_, err = c.Client.Object.Delete(context.Background(), objectRel(object))
-		if err != nil {
-			errs = append(errs, err.Error())
+		if _, e := c.Client.Object.Delete(ctx, objectRel(object)); e != nil {
+			errs = append(errs, cosError("Delete", object, e))
 		}
 	}
-	if len(errs) > 0 {
-		err = errors.New(strings.Join(errs, "; "))
-	}
+	return deleteError(errs)
+}
+
+func (c *COS) Copy(src, dst string) (err error) {
+	return c.CopyContext(context.Background(), src, dst)
+}
+
+func (c *COS) CopyContext(ctx context.Context, src, dst string) (err error) {
+	defer func() { err = cosError("Copy", src, err) }()
+	sourceURL := c.Client.BaseURL.BucketURL.Host + objectAbs(src)
+	_, _, err = c.Client.Object.Copy(ctx, objectRel(dst), sourceURL, nil)
 	return
 }
 
+func (c *COS) Move(src, dst string) (err error) {
+	return c.MoveContext(context.Background(), src, dst)
+}
+
+// COS 没有移动文件的接口，先复制再删除源文件
+func (c *COS) MoveContext(ctx context.Context, src, dst string) (err error) {
+	defer func() { err = cosError("Move", src, err) }()
+	if err = c.CopyContext(ctx, src, dst); err != nil {
+		return
+	}
+	return c.DeleteContext(ctx, src)
+}
+
 func (c *COS) GetSignURL(object string, expire int64) (link string, err error) {
+	return c.GetSignURLContext(context.Background(), object, expire)
+}
+
+func (c *COS) GetSignURLContext(ctx context.Context, object string, expire int64) (link string, err error) {
+	defer func() { err = cosError("GetSignURL", object, err) }()
+	if err = ctx.Err(); err != nil {
+		return
+	}
 	if expire <= 0 {
 		link = c.Domain + objectAbs(object)
 		return
@@ -101,7 +294,7 @@ func (c *COS) GetSignURL(object string, expire int64) (link string, err error) {
 
 	var u *url.URL
 	exp := time.Duration(expire) * time.Second
-	u, err = c.Client.Object.GetPresignedURL(context.Background(),
+	u, err = c.Client.Object.GetPresignedURL(ctx,
 		http.MethodGet, objectRel(object),
 		c.AccessKey, c.SecretKey,
 		exp, nil)
@@ -115,34 +308,224 @@ func (c *COS) GetSignURL(object string, expire int64) (link string, err error) {
 	return
 }
 
-func (c *COS) Download(object string, savePath string) (err error) {
-	_, err = c.Client.Object.GetToFile(context.Background(), objectRel(object), savePath, nil)
+func (c *COS) GetSignUploadURL(object string, expire int64, headers ...map[string]string) (upload SignedUpload, err error) {
+	return c.GetSignUploadURLContext(context.Background(), object, expire, headers...)
+}
+
+// 上传签名不替换为绑定的域名，CDN 域名一般不支持 PUT
+func (c *COS) GetSignUploadURLContext(ctx context.Context, object string, expire int64, headers ...map[string]string) (upload SignedUpload, err error) {
+	defer func() { err = cosError("GetSignUploadURL", object, err) }()
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	if expire <= 0 {
+		expire = oneHour
+	}
+	upload = SignedUpload{
+		Method: http.MethodPut,
+		Header: cosDialect.native(parseHeaders(headers...)),
+	}
+	_, objHeader := cosPutHeader(headers...)
+	var u *url.URL
+	u, err = c.Client.Object.GetPresignedURL(ctx,
+		http.MethodPut, objectRel(object),
+		c.AccessKey, c.SecretKey,
+		time.Duration(expire)*time.Second, objHeader)
+	if err != nil {
+		return
+	}
+	upload.URL = u.String()
 	return
 }
 
+func (c *COS) PostPolicy(keyPrefix string, maxSize int64, contentTypes []string, expire int64) (form PostForm, err error) {
+	return c.PostPolicyContext(context.Background(), keyPrefix, maxSize, contentTypes, expire)
+}
+
+// https://cloud.tencent.com/document/product/436/14690
+func (c *COS) PostPolicyContext(ctx context.Context, keyPrefix string, maxSize int64, contentTypes []string, expire int64) (form PostForm, err error) {
+	defer func() { err = cosError("PostPolicy", keyPrefix, err) }()
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	if expire <= 0 {
+		expire = oneHour
+	}
+	now := time.Now().Unix()
+	keyTime := fmt.Sprintf("%d;%d", now, now+expire)
+	var p *postPolicy
+	if p, err = newPostPolicy(keyPrefix, maxSize, contentTypes, expire, false); err != nil {
+		return
+	}
+	p.add(map[string]string{"q-sign-algorithm": "sha1"})
+	p.add(map[string]string{"q-ak": c.AccessKey})
+	p.add(map[string]string{"q-sign-time": keyTime})
+
+	signKey := hmac.New(sha1.New, []byte(c.SecretKey))
+	signKey.Write([]byte(keyTime))
+	stringToSign := sha1.Sum(p.json())
+	signature := hmac.New(sha1.New, []byte(hex.EncodeToString(signKey.Sum(nil))))
+	signature.Write([]byte(hex.EncodeToString(stringToSign[:])))
+
+	form = PostForm{
+		URL:    c.Client.BaseURL.BucketURL.String(),
+		Fields: postFields(keyPrefix, contentTypes),
+	}
+	form.Fields["policy"] = p.base64()
+	form.Fields["q-sign-algorithm"] = "sha1"
+	form.Fields["q-ak"] = c.AccessKey
+	form.Fields["q-key-time"] = keyTime
+	form.Fields["q-signature"] = hex.EncodeToString(signature.Sum(nil))
+	return
+}
+
+func (c *COS) Download(object string, savePath string) (err error) {
+	return c.DownloadContext(context.Background(), object, savePath)
+}
+
+func (c *COS) DownloadContext(ctx context.Context, object string, savePath string) (err error) {
+	defer func() { err = cosError("Download", object, err) }()
+	// SDK 的 GetToFile 直接写入 savePath，失败时会留下不完整的文件，这里使用 Get 的数据流
+	var reader io.ReadCloser
+	reader, _, err = c.GetContext(ctx, object)
+	if err != nil {
+		return
+	}
+	defer reader.Close()
+	return saveTo(reader, savePath)
+}
+
 func (c *COS) GetInfo(object string) (info File, err error) {
+	return c.GetInfoContext(context.Background(), object)
+}
+
+func (c *COS) GetInfoContext(ctx context.Context, object string) (info File, err error) {
+	defer func() { err = cosError("GetInfo", object, err) }()
+	return c.getInfo(ctx, object, nil)
+}
+
+func (c *COS) GetInfoIf(object string, cond Conditions) (info File, err error) {
+	return c.GetInfoIfContext(context.Background(), object, cond)
+}
+
+func (c *COS) GetInfoIfContext(ctx context.Context, object string, cond Conditions) (info File, err error) {
+	defer func() { err = cosError("GetInfoIf", object, err) }()
+	header := cond.header()
+	return c.getInfo(ctx, object, &cos.ObjectHeadOptions{XOptionHeader: &header})
+}
+
+func (c *COS) getInfo(ctx context.Context, object string, opt *cos.ObjectHeadOptions) (info File, err error) {
 	var resp *cos.Response
 	path := objectRel(object)
-	resp, err = c.Client.Object.Get(context.Background(), path, nil)
+	// 使用 HEAD 请求，GET 请求的 gzip 内容会被 http.Transport 自动解压并去掉 Content-Encoding
+	resp, err = c.Client.Object.Head(ctx, path, opt)
 	if err != nil {
 		return
 	}
-	defer resp.Body.Close()
 	header := make(map[string]string)
 	for k, _ := range resp.Header {
 		header[k] = resp.Header.Get(k)
 	}
-	info = File{
-		Header: header,
-		Name:   path,
-	}
+	info = File{Name: path}
+	cosDialect.setHeader(&info, header)
 	info.ModTime, _ = time.Parse(http.TimeFormat, resp.Header.Get("Last-Modified"))
 	info.Size, _ = strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64)
-	info.IsDir = info.Size == 0
+	info.IsDir = isDirKey(path)
 	return
 }
 
 func (c *COS) Lists(prefix string) (files []File, err error) {
-	// TODO: 腾讯云的SDK中暂时没开放这个功能
+	return c.ListsContext(context.Background(), prefix)
+}
+
+func (c *COS) ListsContext(ctx context.Context, prefix string) (files []File, err error) {
+	defer func() { err = cosError("Lists", prefix, err) }()
+	return listAll(ctx, c, prefix)
+}
+
+func (c *COS) ListPage(prefix, marker string, limit int) (files []File, nextMarker string, err error) {
+	return c.ListPageContext(context.Background(), prefix, marker, limit)
+}
+
+func (c *COS) ListPageContext(ctx context.Context, prefix, marker string, limit int) (files []File, nextMarker string, err error) {
+	defer func() { err = cosError("ListPage", prefix, err) }()
+	return c.list(ctx, objectRel(prefix), "", marker, limit)
+}
+
+func (c *COS) ListDir(dir, marker string, limit int) (files []File, nextMarker string, err error) {
+	return c.ListDirContext(context.Background(), dir, marker, limit)
+}
+
+func (c *COS) ListDirContext(ctx context.Context, dir, marker string, limit int) (files []File, nextMarker string, err error) {
+	defer func() { err = cosError("ListDir", dir, err) }()
+	return c.list(ctx, dirPrefix(dir), delimiter, marker, limit)
+}
+
+// https://cloud.tencent.com/document/product/436/7734
+func (c *COS) list(ctx context.Context, prefix, delim, marker string, limit int) (files []File, nextMarker string, err error) {
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	var res *cos.BucketGetResult
+	res, _, err = c.Client.Bucket.Get(ctx, &cos.BucketGetOptions{
+		Prefix:    prefix,
+		Delimiter: delim,
+		Marker:    marker,
+		MaxKeys:   limit,
+	})
+	if err != nil {
+		return
+	}
+	for _, dir := range res.CommonPrefixes {
+		files = append(files, dirFile(dir))
+	}
+	for _, object := range res.Contents {
+		if delim != "" && object.Key == prefix {
+			continue
+		}
+		file := File{
+			Name:         objectRel(object.Key),
+			Size:         object.Size,
+			IsDir:        isDirKey(object.Key),
+			ETag:         strings.Trim(object.ETag, `"`),
+			StorageClass: cosDialect.canonicalStorageClass(object.StorageClass),
+		}
+		file.ModTime, _ = time.Parse(time.RFC3339, object.LastModified)
+		files = append(files, file)
+	}
+	if res.IsTruncated {
+		nextMarker = res.NextMarker
+		// 没有返回 NextMarker 时使用本页最后一个文件或者目录，文件和目录按字典序混合排列，取较大的一个
+		if nextMarker == "" {
+			if n := len(res.Contents); n > 0 {
+				nextMarker = res.Contents[n-1].Key
+			}
+			if n := len(res.CommonPrefixes); n > 0 && res.CommonPrefixes[n-1] > nextMarker {
+				nextMarker = res.CommonPrefixes[n-1]
+			}
+		}
+		if nextMarker == "" {
+			err = errors.New("list is truncated but no marker is returned")
+		}
+	}
 	return
 }
+
+// 将 SDK 返回的错误转换为 StoreError
+func cosError(op, object string, err error) error {
+	if keepError(err) {
+		return err
+	}
+	if e, ok := err.(*cos.ErrorResponse); ok {
+		var statusCode int
+		requestID := e.RequestID
+		if e.Response != nil {
+			statusCode = e.Response.StatusCode
+			if requestID == "" {
+				requestID = e.Response.Header.Get("X-Cos-Request-Id")
+			}
+		}
+		return newStoreError("cos", op, object, statusCode, e.Code, requestID, err)
+	}
+	return newStoreError("cos", op, object, 0, "", "", err)
+}
@@ -22,6 +22,10 @@ func init() {
 	appId := beego.AppConfig.String("cos::appId")
 	region := beego.AppConfig.String("cos::region")
 	domain := beego.AppConfig.String("cos::domain")
+	// 没有配置 cos 时跳过测试
+	if secretId == "" {
+		return
+	}
 	Cos, err = NewCOS(secretId, secretKey, bucket, appId, region, domain)
 	if err != nil {
 		panic(err)
@@ -29,6 +33,9 @@ func init() {
 }
 
 func TestCOS(t *testing.T) {
+	if Cos == nil {
+		t.Skip("cos is not configured")
+	}
 	// upload
 	t.Log("=====Upload=====", objectSVG, objectSVGGzip)
 	err = Cos.Upload(objectSVG, objectSVG,headerSVG)
@@ -70,6 +77,9 @@ func TestCOS(t *testing.T) {
 }
 
 func TestCOS_Delete(t *testing.T) {
+	if Cos == nil {
+		t.Skip("cos is not configured")
+	}
 	if err := Cos.Delete(objectSVG, objectSVGGzip); err != nil {
 		t.Error(err)
 	} else {
@@ -0,0 +1,167 @@
+package CloudStore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// 与具体云存储无关的错误类型，使用 errors.Is 判断，如：
+//
+//	if errors.Is(err, CloudStore.ErrNotExist) {
+//		...
+//	}
+var (
+	ErrNotExist   = errors.New("file is not exist")   // 文件不存在
+	ErrPermission = errors.New("permission denied")   // 没有权限，或者签名错误
+	ErrThrottled  = errors.New("request throttled")   // 请求过于频繁，被云存储限流
+	ErrExist      = errors.New("file already exists") // 只在文件不存在时上传（If-None-Match: *），但是文件已存在
+
+	ErrPreconditionFailed = errors.New("precondition failed") // If-Match 等条件不满足，ErrExist 也满足 errors.Is(err, ErrPreconditionFailed)
+	ErrNotModified        = errors.New("not modified")        // GetIf、GetInfoIf 的 If-None-Match、If-Modified-Since 条件不满足
+)
+
+// StoreError 为各云存储返回的错误，保留了原始错误以及请求的相关信息
+type StoreError struct {
+	Provider   string // 云存储，如 oss、cos
+	Op         string // 操作，如 GetInfo、Upload
+	Key        string // 文件
+	StatusCode int    // HTTP 状态码，没有的时候为 0
+	Code       string // 云存储返回的错误码，如 NoSuchKey
+	RequestID  string // 云存储返回的请求 ID
+	Err        error  // 原始错误
+	kind       error  // ErrNotExist、ErrPermission、ErrThrottled、ErrExist、ErrPreconditionFailed、ErrNotModified 或者 nil
+}
+
+func (e *StoreError) Error() string {
+	s := e.Provider + " " + e.Op
+	if e.Key != "" {
+		s += " " + e.Key
+	}
+	if e.StatusCode > 0 {
+		s += fmt.Sprintf(" (status: %v", e.StatusCode)
+		if e.RequestID != "" {
+			s += ", request id: " + e.RequestID
+		}
+		s += ")"
+	}
+	return s + ": " + e.Err.Error()
+}
+
+func (e *StoreError) Unwrap() error {
+	return e.Err
+}
+
+// Is 使 errors.Is(err, ErrNotExist) 等判断对所有云存储都生效；文件已存在是条件上传失败的一种
+func (e *StoreError) Is(target error) bool {
+	if e.kind == ErrExist && target == ErrPreconditionFailed {
+		return true
+	}
+	return e.kind != nil && e.kind == target
+}
+
+func newStoreError(provider, op, key string, statusCode int, code, requestID string, err error) *StoreError {
+	e := &StoreError{
+		Provider:   provider,
+		Op:         op,
+		Key:        key,
+		StatusCode: statusCode,
+		Code:       code,
+		RequestID:  requestID,
+		Err:        err,
+		kind:       errorKind(statusCode, code),
+	}
+	for _, kind := range []error{ErrNotExist, ErrPermission, ErrThrottled, ErrExist, ErrPreconditionFailed, ErrNotModified} {
+		if errors.Is(err, kind) {
+			e.kind = kind
+			break
+		}
+	}
+	return e
+}
+
+// 根据 HTTP 状态码以及云存储的错误码判断错误类型，各家云存储的错误码大多沿用 S3 的命名
+func errorKind(statusCode int, code string) error {
+	switch code {
+	case "NoSuchKey", "NoSuchFile", "NoSuchObject":
+		return ErrNotExist
+	case "AccessDenied", "AccessForbidden", "SignatureDoesNotMatch", "InvalidAccessKeyId":
+		return ErrPermission
+	case "SlowDown", "Throttling", "RequestLimitExceeded", "TooManyRequests":
+		return ErrThrottled
+	case "FileAlreadyExists":
+		return ErrExist
+	case "PreconditionFailed":
+		return ErrPreconditionFailed
+	case "NotModified":
+		return ErrNotModified
+	}
+	switch statusCode {
+	case http.StatusNotFound:
+		return ErrNotExist
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrPermission
+	case http.StatusTooManyRequests:
+		return ErrThrottled
+	case http.StatusPreconditionFailed:
+		return ErrPreconditionFailed
+	case http.StatusNotModified:
+		return ErrNotModified
+	}
+	return nil
+}
+
+// DeleteError 为批量删除时部分文件删除失败的错误，Errors 为各个文件的 *StoreError
+type DeleteError struct {
+	Errors []error
+}
+
+func (e *DeleteError) Error() string {
+	errs := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		errs = append(errs, err.Error())
+	}
+	return strings.Join(errs, "; ")
+}
+
+// Is 在所有文件的错误都是 target 时返回 true，如所有文件都没有权限删除
+func (e *DeleteError) Is(target error) bool {
+	for _, err := range e.Errors {
+		if !errors.Is(err, target) {
+			return false
+		}
+	}
+	return len(e.Errors) > 0
+}
+
+// As 使 errors.As 可以取得第一个符合的文件错误，如 *StoreError
+func (e *DeleteError) As(target interface{}) bool {
+	for _, err := range e.Errors {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// 没有失败的文件时返回 nil
+func deleteError(errs []error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return &DeleteError{Errors: errs}
+}
+
+// 不需要转换的错误：nil、已经是 StoreError 或 DeleteError、以及 ctx 取消或超时
+func keepError(err error) bool {
+	if err == nil {
+		return true
+	}
+	switch err.(type) {
+	case *StoreError, *DeleteError:
+		return true
+	}
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,299 @@
+package CloudStore
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Local 和 Memory 没有云存储的签名服务，链接使用 HMAC 签名，由 signedHandler 校验并提供访问
+
+var errTooLarge = errors.New("file too large")
+
+// HMAC-SHA256(secret, method + "\n" + object + "\n" + expires)
+func signObject(secret, method, object string, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%s\n%s\n%d", method, objectRel(object), expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func signQuery(secret, method, object string, expires int64) url.Values {
+	return url.Values{
+		"expires":   {strconv.FormatInt(expires, 10)},
+		"signature": {signObject(secret, method, object, expires)},
+	}
+}
+
+// 校验签名以及是否过期
+func verifySign(secret, method, object string, query url.Values) bool {
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return false
+	}
+	return hmac.Equal([]byte(query.Get("signature")), []byte(signObject(secret, method, object, expires)))
+}
+
+// 链接中的文件路径，对空格、#、? 等字符转义
+func escapedPath(object string) string {
+	return (&url.URL{Path: objectAbs(object)}).EscapedPath()
+}
+
+// expire 小于等于 0 时返回不带签名的链接
+func signURL(domain, secret, object string, expire int64) (link string) {
+	link = domain + escapedPath(object)
+	if expire > 0 {
+		link += "?" + signQuery(secret, http.MethodGet, object, time.Now().Unix()+expire).Encode()
+	}
+	return
+}
+
+// 自定义元数据以 X-Meta- 前缀的请求头上传，由 signedHandler 原样保存
+func signUploadURL(domain, secret, object string, expire int64, headers ...map[string]string) (upload SignedUpload) {
+	if expire <= 0 {
+		expire = oneHour
+	}
+	upload = SignedUpload{
+		Method: http.MethodPut,
+		URL:    domain + escapedPath(object) + "?" + signQuery(secret, http.MethodPut, object, time.Now().Unix()+expire).Encode(),
+		Header: parseHeaders(headers...).header(),
+	}
+	return
+}
+
+// 表单上传的 policy，由 signedHandler 自己校验，因此不需要使用 S3 风格的 conditions
+type formPolicy struct {
+	Expiration   int64    `json:"expiration"`
+	KeyPrefix    string   `json:"key_prefix"`
+	MaxSize      int64    `json:"max_size"`
+	ContentTypes []string `json:"content_types"`
+}
+
+func signPostPolicy(domain, secret, keyPrefix string, maxSize int64, contentTypes []string, expire int64) (form PostForm) {
+	if expire <= 0 {
+		expire = oneHour
+	}
+	policy := base64.StdEncoding.EncodeToString([]byte(toJSON(formPolicy{
+		Expiration:   time.Now().Unix() + expire,
+		KeyPrefix:    objectRel(keyPrefix),
+		MaxSize:      maxSize,
+		ContentTypes: contentTypes,
+	})))
+	form = PostForm{
+		URL:    domain + "/",
+		Fields: postFields(keyPrefix, contentTypes),
+	}
+	form.Fields["policy"] = policy
+	form.Fields["signature"] = signObject(secret, http.MethodPost, policy, 0)
+	return
+}
+
+func verifyFormPolicy(secret string, fields map[string]string) (policy formPolicy, err error) {
+	if !hmac.Equal([]byte(fields["signature"]), []byte(signObject(secret, http.MethodPost, fields["policy"], 0))) {
+		err = os.ErrPermission
+		return
+	}
+	var b []byte
+	b, err = base64.StdEncoding.DecodeString(fields["policy"])
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(b, &policy); err != nil {
+		return
+	}
+	if time.Now().Unix() > policy.Expiration {
+		err = os.ErrPermission
+	}
+	return
+}
+
+// signedHandler 校验 signURL、signUploadURL 以及 signPostPolicy 生成的签名，并通过 store 读写文件：
+// GET、HEAD 下载文件，需要签名（public 为 true 时不需要）；PUT 上传文件；POST 为表单上传
+type signedHandler struct {
+	store  CloudStoreContext
+	secret string
+	public bool
+	open   func(ctx context.Context, object string) (content io.ReadSeekCloser, info File, err error)
+}
+
+func (h *signedHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	object := objectRel(r.URL.Path)
+	var err error
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		if !h.public && !verifySign(h.secret, http.MethodGet, object, r.URL.Query()) {
+			err = ErrPermission
+			break
+		}
+		err = h.serveFile(w, r, object)
+	case http.MethodPut:
+		if !verifySign(h.secret, http.MethodPut, object, r.URL.Query()) {
+			err = ErrPermission
+			break
+		}
+		// 只保存标准 header、存储类型以及 X-Meta- 开头的自定义元数据
+		header := make(map[string]string)
+		for k := range r.Header {
+			if isStandardHeader(k) || k == HeaderStorageClass || strings.HasPrefix(k, MetaPrefix) {
+				header[k] = r.Header.Get(k)
+			}
+		}
+		err = h.store.PutContext(r.Context(), object, r.Body, r.ContentLength, header)
+	case http.MethodPost:
+		err = h.servePost(r)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if err != nil {
+		signedHTTPError(w, err)
+	}
+}
+
+// 使用 http.ServeContent 以支持 Range 和条件请求
+func (h *signedHandler) serveFile(w http.ResponseWriter, r *http.Request, object string) (err error) {
+	var (
+		content io.ReadSeekCloser
+		info    File
+	)
+	content, info, err = h.open(r.Context(), object)
+	if err != nil {
+		return
+	}
+	defer content.Close()
+
+	for k, v := range info.Header {
+		switch k {
+		case "Content-Length", "Last-Modified":
+		default:
+			w.Header().Set(k, v)
+		}
+	}
+	http.ServeContent(w, r, path.Base(object), info.ModTime, content)
+	return
+}
+
+// 表单上传：file 字段之前的字段为表单参数，校验 policy 之后直接将 file 字段的内容写入文件
+func (h *signedHandler) servePost(r *http.Request) (err error) {
+	var reader *multipart.Reader
+	reader, err = r.MultipartReader()
+	if err != nil {
+		return
+	}
+	fields := make(map[string]string)
+	for {
+		part, errPart := reader.NextPart()
+		if errPart != nil {
+			if errPart == io.EOF {
+				errPart = errors.New("missing file field")
+			}
+			return errPart
+		}
+		if part.FormName() != "file" {
+			b, errRead := ioutil.ReadAll(io.LimitReader(part, 1<<20))
+			if errRead != nil {
+				return errRead
+			}
+			fields[part.FormName()] = string(b)
+			continue
+		}
+
+		var policy formPolicy
+		if policy, err = verifyFormPolicy(h.secret, fields); err != nil {
+			return
+		}
+		object := strings.Replace(fields["key"], "${filename}", path.Base(part.FileName()), -1)
+		object = objectRel(path.Clean("/" + object))
+		if !strings.HasPrefix(object, policy.KeyPrefix) || isDirKey(object) {
+			return ErrPermission
+		}
+
+		contentType := fields["Content-Type"]
+		if contentType == "" {
+			contentType = part.Header.Get("Content-Type")
+		}
+		if !contentTypeAllowed(contentType, policy.ContentTypes) {
+			return ErrPermission
+		}
+		header := make(map[string]string)
+		if contentType != "" {
+			header["Content-Type"] = contentType
+		}
+
+		var file io.Reader = part
+		if policy.MaxSize > 0 {
+			file = &maxSizeReader{Reader: part, remain: policy.MaxSize}
+		}
+		return h.store.PutContext(r.Context(), object, file, -1, header)
+	}
+}
+
+func contentTypeAllowed(contentType string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	for _, t := range allowed {
+		if strings.EqualFold(mediaType, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// 超过 remain 字节时返回 errTooLarge，PutContext 会丢弃已写入的内容
+type maxSizeReader struct {
+	io.Reader
+	remain int64
+}
+
+func (r *maxSizeReader) Read(p []byte) (n int, err error) {
+	n, err = r.Reader.Read(p)
+	if r.remain -= int64(n); r.remain < 0 {
+		err = errTooLarge
+	}
+	return
+}
+
+func signedHTTPError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrNotExist):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrPermission):
+		status = http.StatusForbidden
+	case errors.Is(err, errTooLarge):
+		status = http.StatusRequestEntityTooLarge
+	}
+	http.Error(w, err.Error(), status)
+}
+
+// 将本地文件系统风格的错误（os.ErrNotExist 等）转换为 StoreError，供 Local 和 Memory 使用
+func fsError(provider, op, object string, err error) error {
+	if keepError(err) {
+		return err
+	}
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return newStoreError(provider, op, object, 0, "NoSuchKey", "", err)
+	case errors.Is(err, os.ErrPermission):
+		return newStoreError(provider, op, object, 0, "AccessDenied", "", err)
+	}
+	return newStoreError(provider, op, object, 0, "", "", err)
+}
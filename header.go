@@ -0,0 +1,282 @@
+package CloudStore
+
+import (
+	"net/http"
+	"strings"
+)
+
+// 上传时 headers 中可以使用的通用 header，名称不区分大小写，各云存储会映射到各自的字段或者请求头；
+// GetInfo、Get 返回的 File.Header 中的自定义元数据和存储类型也使用这里的名称
+const (
+	HeaderCacheControl       = "Cache-Control"
+	HeaderContentDisposition = "Content-Disposition"
+	HeaderContentEncoding    = "Content-Encoding"
+	HeaderContentLanguage    = "Content-Language"
+	HeaderContentType        = "Content-Type"
+	HeaderExpires            = "Expires"
+	HeaderContentMD5         = "Content-MD5"     // 文件内容 MD5 的 base64 编码，云存储会据此校验上传的内容
+	HeaderStorageClass       = "X-Storage-Class" // 存储类型，见 StorageStandard 等
+	HeaderACL                = "X-Acl"           // 文件的访问权限，见 ACLPrivate 等
+	HeaderIfMatch            = "If-Match"        // 只在文件的 ETag 与之相同时上传，否则返回 ErrPreconditionFailed
+	HeaderIfNoneMatch        = "If-None-Match"   // 为 "*" 时只在文件不存在时上传，文件已存在返回 ErrExist
+
+	// MetaPrefix 自定义元数据的前缀，如 X-Meta-Author，上传时也可以使用各云存储自己的前缀，如 x-oss-meta-
+	MetaPrefix = "X-Meta-"
+)
+
+// 通用的存储类型，各云存储会转换为自己的取值，其他的值原样传给云存储
+const (
+	StorageStandard = "STANDARD" // 标准存储
+	StorageIA       = "IA"       // 低频访问存储
+	StorageArchive  = "ARCHIVE"  // 归档存储
+)
+
+// 文件的访问权限，各云存储的取值相同
+const (
+	ACLPrivate         = "private"
+	ACLPublicRead      = "public-read"
+	ACLPublicReadWrite = "public-read-write"
+)
+
+// 各云存储都支持的标准 header
+var standardHeaders = []string{
+	HeaderCacheControl,
+	HeaderContentDisposition,
+	HeaderContentEncoding,
+	HeaderContentLanguage,
+	HeaderContentType,
+	HeaderExpires,
+}
+
+func isStandardHeader(k string) bool {
+	for _, h := range standardHeaders {
+		if strings.EqualFold(h, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// 上传时视为自定义元数据的前缀，其他不认识的 header 也作为自定义元数据
+var metaPrefixes = []string{"x-meta-", "x-oss-meta-", "x-cos-meta-", "x-amz-meta-", "x-obs-meta-", "x-bce-meta-", "x-upyun-meta-", "x-qn-meta-"}
+
+// Metadata 自定义元数据，名称不包含 X-Meta- 等前缀，上传时与其他 header 一起传入：
+//
+//	store.Put(object, reader, size, map[string]string{"Cache-Control": "max-age=3600"}, CloudStore.Metadata{"author": "TruthHun"}.Header())
+type Metadata map[string]string
+
+// Header 返回带上 X-Meta- 前缀的 header
+func (m Metadata) Header() map[string]string {
+	header := make(map[string]string, len(m))
+	for k, v := range m {
+		header[MetaPrefix+http.CanonicalHeaderKey(k)] = v
+	}
+	return header
+}
+
+// MetadataFromHeader 从 File.Header 中取出自定义元数据，名称为小写
+func MetadataFromHeader(header map[string]string) Metadata {
+	m := make(Metadata)
+	for k, v := range header {
+		if len(k) > len(MetaPrefix) && strings.EqualFold(k[:len(MetaPrefix)], MetaPrefix) {
+			m[strings.ToLower(k[len(MetaPrefix):])] = v
+		}
+	}
+	return m
+}
+
+// objectHeader 为解析后的上传 header，各云存储据此设置各自的字段
+type objectHeader struct {
+	CacheControl       string
+	ContentDisposition string
+	ContentEncoding    string
+	ContentLanguage    string
+	ContentType        string
+	Expires            string
+	ContentMD5         string
+	StorageClass       string
+	ACL                string
+	IfMatch            string
+	IfNoneMatch        string
+	Metadata           Metadata // 名称为小写
+}
+
+// 合并并解析上传时传入的 headers，后面的覆盖前面的；Content-Length 由 size 参数决定，ETag 由云存储计算，这里忽略
+func parseHeaders(headers ...map[string]string) (h objectHeader) {
+	h.Metadata = make(Metadata)
+	for _, header := range headers {
+		for k, v := range header {
+			switch lk := strings.ToLower(strings.TrimSpace(k)); lk {
+			case "cache-control":
+				h.CacheControl = v
+			case "content-disposition":
+				h.ContentDisposition = v
+			case "content-encoding":
+				h.ContentEncoding = v
+			case "content-language":
+				h.ContentLanguage = v
+			case "content-type":
+				h.ContentType = v
+			case "expires":
+				h.Expires = v
+			case "content-md5":
+				h.ContentMD5 = v
+			case "x-storage-class":
+				h.StorageClass = v
+			case "x-acl":
+				h.ACL = v
+			case "if-match":
+				h.IfMatch = v
+			case "if-none-match":
+				h.IfNoneMatch = v
+			case "content-length", "etag":
+			default:
+				for _, prefix := range metaPrefixes {
+					if strings.HasPrefix(lk, prefix) {
+						lk = lk[len(prefix):]
+						break
+					}
+				}
+				h.Metadata[lk] = v
+			}
+		}
+	}
+	return
+}
+
+// header 返回使用通用名称的 header，用于 Memory 和 Local 保存，它们不支持访问权限
+func (h objectHeader) header() map[string]string {
+	header := make(map[string]string)
+	for k, v := range map[string]string{
+		HeaderCacheControl:       h.CacheControl,
+		HeaderContentDisposition: h.ContentDisposition,
+		HeaderContentEncoding:    h.ContentEncoding,
+		HeaderContentLanguage:    h.ContentLanguage,
+		HeaderContentType:        h.ContentType,
+		HeaderExpires:            h.Expires,
+		HeaderContentMD5:         h.ContentMD5,
+		HeaderStorageClass:       h.StorageClass,
+	} {
+		if v != "" {
+			header[k] = v
+		}
+	}
+	for k, v := range h.Metadata.Header() {
+		header[k] = v
+	}
+	return header
+}
+
+// headerDialect 为各云存储请求头的差异
+type headerDialect struct {
+	metaPrefix     string            // 自定义元数据的前缀，小写
+	storageClass   string            // 存储类型的请求头，小写，为空表示不支持
+	acl            string            // 访问权限的请求头，小写，为空表示不支持
+	storageClasses map[string]string // 通用的存储类型 => 云存储的取值
+	versionID      string            // 版本 ID 的响应头，小写
+}
+
+var (
+	ossDialect = headerDialect{"x-oss-meta-", "x-oss-storage-class", "x-oss-object-acl",
+		map[string]string{StorageStandard: "Standard", StorageIA: "IA", StorageArchive: "Archive"}, "x-oss-version-id"}
+	cosDialect = headerDialect{"x-cos-meta-", "x-cos-storage-class", "x-cos-acl",
+		map[string]string{StorageStandard: "STANDARD", StorageIA: "STANDARD_IA", StorageArchive: "ARCHIVE"}, "x-cos-version-id"}
+	bosDialect = headerDialect{"x-bce-meta-", "x-bce-storage-class", "x-bce-acl",
+		map[string]string{StorageStandard: "STANDARD", StorageIA: "STANDARD_IA", StorageArchive: "ARCHIVE"}, "x-bce-version-id"}
+	obsDialect = headerDialect{"x-obs-meta-", "x-obs-storage-class", "x-obs-acl",
+		map[string]string{StorageStandard: "STANDARD", StorageIA: "WARM", StorageArchive: "COLD"}, "x-obs-version-id"}
+	minioDialect = headerDialect{"x-amz-meta-", "x-amz-storage-class", "x-amz-acl",
+		map[string]string{StorageStandard: "STANDARD", StorageIA: "STANDARD_IA", StorageArchive: "GLACIER"}, "x-amz-version-id"}
+	qiniuDialect = headerDialect{metaPrefix: "x-qn-meta-"}
+	upyunDialect = headerDialect{metaPrefix: "x-upyun-meta-"}
+	// Memory 和 Local 保存的已经是通用的 header
+	canonicalDialect = headerDialect{}
+)
+
+// 通用的存储类型转换为云存储的取值，其他的值原样返回
+func (d headerDialect) nativeStorageClass(class string) string {
+	if v, ok := d.storageClasses[strings.ToUpper(class)]; ok {
+		return v
+	}
+	return class
+}
+
+// 云存储的存储类型转换为通用的取值
+func (d headerDialect) canonicalStorageClass(class string) string {
+	for k, v := range d.storageClasses {
+		if strings.EqualFold(v, class) {
+			return k
+		}
+	}
+	return class
+}
+
+// native 返回云存储的请求头，用于签名上传以及 SDK 没有对应字段时直接设置请求头
+func (d headerDialect) native(h objectHeader) map[string]string {
+	header := make(map[string]string)
+	for k, v := range map[string]string{
+		HeaderCacheControl:       h.CacheControl,
+		HeaderContentDisposition: h.ContentDisposition,
+		HeaderContentEncoding:    h.ContentEncoding,
+		HeaderContentLanguage:    h.ContentLanguage,
+		HeaderContentType:        h.ContentType,
+		HeaderExpires:            h.Expires,
+		HeaderContentMD5:         h.ContentMD5,
+	} {
+		if v != "" {
+			header[k] = v
+		}
+	}
+	if h.StorageClass != "" && d.storageClass != "" {
+		header[d.storageClass] = d.nativeStorageClass(h.StorageClass)
+	}
+	if h.ACL != "" && d.acl != "" {
+		header[d.acl] = h.ACL
+	}
+	for k, v := range h.Metadata {
+		header[d.metaPrefix+k] = v
+	}
+	return header
+}
+
+// normalize 把云存储返回的 header 转换为通用的名称：自定义元数据使用 X-Meta- 前缀，存储类型使用 X-Storage-Class
+func (d headerDialect) normalize(header map[string]string) map[string]string {
+	normalized := make(map[string]string, len(header))
+	for k, v := range header {
+		lk := strings.ToLower(k)
+		switch {
+		case d.metaPrefix != "" && strings.HasPrefix(lk, d.metaPrefix):
+			normalized[MetaPrefix+http.CanonicalHeaderKey(lk[len(d.metaPrefix):])] = v
+		case d.storageClass != "" && lk == d.storageClass:
+			normalized[HeaderStorageClass] = d.canonicalStorageClass(v)
+		default:
+			normalized[k] = v
+		}
+	}
+	return normalized
+}
+
+// setHeader 把云存储返回的 header 转换为通用的名称后保存到 info.Header，并据此填充 ETag、ContentType 等字段
+func (d headerDialect) setHeader(info *File, header map[string]string) {
+	info.Header = d.normalize(header)
+	get := func(name string) string {
+		for k, v := range info.Header {
+			if strings.EqualFold(k, name) {
+				return v
+			}
+		}
+		return ""
+	}
+	info.ETag = strings.Trim(get("ETag"), `"`)
+	info.ContentType = get(HeaderContentType)
+	info.ContentEncoding = get(HeaderContentEncoding)
+	info.StorageClass = get(HeaderStorageClass)
+	// S3 风格的云存储对标准存储不返回存储类型
+	if info.StorageClass == "" && d.storageClass != "" {
+		info.StorageClass = StorageStandard
+	}
+	if d.versionID != "" {
+		info.VersionID = get(d.versionID)
+	}
+	info.Metadata = MetadataFromHeader(info.Header)
+}
@@ -0,0 +1,106 @@
+package CloudStore
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseHeaders(t *testing.T) {
+	h := parseHeaders(
+		map[string]string{"content-type": "text/plain", "Cache-Control": "no-cache", "Content-Length": "3"},
+		map[string]string{"X-Storage-Class": "ia", "X-Acl": ACLPublicRead, "Content-MD5": "DMF1ucDxtqgxw5niaXcmYQ=="},
+		map[string]string{"X-Meta-Author": "TruthHun", "x-oss-meta-page": "1", "book-id": "42"},
+		map[string]string{"Cache-Control": "max-age=60"},
+	)
+	want := objectHeader{
+		CacheControl: "max-age=60",
+		ContentType:  "text/plain",
+		ContentMD5:   "DMF1ucDxtqgxw5niaXcmYQ==",
+		StorageClass: "ia",
+		ACL:          ACLPublicRead,
+		Metadata:     Metadata{"author": "TruthHun", "page": "1", "book-id": "42"},
+	}
+	if !reflect.DeepEqual(h, want) {
+		t.Errorf("parseHeaders = %+v, want %+v", h, want)
+	}
+
+	native := cosDialect.native(h)
+	for k, v := range map[string]string{
+		"Cache-Control":       "max-age=60",
+		"x-cos-storage-class": "STANDARD_IA",
+		"x-cos-acl":           ACLPublicRead,
+		"x-cos-meta-author":   "TruthHun",
+	} {
+		if native[k] != v {
+			t.Errorf("cos native %v = %q, want %q", k, native[k], v)
+		}
+	}
+	if native := upyunDialect.native(h); native["x-upyun-meta-page"] != "1" || len(native) != 6 {
+		t.Errorf("upyun native = %v", native)
+	}
+
+	header := h.header()
+	if header["X-Storage-Class"] != "ia" || header["X-Meta-Book-Id"] != "42" || header["X-Acl"] != "" {
+		t.Errorf("header = %v", header)
+	}
+}
+
+func TestNormalizeHeader(t *testing.T) {
+	got := obsDialect.normalize(map[string]string{
+		"Content-Type":        "text/plain",
+		"X-Obs-Meta-Author":   "TruthHun",
+		"x-obs-storage-class": "COLD",
+		"X-Obs-Request-Id":    "1",
+	})
+	want := map[string]string{
+		"Content-Type":     "text/plain",
+		"X-Meta-Author":    "TruthHun",
+		"X-Storage-Class":  StorageArchive,
+		"X-Obs-Request-Id": "1",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("normalize = %v, want %v", got, want)
+	}
+	if m := MetadataFromHeader(got); !reflect.DeepEqual(m, Metadata{"author": "TruthHun"}) {
+		t.Errorf("MetadataFromHeader = %v", m)
+	}
+	if class := ossDialect.nativeStorageClass("GLACIER"); class != "GLACIER" {
+		t.Errorf("unknown storage class = %q", class)
+	}
+}
+
+func TestSetHeader(t *testing.T) {
+	var info File
+	cosDialect.setHeader(&info, map[string]string{
+		"Content-Type":      "text/plain",
+		"Content-Encoding":  "gzip",
+		"Etag":              `"0cc175b9c0f1b6a831c399e269772661"`,
+		"X-Cos-Meta-Author": "TruthHun",
+		"X-Cos-Version-Id":  "v1",
+	})
+	want := File{
+		Header: map[string]string{
+			"Content-Type":     "text/plain",
+			"Content-Encoding": "gzip",
+			"Etag":             `"0cc175b9c0f1b6a831c399e269772661"`,
+			"X-Meta-Author":    "TruthHun",
+			"X-Cos-Version-Id": "v1",
+		},
+		ETag:            "0cc175b9c0f1b6a831c399e269772661",
+		ContentType:     "text/plain",
+		ContentEncoding: "gzip",
+		StorageClass:    StorageStandard,
+		VersionID:       "v1",
+		Metadata:        Metadata{"author": "TruthHun"},
+	}
+	if !reflect.DeepEqual(info, want) {
+		t.Errorf("setHeader = %+v, want %+v", info, want)
+	}
+
+	// 不支持存储类型的云存储不返回默认值
+	info = File{}
+	upyunDialect.setHeader(&info, map[string]string{"Content-Type": "text/plain"})
+	if info.StorageClass != "" || info.ContentType != "text/plain" || len(info.Metadata) != 0 {
+		t.Errorf("upyun setHeader = %+v", info)
+	}
+}
@@ -1,6 +1,8 @@
 package CloudStore
 
 import (
+	"context"
+	"io"
 	"time"
 )
 
@@ -10,9 +12,33 @@ type File struct {
 	Size    int64
 	IsDir   bool
 	Header  map[string]string
+
+	ETag            string   // 不带引号；分片上传的对象和七牛的 hash 不是内容的 MD5
+	ContentType     string   // 列表接口中只有七牛返回
+	ContentEncoding string   // 列表接口中不返回
+	StorageClass    string   // 通用的存储类型，如 StorageStandard、StorageIA
+	VersionID       string   // 开启了多版本时对象的版本 ID，只在 GetInfo 中返回
+	Metadata        Metadata // 自定义元数据，名称为小写并且去掉了前缀，列表接口中不返回
+}
+
+// SignedUpload 为客户端直传文件所需的信息：使用 Method 向 URL 发送文件内容，并带上 Header 中的请求头；
+// Form 不为空时（七牛、又拍云）使用 multipart/form-data 表单上传，表单中除 Form 的字段外，文件内容放在 file 字段
+type SignedUpload struct {
+	Method string
+	URL    string
+	Header map[string]string
+	Form   map[string]string
+}
+
+// PostForm 为浏览器表单上传所需的信息：以 multipart/form-data 向 URL 提交 Fields 中的全部字段，
+// 文件内容放在最后的 file 字段中
+type PostForm struct {
+	URL    string
+	Fields map[string]string
 }
 
 type CloudStore interface {
+	CloudStoreContext
 	Delete(objects ...string) (err error)                                             // 删除文件
 	GetSignURL(object string, expire int64) (link string, err error)                  // 文件访问签名
 	IsExist(object string) (err error)                                                // 判断文件是否存在
@@ -20,4 +46,73 @@ type CloudStore interface {
 	Upload(tmpFile string, saveFile string, headers ...map[string]string) (err error) // 上传文件
 	Download(object string, savePath string) (err error)                              // 下载文件
 	GetInfo(object string) (info File, err error)                                     // 获取指定文件信息
+
+	// 以数据流的方式上传和下载文件，不需要经过本地临时文件。
+	// size 为 reader 中数据的字节数；Get 返回的 reader 使用完之后需要调用方 Close
+	Put(object string, reader io.Reader, size int64, headers ...map[string]string) (err error)
+	Get(object string) (reader io.ReadCloser, info File, err error)
+
+	// 条件下载和获取文件信息，cond 中的条件不满足时返回的错误满足 errors.Is(err, ErrNotModified) 或者
+	// errors.Is(err, ErrPreconditionFailed)，见 Conditions；上传时的条件见 HeaderIfMatch、HeaderIfNoneMatch
+	GetIf(object string, cond Conditions) (reader io.ReadCloser, info File, err error)
+	GetInfoIf(object string, cond Conditions) (info File, err error)
+
+	// 分页列出文件，marker 为上一页返回的 nextMarker，第一页传空字符串；
+	// limit 小于等于 0 时使用默认值，nextMarker 为空表示已经没有更多文件
+	ListPage(prefix, marker string, limit int) (files []File, nextMarker string, err error)
+
+	// 以 "/" 为分隔符分页列出一级目录下的文件和子目录，子目录的 IsDir 为 true
+	ListDir(dir, marker string, limit int) (files []File, nextMarker string, err error)
+
+	// 在云存储服务端复制和移动文件，不经过本地，文件的 header 和自定义元数据保持不变；
+	// dst 已存在时会被覆盖
+	Copy(src, dst string) (err error)
+	Move(src, dst string) (err error)
+
+	// 分片上传大文件，opts 可以设置分片大小和并发数；Upload 在文件大于 64MB 时也会自动使用分片上传
+	UploadMultipart(tmpFile, saveFile string, opts MultipartOptions, headers ...map[string]string) (err error)
+
+	// 客户端直传签名，expire 为有效期（秒），小于等于 0 时为 1 小时；
+	// headers 中的 Content-Type 等请求头会参与签名，客户端上传时需要带上返回的 Header
+	GetSignUploadURL(object string, expire int64, headers ...map[string]string) (upload SignedUpload, err error)
+
+	// 浏览器表单上传的签名，上传的文件名以 keyPrefix 开头，默认为 keyPrefix + 用户上传的文件名；
+	// maxSize 为文件大小上限（字节），contentTypes 为允许的文件类型，为 0 或者空时不限制；expire 同 GetSignUploadURL
+	PostPolicy(keyPrefix string, maxSize int64, contentTypes []string, expire int64) (form PostForm, err error)
 }
+
+// CloudStoreContext 与 CloudStore 中的方法一一对应，第一个参数为 context.Context，
+// ctx 被取消或者超时的时候，对云存储的调用会提前结束并返回 ctx.Err()
+type CloudStoreContext interface {
+	DeleteContext(ctx context.Context, objects ...string) (err error)
+	GetSignURLContext(ctx context.Context, object string, expire int64) (link string, err error)
+	IsExistContext(ctx context.Context, object string) (err error)
+	ListsContext(ctx context.Context, prefix string) (files []File, err error)
+	UploadContext(ctx context.Context, tmpFile string, saveFile string, headers ...map[string]string) (err error)
+	DownloadContext(ctx context.Context, object string, savePath string) (err error)
+	GetInfoContext(ctx context.Context, object string) (info File, err error)
+	PutContext(ctx context.Context, object string, reader io.Reader, size int64, headers ...map[string]string) (err error)
+	GetContext(ctx context.Context, object string) (reader io.ReadCloser, info File, err error)
+	GetIfContext(ctx context.Context, object string, cond Conditions) (reader io.ReadCloser, info File, err error)
+	GetInfoIfContext(ctx context.Context, object string, cond Conditions) (info File, err error)
+	ListPageContext(ctx context.Context, prefix, marker string, limit int) (files []File, nextMarker string, err error)
+	ListDirContext(ctx context.Context, dir, marker string, limit int) (files []File, nextMarker string, err error)
+	CopyContext(ctx context.Context, src, dst string) (err error)
+	MoveContext(ctx context.Context, src, dst string) (err error)
+	UploadMultipartContext(ctx context.Context, tmpFile, saveFile string, opts MultipartOptions, headers ...map[string]string) (err error)
+	GetSignUploadURLContext(ctx context.Context, object string, expire int64, headers ...map[string]string) (upload SignedUpload, err error)
+	PostPolicyContext(ctx context.Context, keyPrefix string, maxSize int64, contentTypes []string, expire int64) (form PostForm, err error)
+}
+
+var (
+	_ CloudStore = (*OSS)(nil)
+	_ CloudStore = (*BOS)(nil)
+	_ CloudStore = (*COS)(nil)
+	_ CloudStore = (*OBS)(nil)
+	_ CloudStore = (*UpYun)(nil)
+	_ CloudStore = (*QINIU)(nil)
+	_ CloudStore = (*MinIO)(nil)
+	_ CloudStore = (*Local)(nil)
+	_ CloudStore = (*Memory)(nil)
+	_ CloudStore = (*Mirror)(nil)
+)
@@ -0,0 +1,215 @@
+package fakes
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 百度云 BOS 的 REST API 与 S3 类似，但使用 x-bce- 前缀以及 JSON 格式的响应
+type bosServer struct {
+	bucket *bucket
+	name   string
+}
+
+// NewBOS 模拟百度云 BOS，SDK 使用 /bucket/object 形式的路径
+func NewBOS(bucketName string) *httptest.Server {
+	return httptest.NewServer(&bosServer{bucket: newBucket(), name: bucketName})
+}
+
+func (s *bosServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("x-bce-request-id", strconv.FormatInt(time.Now().UnixNano(), 36))
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	if key != s.name && !strings.HasPrefix(key, s.name+"/") {
+		s.error(w, r, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	key = strings.TrimPrefix(strings.TrimPrefix(key, s.name), "/")
+
+	query := r.URL.Query()
+	switch {
+	case key == "" && r.Method == http.MethodGet:
+		s.list(w, r)
+	case key == "" && r.Method == http.MethodPost && hasParam(query, "delete"):
+		s.deleteObjects(w, r)
+	case key == "" && r.Method == http.MethodHead:
+	case hasParam(query, "uploads") || hasParam(query, "uploadId"):
+		s.error(w, r, http.StatusNotImplemented, "NotImplemented")
+	case r.Method == http.MethodPut && r.Header.Get("x-bce-copy-source") != "":
+		s.copy(w, r, key)
+	case r.Method == http.MethodPut:
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			s.error(w, r, http.StatusBadRequest, "InvalidHTTPRequest")
+			return
+		}
+		// 与 BOS 一样校验 Content-MD5，预签名 URL 上传时可以不带
+		if sum := md5.Sum(data); r.Header.Get("Content-MD5") != "" && r.Header.Get("Content-MD5") != base64.StdEncoding.EncodeToString(sum[:]) {
+			s.error(w, r, http.StatusBadRequest, "BadDigest")
+			return
+		}
+		obj, ok := s.bucket.putIf(key, data, storedHeader(r, "x-bce-meta-"), r.Header.Get("If-Match"), r.Header.Get("If-None-Match"))
+		if !ok {
+			s.error(w, r, http.StatusPreconditionFailed, "PreconditionFailed")
+			return
+		}
+		w.Header().Set("ETag", `"`+obj.etag()+`"`)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		if !authorized(r) {
+			s.error(w, r, http.StatusForbidden, "AccessDenied")
+			return
+		}
+		obj, ok := s.bucket.get(key)
+		if !ok {
+			s.error(w, r, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		switch preconditionStatus(r, obj) {
+		case http.StatusPreconditionFailed:
+			s.error(w, r, http.StatusPreconditionFailed, "PreconditionFailed")
+			return
+		case http.StatusNotModified:
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		writeObjectHeader(w, obj)
+		if r.Method == http.MethodGet {
+			w.Write(obj.data)
+		}
+	case r.Method == http.MethodDelete:
+		if !s.bucket.delete(key) {
+			s.error(w, r, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		s.error(w, r, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(v)
+}
+
+func (s *bosServer) error(w http.ResponseWriter, r *http.Request, status int, code string) {
+	if r.Method == http.MethodHead {
+		w.WriteHeader(status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"code":      code,
+		"message":   code,
+		"requestId": w.Header().Get("x-bce-request-id"),
+	})
+}
+
+// 源文件为 /bucket/object，x-bce-metadata-directive 为 copy 或者 replace
+func (s *bosServer) copy(w http.ResponseWriter, r *http.Request, key string) {
+	src, _ := url.PathUnescape(r.Header.Get("x-bce-copy-source"))
+	src = strings.TrimPrefix(src, "/")
+	if i := strings.Index(src, "/"); i >= 0 {
+		src = src[i+1:]
+	}
+	var header http.Header
+	if strings.EqualFold(r.Header.Get("x-bce-metadata-directive"), "replace") {
+		header = storedHeader(r, "x-bce-meta-")
+	}
+	obj, ok := s.bucket.copy(src, key, header)
+	if !ok {
+		s.error(w, r, http.StatusNotFound, "NoSuchKey")
+		return
+	}
+	writeJSON(w, map[string]string{
+		"lastModified": obj.modTime.UTC().Format(time.RFC3339),
+		"eTag":         obj.etag(),
+	})
+}
+
+// 只返回删除失败的文件
+func (s *bosServer) deleteObjects(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Objects []struct {
+			Key string `json:"key"`
+		} `json:"objects"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.error(w, r, http.StatusBadRequest, "MalformedJSON")
+		return
+	}
+	type result struct {
+		Key     string `json:"key"`
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+	res := struct {
+		Errors []result `json:"errors"`
+	}{}
+	for _, obj := range req.Objects {
+		if !s.bucket.delete(obj.Key) {
+			res.Errors = append(res.Errors, result{Key: obj.Key, Code: "NoSuchKey", Message: "NoSuchKey"})
+		}
+	}
+	// 全部删除成功时没有响应内容
+	if len(res.Errors) == 0 {
+		return
+	}
+	writeJSON(w, res)
+}
+
+func (s *bosServer) list(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	type content struct {
+		Key          string `json:"key"`
+		LastModified string `json:"lastModified"`
+		ETag         string `json:"eTag"`
+		Size         int    `json:"size"`
+		StorageClass string `json:"storageClass"`
+	}
+	type prefix struct {
+		Prefix string `json:"prefix"`
+	}
+	res := struct {
+		Name           string    `json:"name"`
+		Prefix         string    `json:"prefix"`
+		Delimiter      string    `json:"delimiter"`
+		Marker         string    `json:"marker"`
+		NextMarker     string    `json:"nextMarker,omitempty"`
+		MaxKeys        int       `json:"maxKeys"`
+		IsTruncated    bool      `json:"isTruncated"`
+		Contents       []content `json:"contents"`
+		CommonPrefixes []prefix  `json:"commonPrefixes"`
+	}{
+		Name:      s.name,
+		Prefix:    query.Get("prefix"),
+		Delimiter: query.Get("delimiter"),
+		Marker:    query.Get("marker"),
+	}
+	res.MaxKeys, _ = strconv.Atoi(query.Get("maxKeys"))
+
+	var entries []entry
+	entries, res.IsTruncated, res.NextMarker = s.bucket.list(res.Prefix, res.Delimiter, res.Marker, res.MaxKeys)
+	for _, e := range entries {
+		if e.prefix != "" {
+			res.CommonPrefixes = append(res.CommonPrefixes, prefix{Prefix: e.prefix})
+			continue
+		}
+		res.Contents = append(res.Contents, content{
+			Key:          e.key,
+			LastModified: e.object.modTime.UTC().Format(time.RFC3339),
+			ETag:         e.object.etag(),
+			Size:         len(e.object.data),
+			StorageClass: e.object.storageClass(),
+		})
+	}
+	writeJSON(w, res)
+}
@@ -0,0 +1,239 @@
+// Package fakes 使用 httptest 模拟各云存储的 REST API，只实现 CloudStore 用到的接口，
+// 供 storetest 在不需要账号和网络的情况下测试各云存储的实现。
+//
+// 模拟服务不校验签名，但会拒绝没有签名的下载请求，并检查签名链接是否过期。
+package fakes
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"hash/crc64"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 模拟服务中保存的文件，header 为上传时的请求头（Content-Type 以及各云存储前缀的自定义元数据）
+type object struct {
+	data    []byte
+	header  http.Header
+	modTime time.Time
+}
+
+func (o *object) etag() string {
+	sum := md5.Sum(o.data)
+	return hex.EncodeToString(sum[:])
+}
+
+// 上传时设置的存储类型，列表接口中返回，默认为 STANDARD
+func (o *object) storageClass() string {
+	for k, v := range o.header {
+		if strings.HasSuffix(strings.ToLower(k), "-storage-class") && len(v) > 0 {
+			return v[0]
+		}
+	}
+	return "STANDARD"
+}
+
+// OSS 和 COS 的 SDK 会校验响应头中的 CRC64
+func (o *object) crc64() string {
+	return strconv.FormatUint(crc64.Checksum(o.data, crc64.MakeTable(crc64.ECMA)), 10)
+}
+
+// bucket 为并发安全的文件存储，所有模拟服务共用
+type bucket struct {
+	mu      sync.Mutex
+	objects map[string]*object
+}
+
+func newBucket() *bucket {
+	return &bucket{objects: make(map[string]*object)}
+}
+
+func (b *bucket) put(key string, data []byte, header http.Header) *object {
+	obj, _ := b.putIf(key, data, header, "", "")
+	return obj
+}
+
+// putIf 只在 If-Match、If-None-Match 满足时保存，对应云存储原生的条件上传，为空的条件不检查
+func (b *bucket) putIf(key string, data []byte, header http.Header, ifMatch, ifNoneMatch string) (obj *object, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	old, exist := b.objects[key]
+	if ifMatch != "" && (!exist || !matchETag(ifMatch, old.etag())) {
+		return nil, false
+	}
+	if ifNoneMatch != "" && exist && matchETag(ifNoneMatch, old.etag()) {
+		return nil, false
+	}
+	obj = &object{data: data, header: header, modTime: time.Now()}
+	b.objects[key] = obj
+	return obj, true
+}
+
+// insert 只在文件不存在时保存，对应 OSS、COS 的禁止覆盖
+func (b *bucket) insert(key string, data []byte, header http.Header) (obj *object, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, exist := b.objects[key]; exist {
+		return nil, false
+	}
+	obj = &object{data: data, header: header, modTime: time.Now()}
+	b.objects[key] = obj
+	return obj, true
+}
+
+func (b *bucket) get(key string) (obj *object, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	obj, ok = b.objects[key]
+	return
+}
+
+func (b *bucket) delete(key string) (ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	_, ok = b.objects[key]
+	delete(b.objects, key)
+	return
+}
+
+// header 为 nil 时保留源文件的 header
+func (b *bucket) copy(src, dst string, header http.Header) (obj *object, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	srcObj, ok := b.objects[src]
+	if !ok {
+		return
+	}
+	if header == nil {
+		header = srcObj.header.Clone()
+	}
+	obj = &object{data: srcObj.data, header: header, modTime: time.Now()}
+	b.objects[dst] = obj
+	return
+}
+
+// 列表中的一项，prefix 不为空时为 delimiter 分组之后的目录
+type entry struct {
+	key    string
+	object *object
+	prefix string
+}
+
+// list 按 S3 的语义列出 prefix 下大于 marker 的文件，delimiter 不为空时把下一级目录合并为一项；
+// 超过 max 项时 truncated 为 true，next 为最后一项的 key 或者目录
+func (b *bucket) list(prefix, delimiter, marker string, max int) (entries []entry, truncated bool, next string) {
+	b.mu.Lock()
+	var keys []string
+	for key := range b.objects {
+		if strings.HasPrefix(key, prefix) && key > marker {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	objects := make(map[string]*object, len(keys))
+	for _, key := range keys {
+		objects[key] = b.objects[key]
+	}
+	b.mu.Unlock()
+
+	if max <= 0 {
+		max = 1000
+	}
+	for _, key := range keys {
+		e := entry{key: key, object: objects[key]}
+		if delimiter != "" {
+			if i := strings.Index(key[len(prefix):], delimiter); i >= 0 {
+				p := key[:len(prefix)+i+len(delimiter)]
+				// marker 为目录时，跳过目录下的文件
+				if p <= marker || len(entries) > 0 && entries[len(entries)-1].prefix == p {
+					continue
+				}
+				e = entry{prefix: p}
+			}
+		}
+		if len(entries) == max {
+			truncated = true
+			break
+		}
+		entries = append(entries, e)
+	}
+	if truncated {
+		last := entries[len(entries)-1]
+		next = last.key + last.prefix
+	}
+	return
+}
+
+// 上传时需要保存的标准请求头
+var standardHeaders = []string{
+	"Cache-Control",
+	"Content-Disposition",
+	"Content-Encoding",
+	"Content-Language",
+	"Content-Type",
+	"Expires",
+}
+
+// 保存标准请求头、存储类型以及 metaPrefix 开头的自定义元数据
+func storedHeader(r *http.Request, metaPrefix string) http.Header {
+	header := make(http.Header)
+	for _, k := range standardHeaders {
+		if v := r.Header.Get(k); v != "" {
+			header.Set(k, v)
+		}
+	}
+	for k := range r.Header {
+		lk := strings.ToLower(k)
+		if metaPrefix != "" && strings.HasPrefix(lk, metaPrefix) || strings.HasSuffix(lk, "-storage-class") {
+			header.Set(k, r.Header.Get(k))
+		}
+	}
+	if header.Get("Content-Type") == "" {
+		header.Set("Content-Type", "application/octet-stream")
+	}
+	return header
+}
+
+// 下载时的响应头
+func writeObjectHeader(w http.ResponseWriter, obj *object) {
+	for k, v := range obj.header {
+		w.Header()[k] = v
+	}
+	w.Header().Set("Content-Length", itoa(int64(len(obj.data))))
+	w.Header().Set("Last-Modified", obj.modTime.UTC().Format(http.TimeFormat))
+	w.Header().Set("ETag", `"`+obj.etag()+`"`)
+}
+
+// 按照 RFC 7232 检查下载时的条件请求头，返回 412、304 或者 0（条件满足）
+func preconditionStatus(r *http.Request, obj *object) int {
+	modTime := obj.modTime.Truncate(time.Second)
+	if v := r.Header.Get("If-Match"); v != "" {
+		if !matchETag(v, obj.etag()) {
+			return http.StatusPreconditionFailed
+		}
+	} else if t, err := http.ParseTime(r.Header.Get("If-Unmodified-Since")); err == nil && modTime.After(t) {
+		return http.StatusPreconditionFailed
+	}
+	if v := r.Header.Get("If-None-Match"); v != "" {
+		if matchETag(v, obj.etag()) {
+			return http.StatusNotModified
+		}
+	} else if t, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !modTime.After(t) {
+		return http.StatusNotModified
+	}
+	return 0
+}
+
+func matchETag(etags, etag string) bool {
+	for _, e := range strings.Split(etags, ",") {
+		if e = strings.Trim(strings.TrimSpace(e), `"`); e == "*" || e == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,293 @@
+package fakes
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 七牛的 SDK 把空间查询（uc）、资源管理（rs、rsf）以及上传（up）发到不同的域名，
+// 测试时使用 Transport 把这些请求都转发到同一个模拟服务，下载则直接使用模拟服务的地址作为绑定域名
+type qiniuServer struct {
+	bucket *bucket
+	name   string
+	host   string // 模拟服务的地址，作为下载域名
+}
+
+// 返回给 SDK 的上传域名，由 Transport 转发到模拟服务
+const qiniuUpHost = "up.qiniu.fake"
+
+// NewQiniu 模拟七牛云存储，需要配合 Transport 替换 client.DefaultClient 的 Transport 使用
+func NewQiniu(bucketName string) *httptest.Server {
+	s := &qiniuServer{bucket: newBucket(), name: bucketName}
+	srv := httptest.NewServer(s)
+	s.host = srv.Listener.Addr().String()
+	return srv
+}
+
+type transport struct {
+	host string
+}
+
+// Transport 把所有请求转发到 srv，请求的 Host 保持不变
+func Transport(srv *httptest.Server) http.RoundTripper {
+	return &transport{host: srv.Listener.Addr().String()}
+}
+
+func (t *transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	req := r.Clone(r.Context())
+	req.URL.Scheme = "http"
+	req.URL.Host = t.host
+	req.Host = r.URL.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func (s *qiniuServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("X-Reqid", strconv.FormatInt(time.Now().UnixNano(), 36))
+	if r.Host == s.host {
+		s.download(w, r)
+		return
+	}
+	op := strings.Split(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	switch {
+	case r.URL.Path == "/v2/query":
+		writeJSON(w, map[string]interface{}{
+			"ttl": 86400,
+			"io":  map[string]map[string][]string{"src": {"main": {s.host}}},
+			"up":  map[string]map[string][]string{"src": {"main": {qiniuUpHost}}},
+		})
+	case r.URL.Path == "/batch":
+		s.batch(w, r)
+	case r.URL.Path == "/list":
+		s.list(w, r)
+	case r.URL.Path == "/" && r.Method == http.MethodPost:
+		s.upload(w, r)
+	default:
+		code, ret := s.call(op)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(ret)
+	}
+}
+
+type qiniuError struct {
+	Error string `json:"error"`
+}
+
+// 七牛使用自定义的状态码，612 为文件不存在，614 为文件已存在
+var (
+	errNoSuchFile = qiniuError{Error: "no such file or directory"}
+	errFileExists = qiniuError{Error: "file exists"}
+)
+
+// 资源管理的操作为 /stat/{entry}、/delete/{entry}、/copy/{src}/{dst}/force/true 以及 /move/...，
+// entry 为 URL 安全的 base64 编码的 bucket:key
+func (s *qiniuServer) call(op []string) (code int, ret interface{}) {
+	entry := func(i int) string {
+		if i >= len(op) {
+			return ""
+		}
+		b, _ := base64.URLEncoding.DecodeString(op[i])
+		return strings.TrimPrefix(string(b), s.name+":")
+	}
+	switch op[0] {
+	case "stat":
+		obj, ok := s.bucket.get(entry(1))
+		if !ok {
+			return 612, errNoSuchFile
+		}
+		meta := make(map[string]string)
+		for k, v := range obj.header {
+			if lk := strings.ToLower(k); strings.HasPrefix(lk, "x-qn-meta-") && len(v) > 0 {
+				meta[strings.TrimPrefix(lk, "x-qn-meta-")] = v[0]
+			}
+		}
+		return http.StatusOK, map[string]interface{}{
+			"fsize":     len(obj.data),
+			"hash":      obj.etag(),
+			"mimeType":  obj.header.Get("Content-Type"),
+			"putTime":   obj.modTime.UnixNano() / 100,
+			"type":      qiniuFileType(obj),
+			"x-qn-meta": meta,
+		}
+	case "delete":
+		if !s.bucket.delete(entry(1)) {
+			return 612, errNoSuchFile
+		}
+		return http.StatusOK, struct{}{}
+	case "copy", "move":
+		src, dst := entry(1), entry(2)
+		force := len(op) > 4 && op[3] == "force" && op[4] == "true"
+		if _, ok := s.bucket.get(dst); ok && !force {
+			return 614, errFileExists
+		}
+		if _, ok := s.bucket.copy(src, dst, nil); !ok {
+			return 612, errNoSuchFile
+		}
+		if op[0] == "move" {
+			s.bucket.delete(src)
+		}
+		return http.StatusOK, struct{}{}
+	}
+	return http.StatusNotFound, qiniuError{Error: "unknown operation"}
+}
+
+// 部分操作失败时返回 298
+func (s *qiniuServer) batch(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	type result struct {
+		Code int         `json:"code"`
+		Data interface{} `json:"data"`
+	}
+	var results []result
+	status := http.StatusOK
+	for _, op := range r.PostForm["op"] {
+		code, data := s.call(strings.Split(strings.TrimPrefix(op, "/"), "/"))
+		if code != http.StatusOK {
+			status = 298
+		}
+		results = append(results, result{Code: code, Data: data})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(results)
+}
+
+func (s *qiniuServer) list(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	prefix, delimiter := query.Get("prefix"), query.Get("delimiter")
+	entries, _, next := s.bucket.list(prefix, delimiter, query.Get("marker"), limit)
+
+	type item struct {
+		Key      string `json:"key"`
+		Hash     string `json:"hash"`
+		Fsize    int    `json:"fsize"`
+		PutTime  int64  `json:"putTime"`
+		MimeType string `json:"mimeType"`
+		Type     int    `json:"type"`
+	}
+	ret := struct {
+		Marker         string   `json:"marker,omitempty"`
+		Items          []item   `json:"items"`
+		CommonPrefixes []string `json:"commonPrefixes,omitempty"`
+	}{Marker: next, Items: []item{}}
+	for _, e := range entries {
+		if e.prefix != "" {
+			ret.CommonPrefixes = append(ret.CommonPrefixes, e.prefix)
+			continue
+		}
+		ret.Items = append(ret.Items, item{
+			Key:      e.key,
+			Hash:     e.object.etag(),
+			Fsize:    len(e.object.data),
+			PutTime:  e.object.modTime.UnixNano() / 100,
+			MimeType: e.object.header.Get("Content-Type"),
+			Type:     qiniuFileType(e.object),
+		})
+	}
+	writeJSON(w, ret)
+}
+
+// 上传时由上传凭证的 fileType 指定的存储类型
+const qiniuFileTypeHeader = "X-Qn-File-Type"
+
+func qiniuFileType(obj *object) int {
+	fileType, _ := strconv.Atoi(obj.header.Get(qiniuFileTypeHeader))
+	return fileType
+}
+
+type uploadPolicy struct {
+	Scope      string `json:"scope"`
+	FileType   int    `json:"fileType"`
+	InsertOnly int    `json:"insertOnly"`
+}
+
+// 上传凭证为 ak:sign:base64(policy)，scope 为 bucket 或者设置了 insertOnly 时不能覆盖已存在的文件
+func parseUploadToken(token string) (policy uploadPolicy) {
+	parts := strings.Split(token, ":")
+	if len(parts) != 3 {
+		return
+	}
+	b, err := base64.URLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return
+	}
+	json.Unmarshal(b, &policy)
+	return
+}
+
+func (s *qiniuServer) upload(w http.ResponseWriter, r *http.Request) {
+	fail := func(code int, e qiniuError) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(e)
+	}
+	file, fh, err := r.FormFile("file")
+	if err != nil {
+		fail(http.StatusBadRequest, qiniuError{Error: err.Error()})
+		return
+	}
+	defer file.Close()
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		fail(http.StatusBadRequest, qiniuError{Error: err.Error()})
+		return
+	}
+
+	key := r.FormValue("key")
+	policy := parseUploadToken(r.FormValue("token"))
+	scope := policy.Scope
+	if scope != s.name && scope != s.name+":"+key {
+		fail(http.StatusUnauthorized, qiniuError{Error: "bad token"})
+		return
+	}
+	if _, ok := s.bucket.get(key); ok && (scope == s.name || policy.InsertOnly != 0) {
+		fail(614, errFileExists)
+		return
+	}
+
+	// 没有指定 MimeType 时按文件名检测
+	header := make(http.Header)
+	contentType := fh.Header.Get("Content-Type")
+	if contentType == "" || contentType == "application/octet-stream" {
+		contentType = mime.TypeByExtension(path.Ext(key))
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	header.Set("Content-Type", contentType)
+	if policy.FileType != 0 {
+		header.Set(qiniuFileTypeHeader, strconv.Itoa(policy.FileType))
+	}
+	for k := range r.MultipartForm.Value {
+		if strings.HasPrefix(k, "x-qn-meta-") {
+			header.Set(k, r.FormValue(k))
+		}
+	}
+	obj := s.bucket.put(key, data, header)
+	writeJSON(w, map[string]string{"key": key, "hash": obj.etag()})
+}
+
+func (s *qiniuServer) download(w http.ResponseWriter, r *http.Request) {
+	if !authorized(r) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	obj, ok := s.bucket.get(strings.TrimPrefix(r.URL.Path, "/"))
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	writeObjectHeader(w, obj)
+	if r.Method != http.MethodHead {
+		w.Write(obj.data)
+	}
+}
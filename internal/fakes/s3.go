@@ -0,0 +1,319 @@
+package fakes
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OSS、COS、OBS 以及 MinIO 都使用 S3 风格的 REST API，区别在于扩展请求头的前缀以及访问 bucket 的方式
+type s3Server struct {
+	bucket    *bucket
+	name      string // bucket 名称，pathStyle 时为路径的第一段
+	prefix    string // 扩展请求头的前缀，如 x-oss-
+	pathStyle bool
+}
+
+// NewOSS 模拟阿里云 OSS，endpoint 为 IP 时 SDK 使用 /bucket/object 形式的路径
+func NewOSS(bucketName string) *httptest.Server {
+	return httptest.NewServer(&s3Server{bucket: newBucket(), name: bucketName, prefix: "x-oss-", pathStyle: true})
+}
+
+// NewCOS 模拟腾讯云 COS，使用时将 SDK 的 BucketURL 设置为模拟服务的地址
+func NewCOS() *httptest.Server {
+	return httptest.NewServer(&s3Server{bucket: newBucket(), prefix: "x-cos-"})
+}
+
+// NewOBS 模拟华为云 OBS，SDK 默认使用 V2 签名以及 x-amz- 前缀的请求头
+func NewOBS(bucketName string) *httptest.Server {
+	return httptest.NewServer(&s3Server{bucket: newBucket(), name: bucketName, prefix: "x-amz-", pathStyle: true})
+}
+
+// NewMinIO 模拟 MinIO
+func NewMinIO(bucketName string) *httptest.Server {
+	return httptest.NewServer(&s3Server{bucket: newBucket(), name: bucketName, prefix: "x-amz-", pathStyle: true})
+}
+
+func (s *s3Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set(s.prefix+"request-id", strconv.FormatInt(time.Now().UnixNano(), 36))
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	if s.pathStyle {
+		if key != s.name && !strings.HasPrefix(key, s.name+"/") {
+			s.error(w, r, http.StatusNotFound, "NoSuchBucket")
+			return
+		}
+		key = strings.TrimPrefix(strings.TrimPrefix(key, s.name), "/")
+	}
+
+	query := r.URL.Query()
+	switch {
+	case key == "" && r.Method == http.MethodGet && hasParam(query, "location"):
+		writeXML(w, struct {
+			XMLName xml.Name `xml:"LocationConstraint"`
+			Value   string   `xml:",chardata"`
+		}{Value: "us-east-1"})
+	case key == "" && r.Method == http.MethodGet:
+		s.list(w, r)
+	case key == "" && r.Method == http.MethodPost && hasParam(query, "delete"):
+		s.deleteObjects(w, r)
+	case key == "" && r.Method == http.MethodHead:
+	case hasParam(query, "uploads") || hasParam(query, "uploadId"):
+		s.error(w, r, http.StatusNotImplemented, "NotImplemented")
+	case r.Method == http.MethodPut && r.Header.Get(s.prefix+"copy-source") != "":
+		s.copy(w, r, key)
+	case r.Method == http.MethodPut:
+		data, err := ioutil.ReadAll(r.Body)
+		if err == nil && strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+			data, err = decodeChunked(data)
+		}
+		if err != nil {
+			s.error(w, r, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		var obj *object
+		if r.Header.Get(s.prefix+"forbid-overwrite") == "true" {
+			var ok bool
+			if obj, ok = s.bucket.insert(key, data, storedHeader(r, s.prefix+"meta-")); !ok {
+				s.error(w, r, http.StatusConflict, "FileAlreadyExists")
+				return
+			}
+		} else {
+			var ok bool
+			if obj, ok = s.bucket.putIf(key, data, storedHeader(r, s.prefix+"meta-"), r.Header.Get("If-Match"), r.Header.Get("If-None-Match")); !ok {
+				s.error(w, r, http.StatusPreconditionFailed, "PreconditionFailed")
+				return
+			}
+		}
+		w.Header().Set("ETag", `"`+obj.etag()+`"`)
+		w.Header().Set(s.prefix+"hash-crc64ecma", obj.crc64())
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		if !authorized(r) {
+			s.error(w, r, http.StatusForbidden, "AccessDenied")
+			return
+		}
+		obj, ok := s.bucket.get(key)
+		if !ok {
+			s.error(w, r, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		switch preconditionStatus(r, obj) {
+		case http.StatusPreconditionFailed:
+			s.error(w, r, http.StatusPreconditionFailed, "PreconditionFailed")
+			return
+		case http.StatusNotModified:
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		// OSS 的 GetObjectMeta 只返回基本信息
+		if hasParam(query, "objectMeta") {
+			w.Header().Set("Content-Length", strconv.Itoa(len(obj.data)))
+			w.Header().Set("Last-Modified", obj.modTime.UTC().Format(http.TimeFormat))
+			w.Header().Set("ETag", `"`+obj.etag()+`"`)
+			return
+		}
+		writeObjectHeader(w, obj)
+		w.Header().Set(s.prefix+"hash-crc64ecma", obj.crc64())
+		if r.Method == http.MethodGet {
+			w.Write(obj.data)
+		}
+	case r.Method == http.MethodDelete:
+		s.bucket.delete(key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		s.error(w, r, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+// MinIO 使用 aws-chunked 编码上传：{size 的十六进制};chunk-signature=...\r\n{data}\r\n
+func decodeChunked(body []byte) (data []byte, err error) {
+	for {
+		i := bytes.Index(body, []byte("\r\n"))
+		if i < 0 {
+			return nil, errors.New("malformed chunk")
+		}
+		sizeHex := string(body[:i])
+		if j := strings.Index(sizeHex, ";"); j >= 0 {
+			sizeHex = sizeHex[:j]
+		}
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil || int64(len(body)) < int64(i+2)+size {
+			return nil, errors.New("malformed chunk")
+		}
+		if size == 0 {
+			return data, nil
+		}
+		body = body[i+2:]
+		data = append(data, body[:size]...)
+		body = bytes.TrimPrefix(body[size:], []byte("\r\n"))
+	}
+}
+
+func hasParam(query url.Values, name string) bool {
+	_, ok := query[name]
+	return ok
+}
+
+func writeXML(w http.ResponseWriter, v interface{}) {
+	b, err := xml.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.Write([]byte(xml.Header))
+	w.Write(b)
+}
+
+type s3Error struct {
+	XMLName   xml.Name `xml:"Error"`
+	Code      string   `xml:"Code"`
+	Message   string   `xml:"Message"`
+	RequestID string   `xml:"RequestId"`
+}
+
+// HEAD 请求的错误没有响应内容
+func (s *s3Server) error(w http.ResponseWriter, r *http.Request, status int, code string) {
+	if r.Method == http.MethodHead {
+		w.WriteHeader(status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	b, _ := xml.Marshal(s3Error{Code: code, Message: code, RequestID: w.Header().Get(s.prefix + "request-id")})
+	w.Write(b)
+}
+
+// 源文件为 /bucket/object 或者 host/object 的形式
+func (s *s3Server) copy(w http.ResponseWriter, r *http.Request, key string) {
+	src, _ := url.PathUnescape(r.Header.Get(s.prefix + "copy-source"))
+	src = strings.TrimPrefix(src, "/")
+	if i := strings.Index(src, "/"); i >= 0 {
+		src = src[i+1:]
+	}
+	var header http.Header
+	if strings.EqualFold(r.Header.Get(s.prefix+"metadata-directive"), "REPLACE") {
+		header = storedHeader(r, s.prefix+"meta-")
+	}
+	obj, ok := s.bucket.copy(src, key, header)
+	if !ok {
+		s.error(w, r, http.StatusNotFound, "NoSuchKey")
+		return
+	}
+	writeXML(w, struct {
+		XMLName      xml.Name `xml:"CopyObjectResult"`
+		LastModified string   `xml:"LastModified"`
+		ETag         string   `xml:"ETag"`
+	}{LastModified: obj.modTime.UTC().Format(time.RFC3339), ETag: `"` + obj.etag() + `"`})
+}
+
+func (s *s3Server) deleteObjects(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Quiet   bool `xml:"Quiet"`
+		Objects []struct {
+			Key string `xml:"Key"`
+		} `xml:"Object"`
+	}
+	if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.error(w, r, http.StatusBadRequest, "MalformedXML")
+		return
+	}
+	type deleted struct {
+		Key string `xml:"Key"`
+	}
+	res := struct {
+		XMLName xml.Name  `xml:"DeleteResult"`
+		Deleted []deleted `xml:"Deleted"`
+	}{}
+	for _, obj := range req.Objects {
+		s.bucket.delete(obj.Key)
+		if !req.Quiet {
+			res.Deleted = append(res.Deleted, deleted{Key: obj.Key})
+		}
+	}
+	writeXML(w, res)
+}
+
+type s3Content struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+type s3Prefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+type s3ListResult struct {
+	XMLName               xml.Name    `xml:"ListBucketResult"`
+	Name                  string      `xml:"Name"`
+	Prefix                string      `xml:"Prefix"`
+	Marker                string      `xml:"Marker,omitempty"`
+	StartAfter            string      `xml:"StartAfter,omitempty"`
+	ContinuationToken     string      `xml:"ContinuationToken,omitempty"`
+	MaxKeys               int         `xml:"MaxKeys"`
+	Delimiter             string      `xml:"Delimiter,omitempty"`
+	IsTruncated           bool        `xml:"IsTruncated"`
+	NextMarker            string      `xml:"NextMarker,omitempty"`
+	NextContinuationToken string      `xml:"NextContinuationToken,omitempty"`
+	KeyCount              int         `xml:"KeyCount,omitempty"`
+	Contents              []s3Content `xml:"Contents"`
+	CommonPrefixes        []s3Prefix  `xml:"CommonPrefixes"`
+}
+
+// 同时支持 ListObjects（marker）以及 ListObjectsV2（list-type=2，continuation-token）
+func (s *s3Server) list(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	res := s3ListResult{
+		Name:      s.name,
+		Prefix:    query.Get("prefix"),
+		Delimiter: query.Get("delimiter"),
+	}
+	res.MaxKeys, _ = strconv.Atoi(query.Get("max-keys"))
+	marker := query.Get("marker")
+	v2 := query.Get("list-type") == "2"
+	if v2 {
+		res.StartAfter = query.Get("start-after")
+		res.ContinuationToken = query.Get("continuation-token")
+		marker = res.StartAfter
+		if res.ContinuationToken != "" {
+			marker = res.ContinuationToken
+		}
+	} else {
+		res.Marker = marker
+	}
+
+	var (
+		entries []entry
+		next    string
+	)
+	entries, res.IsTruncated, next = s.bucket.list(res.Prefix, res.Delimiter, marker, res.MaxKeys)
+	if v2 {
+		res.NextContinuationToken = next
+		res.KeyCount = len(entries)
+	} else {
+		res.NextMarker = next
+	}
+	for _, e := range entries {
+		if e.prefix != "" {
+			res.CommonPrefixes = append(res.CommonPrefixes, s3Prefix{Prefix: e.prefix})
+			continue
+		}
+		res.Contents = append(res.Contents, s3Content{
+			Key:          e.key,
+			LastModified: e.object.modTime.UTC().Format("2006-01-02T15:04:05.000Z"),
+			ETag:         `"` + e.object.etag() + `"`,
+			Size:         int64(len(e.object.data)),
+			StorageClass: e.object.storageClass(),
+		})
+	}
+	writeXML(w, res)
+}
@@ -0,0 +1,63 @@
+package fakes
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func itoa(i int64) string {
+	return strconv.FormatInt(i, 10)
+}
+
+// 从各云存储签名链接的参数中取出过期时间
+func signedExpiry(query url.Values) (expires time.Time, ok bool) {
+	switch {
+	case query.Get("Expires") != "": // OSS、OBS
+		return unixTime(query.Get("Expires"))
+	case query.Get("q-sign-time") != "": // COS: start;end
+		times := strings.Split(query.Get("q-sign-time"), ";")
+		return unixTime(times[len(times)-1])
+	case query.Get("X-Amz-Date") != "": // MinIO
+		date, err := time.Parse("20060102T150405Z", query.Get("X-Amz-Date"))
+		seconds, errExpires := strconv.ParseInt(query.Get("X-Amz-Expires"), 10, 64)
+		if err != nil || errExpires != nil {
+			return
+		}
+		return date.Add(time.Duration(seconds) * time.Second), true
+	case query.Get("authorization") != "": // BOS: bce-auth-v1/{ak}/{timestamp}/{expiration}/...
+		parts := strings.Split(query.Get("authorization"), "/")
+		if len(parts) < 4 {
+			return
+		}
+		date, err := time.Parse("2006-01-02T15:04:05Z", parts[2])
+		seconds, errExpires := strconv.ParseInt(parts[3], 10, 64)
+		if err != nil || errExpires != nil {
+			return
+		}
+		return date.Add(time.Duration(seconds) * time.Second), true
+	case query.Get("e") != "" && query.Get("token") != "": // 七牛
+		return unixTime(query.Get("e"))
+	case len(query.Get("_upt")) > 8: // 又拍云: 8 位签名 + 过期时间
+		return unixTime(query.Get("_upt")[8:])
+	}
+	return
+}
+
+func unixTime(s string) (t time.Time, ok bool) {
+	sec, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return
+	}
+	return time.Unix(sec, 0), true
+}
+
+// 模拟私有空间：下载需要 Authorization 请求头或者未过期的签名链接
+func authorized(r *http.Request) bool {
+	if expires, ok := signedExpiry(r.URL.Query()); ok {
+		return time.Now().Before(expires)
+	}
+	return r.Header.Get("Authorization") != ""
+}
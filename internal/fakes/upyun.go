@@ -0,0 +1,169 @@
+package fakes
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 又拍云 REST API 的请求 Host 固定为 v0.api.upyun.com，通过 UpYunConfig.Hosts 把请求发到模拟服务，
+// 下载则直接使用模拟服务的地址作为加速域名
+type upyunServer struct {
+	bucket *bucket
+	name   string
+	host   string
+}
+
+// 又拍云列表接口的迭代结束标志
+const upyunListEOF = "g2gCZAAEbmV4dGQAA2VvZg"
+
+// NewUpYun 模拟又拍云存储，文件夹由文件的路径隐式生成
+func NewUpYun(bucketName string) *httptest.Server {
+	s := &upyunServer{bucket: newBucket(), name: bucketName}
+	srv := httptest.NewServer(s)
+	s.host = srv.Listener.Addr().String()
+	return srv
+}
+
+func (s *upyunServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("X-Request-Id", strconv.FormatInt(time.Now().UnixNano(), 36))
+	if r.Host == s.host {
+		s.download(w, r)
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	if key != s.name && !strings.HasPrefix(key, s.name+"/") {
+		s.error(w, r, http.StatusNotFound, "bucket not exist")
+		return
+	}
+	key = strings.TrimPrefix(strings.TrimPrefix(key, s.name), "/")
+
+	switch r.Method {
+	case http.MethodPut:
+		s.put(w, r, key)
+	case http.MethodGet, http.MethodHead:
+		if obj, ok := s.bucket.get(key); ok {
+			w.Header().Set("x-upyun-file-type", "file")
+			w.Header().Set("x-upyun-file-size", itoa(int64(len(obj.data))))
+			w.Header().Set("x-upyun-file-date", itoa(obj.modTime.Unix()))
+			writeObjectHeader(w, obj)
+			if r.Method == http.MethodGet {
+				w.Write(obj.data)
+			}
+			return
+		}
+		s.folder(w, r, key)
+	case http.MethodDelete:
+		if !s.bucket.delete(key) {
+			s.error(w, r, http.StatusNotFound, "file or directory not found")
+		}
+	default:
+		s.error(w, r, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (s *upyunServer) error(w http.ResponseWriter, r *http.Request, status int, msg string) {
+	if r.Method == http.MethodHead {
+		w.WriteHeader(status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"msg":  msg,
+		"code": status*1000 + 1,
+		"id":   w.Header().Get("X-Request-Id"),
+	})
+}
+
+// 源文件为 /bucket/object
+func (s *upyunServer) put(w http.ResponseWriter, r *http.Request, key string) {
+	if r.Header.Get("X-Upyun-Multi-Stage") != "" {
+		s.error(w, r, http.StatusNotImplemented, "not implemented")
+		return
+	}
+	for _, h := range []string{"X-Upyun-Copy-Source", "X-Upyun-Move-Source"} {
+		src := r.Header.Get(h)
+		if src == "" {
+			continue
+		}
+		src, _ = url.PathUnescape(src)
+		src = strings.TrimPrefix(strings.TrimPrefix(src, "/"+s.name), "/")
+		if _, ok := s.bucket.copy(src, key, nil); !ok {
+			s.error(w, r, http.StatusNotFound, "file or directory not found")
+			return
+		}
+		if h == "X-Upyun-Move-Source" {
+			s.bucket.delete(src)
+		}
+		return
+	}
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		s.error(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	obj := s.bucket.put(key, data, storedHeader(r, "x-upyun-meta-"))
+	w.Header().Set("ETag", `"`+obj.etag()+`"`)
+}
+
+// 没有对应的文件时按文件夹处理，GET 返回文件夹下的文件和子文件夹，每行为：文件名\t类型(N 文件, F 文件夹)\t大小\t修改时间
+func (s *upyunServer) folder(w http.ResponseWriter, r *http.Request, key string) {
+	prefix := key
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	limit, _ := strconv.Atoi(r.Header.Get("X-List-Limit"))
+	iter := r.Header.Get("X-List-Iter")
+	if iter == upyunListEOF {
+		iter = "\xff"
+	}
+	entries, truncated, next := s.bucket.list(prefix, "/", iter, limit)
+	if len(entries) == 0 && iter == "" && prefix != "" {
+		s.error(w, r, http.StatusNotFound, "file or directory not found")
+		return
+	}
+
+	w.Header().Set("x-upyun-file-type", "folder")
+	w.Header().Set("x-upyun-file-date", itoa(time.Now().Unix()))
+	if !truncated {
+		next = upyunListEOF
+	}
+	w.Header().Set("x-upyun-list-iter", next)
+	if r.Method == http.MethodHead {
+		return
+	}
+	var lines []string
+	for _, e := range entries {
+		if e.prefix != "" {
+			lines = append(lines, fmt.Sprintf("%s\tF\t0\t%d", path.Base(e.prefix), time.Now().Unix()))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s\tN\t%d\t%d", path.Base(e.key), len(e.object.data), e.object.modTime.Unix()))
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte(strings.Join(lines, "\n")))
+}
+
+func (s *upyunServer) download(w http.ResponseWriter, r *http.Request) {
+	if !authorized(r) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	obj, ok := s.bucket.get(strings.TrimPrefix(r.URL.Path, "/"))
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	writeObjectHeader(w, obj)
+	if r.Method != http.MethodHead {
+		w.Write(obj.data)
+	}
+}
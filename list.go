@@ -0,0 +1,144 @@
+package CloudStore
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+)
+
+// 分页列出文件时，每页默认的数量
+const defaultListLimit = 1000
+
+// Iterator 通过 ListPageContext 逐页遍历 prefix 下的全部文件
+//
+//	it := NewIterator(ctx, store, "documents/", 0)
+//	for it.Next() {
+//		file := it.File()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type Iterator struct {
+	ctx    context.Context
+	store  CloudStoreContext
+	prefix string
+	limit  int
+	marker string
+	files  []File
+	file   File
+	done   bool
+	err    error
+}
+
+// limit 为每页的数量，小于等于 0 时使用默认值
+func NewIterator(ctx context.Context, store CloudStoreContext, prefix string, limit int) *Iterator {
+	return &Iterator{
+		ctx:    ctx,
+		store:  store,
+		prefix: prefix,
+		limit:  limit,
+	}
+}
+
+// Next 移动到下一个文件，没有更多文件或者出错的时候返回 false
+func (it *Iterator) Next() bool {
+	for len(it.files) == 0 {
+		if it.done || it.err != nil {
+			return false
+		}
+		it.files, it.marker, it.err = it.store.ListPageContext(it.ctx, it.prefix, it.marker, it.limit)
+		if it.err != nil {
+			return false
+		}
+		it.done = it.marker == ""
+	}
+	it.file, it.files = it.files[0], it.files[1:]
+	return true
+}
+
+// File 返回当前的文件
+func (it *Iterator) File() File {
+	return it.file
+}
+
+// Err 返回遍历过程中出现的错误
+func (it *Iterator) Err() error {
+	return it.err
+}
+
+// 列出 prefix 下的全部文件，供各云存储的 Lists 方法使用
+func listAll(ctx context.Context, store CloudStoreContext, prefix string) (files []File, err error) {
+	it := NewIterator(ctx, store, prefix, 0)
+	for it.Next() {
+		files = append(files, it.File())
+	}
+	err = it.Err()
+	return
+}
+
+// 列出目录时使用的分隔符
+const delimiter = "/"
+
+// 目录形式的 prefix，非空时统一以 "/" 结尾
+func dirPrefix(prefix string) string {
+	prefix = objectRel(prefix)
+	if prefix != "" && !strings.HasSuffix(prefix, delimiter) {
+		prefix += delimiter
+	}
+	return prefix
+}
+
+// 把列表接口返回的 common prefix 转换为目录，目录名不带结尾的 "/"
+func dirFile(prefix string) File {
+	return File{
+		Name:  strings.TrimSuffix(objectRel(prefix), delimiter),
+		IsDir: true,
+	}
+}
+
+// 以 "/" 结尾的对象是控制台创建文件夹时生成的占位对象
+func isDirKey(key string) bool {
+	return strings.HasSuffix(key, delimiter)
+}
+
+// 按 keys 排序 files，keys 为文件对应的 key，其中目录以 "/" 结尾
+type sortedFiles struct {
+	files []File
+	keys  []string
+}
+
+func (s sortedFiles) Len() int           { return len(s.files) }
+func (s sortedFiles) Less(i, j int) bool { return s.keys[i] < s.keys[j] }
+func (s sortedFiles) Swap(i, j int) {
+	s.files[i], s.files[j] = s.files[j], s.files[i]
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+}
+
+// 取已排序文件的第一页，还有更多文件时 nextMarker 为本页最后一个文件的 key
+func pageFiles(all []File, keys []string, limit int) (files []File, nextMarker string, err error) {
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	if len(all) > limit {
+		all = all[:limit]
+		nextMarker = keys[limit-1]
+	}
+	files = all
+	return
+}
+
+// 对于 SDK 没有提供 marker 的云存储，把分页状态编码为 marker
+func encodeMarker(v interface{}) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(toJSON(v)))
+}
+
+func decodeMarker(marker string, v interface{}) (err error) {
+	var b []byte
+	b, err = base64.RawURLEncoding.DecodeString(marker)
+	if err != nil {
+		return
+	}
+	return json.Unmarshal(b, v)
+}
@@ -0,0 +1,567 @@
+package CloudStore
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	localMetaSuffix = ".cloudstore-meta" // 保存文件 header 的 sidecar 文件后缀
+	localTempSuffix = ".cloudstore-tmp"  // 写入过程中的临时文件后缀
+)
+
+// Local 将文件保存在本地磁盘的 Root 目录下，用于开发和测试，不需要云存储的账号。
+// 文件的 header 以 JSON 格式保存在同目录下的 sidecar 文件（文件名 + .cloudstore-meta）中；
+// GetSignURL 等返回的链接使用 Secret 进行 HMAC 签名，由 Local 本身（http.Handler）校验并提供访问
+type Local struct {
+	Root   string // 文件保存的根目录
+	Domain string // 访问文件的链接前缀，如 http://localhost:8080/files，对应 Local 挂载的路径
+	Secret string // 签名密钥
+	Public bool   // 为 true 时，Local 作为 http.Handler 允许不带签名下载文件
+
+	lock sync.Mutex // 串行执行文件的替换、删除和移动，使条件上传的检查和重命名之间文件不会被修改
+}
+
+func NewLocal(root, domain, secret string) (l *Local, err error) {
+	root, err = filepath.Abs(root)
+	if err != nil {
+		return
+	}
+	if err = os.MkdirAll(root, os.ModePerm); err != nil {
+		return
+	}
+	l = &Local{
+		Root:   root,
+		Domain: strings.TrimRight(domain, "/"),
+		Secret: secret,
+	}
+	return
+}
+
+// 文件在本地磁盘的路径，path.Clean 保证路径不会超出 Root
+func (l *Local) file(object string) string {
+	return filepath.Join(l.Root, filepath.FromSlash(path.Clean("/"+objectRel(object))))
+}
+
+// 临时文件和 sidecar 文件不对外可见
+func isLocalInternal(name string) bool {
+	return strings.HasSuffix(name, localMetaSuffix) || strings.HasSuffix(name, localTempSuffix)
+}
+
+func (l *Local) IsExist(object string) (err error) {
+	return l.IsExistContext(context.Background(), object)
+}
+
+func (l *Local) IsExistContext(ctx context.Context, object string) (err error) {
+	defer func() { err = localError("IsExist", object, err) }()
+	_, err = l.GetInfoContext(ctx, object)
+	return
+}
+
+func (l *Local) Upload(tmpFile, saveFile string, headers ...map[string]string) (err error) {
+	return l.UploadContext(context.Background(), tmpFile, saveFile, headers...)
+}
+
+func (l *Local) UploadContext(ctx context.Context, tmpFile, saveFile string, headers ...map[string]string) (err error) {
+	defer func() { err = localError("Upload", saveFile, err) }()
+	var (
+		fp   *os.File
+		info os.FileInfo
+	)
+	fp, err = os.Open(tmpFile)
+	if err != nil {
+		return
+	}
+	defer fp.Close()
+
+	info, err = fp.Stat()
+	if err != nil {
+		return
+	}
+	return l.PutContext(ctx, saveFile, fp, info.Size(), headers...)
+}
+
+func (l *Local) Put(object string, reader io.Reader, size int64, headers ...map[string]string) (err error) {
+	return l.PutContext(context.Background(), object, reader, size, headers...)
+}
+
+// 先写入同目录下的临时文件再重命名，写入过程中其他请求读到的始终是完整的文件
+func (l *Local) PutContext(ctx context.Context, object string, reader io.Reader, size int64, headers ...map[string]string) (err error) {
+	defer func() { err = localError("Put", object, err) }()
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	if isLocalInternal(object) {
+		return fmt.Errorf("invalid object name: %v", object)
+	}
+	file := l.file(object)
+	if isDirKey(object) {
+		return os.MkdirAll(file, os.ModePerm)
+	}
+	if err = os.MkdirAll(filepath.Dir(file), os.ModePerm); err != nil {
+		return
+	}
+
+	var (
+		tmp *os.File
+		n   int64
+	)
+	hash := md5.New()
+	tmp, err = ioutil.TempFile(filepath.Dir(file), "*"+localTempSuffix)
+	if err != nil {
+		return
+	}
+	defer os.Remove(tmp.Name())
+
+	n, err = io.Copy(io.MultiWriter(tmp, hash), &contextReader{ctx: ctx, ReadCloser: ioutil.NopCloser(reader)})
+	if errClose := tmp.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		return
+	}
+	if size >= 0 && n != size {
+		return fmt.Errorf("size mismatch: expect %v bytes, got %v", size, n)
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	h := parseHeaders(headers...)
+	if err = checkUploadConditions(ctx, l.GetInfoContext, object, h); err != nil {
+		return
+	}
+	// 上传时计算 ETag，不是通过 Local 上传的文件没有 ETag
+	meta := h.header()
+	meta["ETag"] = hex.EncodeToString(hash.Sum(nil))
+	var b []byte
+	if b, err = json.Marshal(meta); err != nil {
+		return
+	}
+	// sidecar 文件先写入临时文件，文件内容重命名成功之后再替换，重命名失败时保留原来的 header
+	metaTmp := tmp.Name() + localMetaSuffix
+	defer os.Remove(metaTmp)
+	if err = ioutil.WriteFile(metaTmp, b, 0644); err != nil {
+		return
+	}
+	if err = os.Rename(tmp.Name(), file); err != nil {
+		return
+	}
+	return os.Rename(metaTmp, file+localMetaSuffix)
+}
+
+// header 为空时删除已有的 sidecar 文件
+func (l *Local) writeMeta(file string, header map[string]string) (err error) {
+	if len(header) == 0 {
+		err = os.Remove(file + localMetaSuffix)
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	var b []byte
+	b, err = json.Marshal(header)
+	if err != nil {
+		return
+	}
+	return ioutil.WriteFile(file+localMetaSuffix, b, 0644)
+}
+
+func (l *Local) readMeta(file string) (header map[string]string) {
+	header = make(map[string]string)
+	if b, err := ioutil.ReadFile(file + localMetaSuffix); err == nil {
+		json.Unmarshal(b, &header)
+	}
+	return
+}
+
+// 与云存储的 HEAD 请求保持一致，Header 中包含 Content-Type、Content-Length、Last-Modified 以及上传时设置的 header
+func (l *Local) fileInfo(object string, stat os.FileInfo) (info File) {
+	file := l.file(object)
+	header := make(http.Header)
+	for k, v := range l.readMeta(file) {
+		header.Set(k, v)
+	}
+	if header.Get("Content-Type") == "" {
+		contentType := mime.TypeByExtension(path.Ext(object))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		header.Set("Content-Type", contentType)
+	}
+	header.Set("Content-Length", strconv.FormatInt(stat.Size(), 10))
+	header.Set("Last-Modified", stat.ModTime().UTC().Format(http.TimeFormat))
+
+	info = fileFromHeader(objectRel(object), header, canonicalDialect)
+	info.ModTime = stat.ModTime()
+	if stat.IsDir() {
+		info.IsDir = true
+		info.Size = 0
+	}
+	return
+}
+
+func (l *Local) Get(object string) (reader io.ReadCloser, info File, err error) {
+	return l.GetContext(context.Background(), object)
+}
+
+func (l *Local) GetContext(ctx context.Context, object string) (reader io.ReadCloser, info File, err error) {
+	defer func() { err = localError("Get", object, err) }()
+	if info, err = l.GetInfoContext(ctx, object); err != nil {
+		return
+	}
+	if info.IsDir {
+		reader = ioutil.NopCloser(strings.NewReader(""))
+		return
+	}
+	var fp *os.File
+	fp, err = os.Open(l.file(object))
+	if err != nil {
+		return
+	}
+	reader = &contextReader{ctx: ctx, ReadCloser: fp}
+	return
+}
+
+func (l *Local) GetIf(object string, cond Conditions) (reader io.ReadCloser, info File, err error) {
+	return l.GetIfContext(context.Background(), object, cond)
+}
+
+// 在客户端检查条件
+func (l *Local) GetIfContext(ctx context.Context, object string, cond Conditions) (reader io.ReadCloser, info File, err error) {
+	defer func() { err = localError("GetIf", object, err) }()
+	return getIf(ctx, l.GetContext, object, cond)
+}
+
+func (l *Local) Download(object string, savePath string) (err error) {
+	return l.DownloadContext(context.Background(), object, savePath)
+}
+
+func (l *Local) DownloadContext(ctx context.Context, object string, savePath string) (err error) {
+	defer func() { err = localError("Download", object, err) }()
+	var reader io.ReadCloser
+	reader, _, err = l.GetContext(ctx, object)
+	if err != nil {
+		return
+	}
+	defer reader.Close()
+	return saveTo(reader, savePath)
+}
+
+func (l *Local) GetInfo(object string) (info File, err error) {
+	return l.GetInfoContext(context.Background(), object)
+}
+
+// 目录只有以 "/" 结尾时才能获取到，与云存储中的目录占位对象一致
+func (l *Local) GetInfoContext(ctx context.Context, object string) (info File, err error) {
+	defer func() { err = localError("GetInfo", object, err) }()
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	if isLocalInternal(object) {
+		return info, os.ErrNotExist
+	}
+	var stat os.FileInfo
+	stat, err = os.Stat(l.file(object))
+	if err != nil {
+		return
+	}
+	if stat.IsDir() != isDirKey(object) {
+		return info, os.ErrNotExist
+	}
+	info = l.fileInfo(object, stat)
+	return
+}
+
+func (l *Local) GetInfoIf(object string, cond Conditions) (info File, err error) {
+	return l.GetInfoIfContext(context.Background(), object, cond)
+}
+
+// 在客户端检查条件
+func (l *Local) GetInfoIfContext(ctx context.Context, object string, cond Conditions) (info File, err error) {
+	defer func() { err = localError("GetInfoIf", object, err) }()
+	return getInfoIf(ctx, l.GetInfoContext, object, cond)
+}
+
+func (l *Local) UploadMultipart(tmpFile, saveFile string, opts MultipartOptions, headers ...map[string]string) (err error) {
+	return l.UploadMultipartContext(context.Background(), tmpFile, saveFile, opts, headers...)
+}
+
+// 本地磁盘不需要分片，直接复制整个文件
+func (l *Local) UploadMultipartContext(ctx context.Context, tmpFile, saveFile string, opts MultipartOptions, headers ...map[string]string) (err error) {
+	defer func() { err = localError("UploadMultipart", saveFile, err) }()
+	return l.UploadContext(ctx, tmpFile, saveFile, headers...)
+}
+
+func (l *Local) Delete(objects ...string) (err error) {
+	return l.DeleteContext(context.Background(), objects...)
+}
+
+// 与云存储一致，删除不存在的文件不返回错误；删除之后清理空的上级目录
+func (l *Local) DeleteContext(ctx context.Context, objects ...string) (err error) {
+	defer func() { err = localError("Delete", strings.Join(objects, ", "), err) }()
+	for _, object := range objects {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		if err = l.delete(l.file(object)); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (l *Local) delete(file string) (err error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if err = os.Remove(file); err != nil && !os.IsNotExist(err) {
+		return
+	}
+	if err = l.writeMeta(file, nil); err != nil {
+		return
+	}
+	l.removeEmptyDirs(filepath.Dir(file))
+	return
+}
+
+// 云存储中没有真正的目录，文件删除或者移动之后，不再保留空的上级目录
+func (l *Local) removeEmptyDirs(dir string) {
+	for dir != l.Root && strings.HasPrefix(dir, l.Root) {
+		if os.Remove(dir) != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+func (l *Local) Copy(src, dst string) (err error) {
+	return l.CopyContext(context.Background(), src, dst)
+}
+
+func (l *Local) CopyContext(ctx context.Context, src, dst string) (err error) {
+	defer func() { err = localError("Copy", src, err) }()
+	var (
+		reader io.ReadCloser
+		info   File
+	)
+	reader, info, err = l.GetContext(ctx, src)
+	if err != nil {
+		return
+	}
+	defer reader.Close()
+	return l.PutContext(ctx, dst, reader, info.Size, l.readMeta(l.file(src)))
+}
+
+func (l *Local) Move(src, dst string) (err error) {
+	return l.MoveContext(context.Background(), src, dst)
+}
+
+// 文件和 sidecar 文件分别重命名，dst 已存在的 sidecar 文件会被覆盖或者删除
+func (l *Local) MoveContext(ctx context.Context, src, dst string) (err error) {
+	defer func() { err = localError("Move", src, err) }()
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if _, err = l.GetInfoContext(ctx, src); err != nil {
+		return
+	}
+	srcFile, dstFile := l.file(src), l.file(dst)
+	if srcFile == dstFile {
+		return
+	}
+	if err = os.MkdirAll(filepath.Dir(dstFile), os.ModePerm); err != nil {
+		return
+	}
+	// 先移动文件再写入元数据，移动失败时不会修改 dst 的元数据
+	meta := l.readMeta(srcFile)
+	if err = os.Rename(srcFile, dstFile); err != nil {
+		return
+	}
+	l.writeMeta(srcFile, nil)
+	if err = l.writeMeta(dstFile, meta); err != nil {
+		return
+	}
+	l.removeEmptyDirs(filepath.Dir(srcFile))
+	return
+}
+
+func (l *Local) GetSignURL(object string, expire int64) (link string, err error) {
+	return l.GetSignURLContext(context.Background(), object, expire)
+}
+
+// expire 小于等于 0 时返回不带签名的链接，只有 Public 为 true 时才能访问
+func (l *Local) GetSignURLContext(ctx context.Context, object string, expire int64) (link string, err error) {
+	defer func() { err = localError("GetSignURL", object, err) }()
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	link = signURL(l.Domain, l.Secret, object, expire)
+	return
+}
+
+func (l *Local) GetSignUploadURL(object string, expire int64, headers ...map[string]string) (upload SignedUpload, err error) {
+	return l.GetSignUploadURLContext(context.Background(), object, expire, headers...)
+}
+
+func (l *Local) GetSignUploadURLContext(ctx context.Context, object string, expire int64, headers ...map[string]string) (upload SignedUpload, err error) {
+	defer func() { err = localError("GetSignUploadURL", object, err) }()
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	upload = signUploadURL(l.Domain, l.Secret, object, expire, headers...)
+	return
+}
+
+func (l *Local) PostPolicy(keyPrefix string, maxSize int64, contentTypes []string, expire int64) (form PostForm, err error) {
+	return l.PostPolicyContext(context.Background(), keyPrefix, maxSize, contentTypes, expire)
+}
+
+func (l *Local) PostPolicyContext(ctx context.Context, keyPrefix string, maxSize int64, contentTypes []string, expire int64) (form PostForm, err error) {
+	defer func() { err = localError("PostPolicy", keyPrefix, err) }()
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	form = signPostPolicy(l.Domain, l.Secret, keyPrefix, maxSize, contentTypes, expire)
+	return
+}
+
+// ServeHTTP 使 Local 可以作为 http.Handler 提供文件访问，挂载的路径需要与 Domain 对应，如：
+//
+//	http.Handle("/files/", http.StripPrefix("/files", local))
+func (l *Local) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h := &signedHandler{
+		store:  l,
+		secret: l.Secret,
+		public: l.Public,
+		open:   l.open,
+	}
+	h.ServeHTTP(w, r)
+}
+
+func (l *Local) open(ctx context.Context, object string) (content io.ReadSeekCloser, info File, err error) {
+	if info, err = l.GetInfoContext(ctx, object); err != nil {
+		return
+	}
+	if info.IsDir {
+		err = localError("Get", object, os.ErrNotExist)
+		return
+	}
+	var fp *os.File
+	if fp, err = os.Open(l.file(object)); err != nil {
+		err = localError("Get", object, err)
+		return
+	}
+	content = fp
+	return
+}
+
+func (l *Local) Lists(prefix string) (files []File, err error) {
+	return l.ListsContext(context.Background(), prefix)
+}
+
+func (l *Local) ListsContext(ctx context.Context, prefix string) (files []File, err error) {
+	defer func() { err = localError("Lists", prefix, err) }()
+	return listAll(ctx, l, prefix)
+}
+
+func (l *Local) ListPage(prefix, marker string, limit int) (files []File, nextMarker string, err error) {
+	return l.ListPageContext(context.Background(), prefix, marker, limit)
+}
+
+// 与云存储一致，按文件名的字典序返回，marker 为上一页最后一个文件名
+func (l *Local) ListPageContext(ctx context.Context, prefix, marker string, limit int) (files []File, nextMarker string, err error) {
+	defer func() { err = localError("ListPage", prefix, err) }()
+	prefix = objectRel(prefix)
+	var (
+		all  []File
+		keys []string
+	)
+	base := l.file(prefix[:strings.LastIndex(prefix, delimiter)+1])
+	err = filepath.Walk(base, func(file string, stat os.FileInfo, e error) error {
+		if e != nil {
+			if os.IsNotExist(e) {
+				return nil
+			}
+			return e
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if stat.IsDir() || isLocalInternal(stat.Name()) {
+			return nil
+		}
+		rel, _ := filepath.Rel(l.Root, file)
+		if object := filepath.ToSlash(rel); strings.HasPrefix(object, prefix) && object > marker {
+			all = append(all, l.fileInfo(object, stat))
+			keys = append(keys, object)
+		}
+		return nil
+	})
+	if err != nil {
+		return
+	}
+	sort.Sort(sortedFiles{all, keys})
+	return pageFiles(all, keys, limit)
+}
+
+func (l *Local) ListDir(dir, marker string, limit int) (files []File, nextMarker string, err error) {
+	return l.ListDirContext(context.Background(), dir, marker, limit)
+}
+
+// 子目录以 "目录名/" 参与排序和分页，与云存储的 common prefix 一致
+func (l *Local) ListDirContext(ctx context.Context, dir, marker string, limit int) (files []File, nextMarker string, err error) {
+	defer func() { err = localError("ListDir", dir, err) }()
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	prefix := dirPrefix(dir)
+	var stats []os.FileInfo
+	stats, err = ioutil.ReadDir(l.file(prefix))
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	var (
+		all  []File
+		keys []string
+	)
+	for _, stat := range stats {
+		if isLocalInternal(stat.Name()) {
+			continue
+		}
+		var (
+			object = prefix + stat.Name()
+			file   File
+		)
+		if stat.IsDir() {
+			object += delimiter
+			file = dirFile(object)
+		} else {
+			file = l.fileInfo(object, stat)
+		}
+		if object > marker {
+			all = append(all, file)
+			keys = append(keys, object)
+		}
+	}
+	sort.Sort(sortedFiles{all, keys})
+	return pageFiles(all, keys, limit)
+}
+
+// 将本地文件系统的错误转换为 StoreError
+func localError(op, object string, err error) error {
+	return fsError("local", op, object, err)
+}
@@ -0,0 +1,101 @@
+package CloudStore
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// LogLevel 日志级别
+type LogLevel int
+
+const (
+	LevelDebug LogLevel = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	}
+	return fmt.Sprintf("level(%d)", int(l))
+}
+
+// Logger 结构化日志，keyvals 为交替出现的键和值，如 "op", "Upload", "key", "a.txt"，
+// 可以很方便地适配到 zap、logrus 等日志库
+type Logger interface {
+	Log(level LogLevel, msg string, keyvals ...interface{})
+}
+
+// LoggerFunc 使普通函数实现 Logger
+type LoggerFunc func(level LogLevel, msg string, keyvals ...interface{})
+
+func (f LoggerFunc) Log(level LogLevel, msg string, keyvals ...interface{}) {
+	f(level, msg, keyvals...)
+}
+
+type jsonLogger struct {
+	lock  sync.Mutex
+	w     io.Writer
+	level LogLevel
+}
+
+// NewJSONLogger 每条日志输出一行 JSON 到 w，低于 level 的日志会被忽略，并发安全
+func NewJSONLogger(w io.Writer, level LogLevel) Logger {
+	return &jsonLogger{w: w, level: level}
+}
+
+func (l *jsonLogger) Log(level LogLevel, msg string, keyvals ...interface{}) {
+	if level < l.level {
+		return
+	}
+	var buf bytes.Buffer
+	buf.WriteString(`{"time":`)
+	writeJSONValue(&buf, time.Now().Format(time.RFC3339Nano))
+	buf.WriteString(`,"level":`)
+	writeJSONValue(&buf, level.String())
+	buf.WriteString(`,"msg":`)
+	writeJSONValue(&buf, msg)
+	for i := 0; i < len(keyvals); i += 2 {
+		var v interface{} = "(MISSING)"
+		if i+1 < len(keyvals) {
+			v = keyvals[i+1]
+		}
+		buf.WriteByte(',')
+		writeJSONValue(&buf, fmt.Sprint(keyvals[i]))
+		buf.WriteByte(':')
+		writeJSONValue(&buf, v)
+	}
+	buf.WriteString("}\n")
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.w.Write(buf.Bytes())
+}
+
+// error 和 fmt.Stringer（如 time.Duration）输出为字符串，无法编码的值使用 fmt.Sprint
+func writeJSONValue(buf *bytes.Buffer, v interface{}) {
+	switch x := v.(type) {
+	case error:
+		v = x.Error()
+	case fmt.Stringer:
+		v = x.String()
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		b, _ = json.Marshal(fmt.Sprint(v))
+	}
+	buf.Write(b)
+}
@@ -0,0 +1,26 @@
+package CloudStore
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/TruthHun/CloudStore/obs"
+)
+
+func TestSetOBSLogger(t *testing.T) {
+	var logs bytes.Buffer
+	SetOBSLogger(NewJSONLogger(&logs, LevelDebug), obs.LEVEL_WARN)
+	defer SetOBSLogger(nil, obs.LEVEL_OFF)
+
+	obs.DoLog(obs.LEVEL_INFO, "ignored")
+	obs.DoLog(obs.LEVEL_ERROR, "request failed: %v", 500)
+	var entry map[string]interface{}
+	if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+		t.Fatalf("logs = %v: %v", logs.String(), err)
+	}
+	if entry["level"] != "error" || !strings.Contains(entry["msg"].(string), "request failed: 500") || entry["driver"] != "obs" {
+		t.Errorf("log = %v", logs.String())
+	}
+}
@@ -0,0 +1,441 @@
+package CloudStore
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Memory 将文件保存在内存中，并发安全，用于单元测试，不需要云存储的账号和网络。
+// 签名链接与 Local 相同，使用 Secret 进行 HMAC 签名，由 Memory 本身（http.Handler）校验并提供访问
+type Memory struct {
+	Domain string // 访问文件的链接前缀，如 httptest.Server 的 URL
+	Secret string // 签名密钥
+	Public bool   // 为 true 时，Memory 作为 http.Handler 允许不带签名下载文件
+
+	lock    sync.RWMutex
+	objects map[string]memoryObject
+}
+
+type memoryObject struct {
+	data    []byte
+	header  map[string]string
+	modTime time.Time
+}
+
+func NewMemory(domain, secret string) *Memory {
+	return &Memory{
+		Domain:  strings.TrimRight(domain, "/"),
+		Secret:  secret,
+		objects: make(map[string]memoryObject),
+	}
+}
+
+func (m *Memory) load(object string) (obj memoryObject, ok bool) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	obj, ok = m.objects[objectRel(object)]
+	return
+}
+
+// store 在同一个锁内检查上传的 If-Match、If-None-Match 并保存，没有竞争
+func (m *Memory) store(object string, obj memoryObject, h objectHeader) (err error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	getInfo := func(ctx context.Context, object string) (File, error) {
+		old, ok := m.objects[objectRel(object)]
+		if !ok {
+			return File{}, memoryError("GetInfo", object, os.ErrNotExist)
+		}
+		return old.info(objectRel(object)), nil
+	}
+	if err = checkUploadConditions(context.Background(), getInfo, object, h); err != nil {
+		return
+	}
+	if m.objects == nil {
+		m.objects = make(map[string]memoryObject)
+	}
+	m.objects[objectRel(object)] = obj
+	return
+}
+
+// 与云存储的 HEAD 请求保持一致，Header 中包含 Content-Type、Content-Length、Last-Modified 以及上传时设置的 header
+func (obj memoryObject) info(object string) (info File) {
+	header := make(http.Header)
+	for k, v := range obj.header {
+		header.Set(k, v)
+	}
+	if header.Get("Content-Type") == "" {
+		contentType := mime.TypeByExtension(path.Ext(object))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		header.Set("Content-Type", contentType)
+	}
+	header.Set("Content-Length", strconv.Itoa(len(obj.data)))
+	header.Set("Last-Modified", obj.modTime.UTC().Format(http.TimeFormat))
+
+	header.Set("ETag", fmt.Sprintf("%x", md5.Sum(obj.data)))
+
+	info = fileFromHeader(object, header, canonicalDialect)
+	info.ModTime = obj.modTime
+	info.IsDir = isDirKey(object)
+	return
+}
+
+func (m *Memory) IsExist(object string) (err error) {
+	return m.IsExistContext(context.Background(), object)
+}
+
+func (m *Memory) IsExistContext(ctx context.Context, object string) (err error) {
+	defer func() { err = memoryError("IsExist", object, err) }()
+	_, err = m.GetInfoContext(ctx, object)
+	return
+}
+
+func (m *Memory) Upload(tmpFile, saveFile string, headers ...map[string]string) (err error) {
+	return m.UploadContext(context.Background(), tmpFile, saveFile, headers...)
+}
+
+func (m *Memory) UploadContext(ctx context.Context, tmpFile, saveFile string, headers ...map[string]string) (err error) {
+	defer func() { err = memoryError("Upload", saveFile, err) }()
+	var (
+		fp   *os.File
+		info os.FileInfo
+	)
+	fp, err = os.Open(tmpFile)
+	if err != nil {
+		return
+	}
+	defer fp.Close()
+
+	info, err = fp.Stat()
+	if err != nil {
+		return
+	}
+	return m.PutContext(ctx, saveFile, fp, info.Size(), headers...)
+}
+
+func (m *Memory) Put(object string, reader io.Reader, size int64, headers ...map[string]string) (err error) {
+	return m.PutContext(context.Background(), object, reader, size, headers...)
+}
+
+func (m *Memory) PutContext(ctx context.Context, object string, reader io.Reader, size int64, headers ...map[string]string) (err error) {
+	defer func() { err = memoryError("Put", object, err) }()
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	var data []byte
+	data, err = ioutil.ReadAll(&contextReader{ctx: ctx, ReadCloser: ioutil.NopCloser(reader)})
+	if err != nil {
+		return
+	}
+	if size >= 0 && int64(len(data)) != size {
+		return fmt.Errorf("size mismatch: expect %v bytes, got %v", size, len(data))
+	}
+	h := parseHeaders(headers...)
+	return m.store(object, memoryObject{
+		data:    data,
+		header:  h.header(),
+		modTime: time.Now(),
+	}, h)
+}
+
+func (m *Memory) Get(object string) (reader io.ReadCloser, info File, err error) {
+	return m.GetContext(context.Background(), object)
+}
+
+func (m *Memory) GetContext(ctx context.Context, object string) (reader io.ReadCloser, info File, err error) {
+	defer func() { err = memoryError("Get", object, err) }()
+	var content io.ReadSeekCloser
+	content, info, err = m.open(ctx, object)
+	if err != nil {
+		return
+	}
+	reader = &contextReader{ctx: ctx, ReadCloser: content}
+	return
+}
+
+func (m *Memory) GetIf(object string, cond Conditions) (reader io.ReadCloser, info File, err error) {
+	return m.GetIfContext(context.Background(), object, cond)
+}
+
+func (m *Memory) GetIfContext(ctx context.Context, object string, cond Conditions) (reader io.ReadCloser, info File, err error) {
+	defer func() { err = memoryError("GetIf", object, err) }()
+	return getIf(ctx, m.GetContext, object, cond)
+}
+
+// 文件内容写入之后不再修改，读取时不需要复制
+type memoryReader struct {
+	*bytes.Reader
+}
+
+func (memoryReader) Close() error {
+	return nil
+}
+
+func (m *Memory) open(ctx context.Context, object string) (content io.ReadSeekCloser, info File, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	obj, ok := m.load(object)
+	if !ok {
+		err = memoryError("Get", object, os.ErrNotExist)
+		return
+	}
+	content = memoryReader{bytes.NewReader(obj.data)}
+	info = obj.info(objectRel(object))
+	return
+}
+
+func (m *Memory) Download(object string, savePath string) (err error) {
+	return m.DownloadContext(context.Background(), object, savePath)
+}
+
+func (m *Memory) DownloadContext(ctx context.Context, object string, savePath string) (err error) {
+	defer func() { err = memoryError("Download", object, err) }()
+	var reader io.ReadCloser
+	reader, _, err = m.GetContext(ctx, object)
+	if err != nil {
+		return
+	}
+	defer reader.Close()
+	return saveTo(reader, savePath)
+}
+
+func (m *Memory) GetInfo(object string) (info File, err error) {
+	return m.GetInfoContext(context.Background(), object)
+}
+
+func (m *Memory) GetInfoContext(ctx context.Context, object string) (info File, err error) {
+	defer func() { err = memoryError("GetInfo", object, err) }()
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	obj, ok := m.load(object)
+	if !ok {
+		return info, os.ErrNotExist
+	}
+	info = obj.info(objectRel(object))
+	return
+}
+
+func (m *Memory) GetInfoIf(object string, cond Conditions) (info File, err error) {
+	return m.GetInfoIfContext(context.Background(), object, cond)
+}
+
+func (m *Memory) GetInfoIfContext(ctx context.Context, object string, cond Conditions) (info File, err error) {
+	defer func() { err = memoryError("GetInfoIf", object, err) }()
+	return getInfoIf(ctx, m.GetInfoContext, object, cond)
+}
+
+func (m *Memory) UploadMultipart(tmpFile, saveFile string, opts MultipartOptions, headers ...map[string]string) (err error) {
+	return m.UploadMultipartContext(context.Background(), tmpFile, saveFile, opts, headers...)
+}
+
+// 内存中不需要分片，直接读取整个文件
+func (m *Memory) UploadMultipartContext(ctx context.Context, tmpFile, saveFile string, opts MultipartOptions, headers ...map[string]string) (err error) {
+	defer func() { err = memoryError("UploadMultipart", saveFile, err) }()
+	return m.UploadContext(ctx, tmpFile, saveFile, headers...)
+}
+
+func (m *Memory) Delete(objects ...string) (err error) {
+	return m.DeleteContext(context.Background(), objects...)
+}
+
+// 与云存储一致，删除不存在的文件不返回错误
+func (m *Memory) DeleteContext(ctx context.Context, objects ...string) (err error) {
+	defer func() { err = memoryError("Delete", strings.Join(objects, ", "), err) }()
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for _, object := range objects {
+		delete(m.objects, objectRel(object))
+	}
+	return
+}
+
+func (m *Memory) Copy(src, dst string) (err error) {
+	return m.CopyContext(context.Background(), src, dst)
+}
+
+func (m *Memory) CopyContext(ctx context.Context, src, dst string) (err error) {
+	defer func() { err = memoryError("Copy", src, err) }()
+	return m.copy(ctx, src, dst, false)
+}
+
+func (m *Memory) Move(src, dst string) (err error) {
+	return m.MoveContext(context.Background(), src, dst)
+}
+
+// 在同一个锁内复制和删除，Memory 的移动是原子操作
+func (m *Memory) MoveContext(ctx context.Context, src, dst string) (err error) {
+	defer func() { err = memoryError("Move", src, err) }()
+	return m.copy(ctx, src, dst, true)
+}
+
+func (m *Memory) copy(ctx context.Context, src, dst string, move bool) (err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	src, dst = objectRel(src), objectRel(dst)
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	obj, ok := m.objects[src]
+	if !ok {
+		return os.ErrNotExist
+	}
+	obj.header = mergeHeaders(obj.header)
+	obj.modTime = time.Now()
+	m.objects[dst] = obj
+	if move && src != dst {
+		delete(m.objects, src)
+	}
+	return
+}
+
+func (m *Memory) GetSignURL(object string, expire int64) (link string, err error) {
+	return m.GetSignURLContext(context.Background(), object, expire)
+}
+
+// expire 小于等于 0 时返回不带签名的链接，只有 Public 为 true 时才能访问
+func (m *Memory) GetSignURLContext(ctx context.Context, object string, expire int64) (link string, err error) {
+	defer func() { err = memoryError("GetSignURL", object, err) }()
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	link = signURL(m.Domain, m.Secret, object, expire)
+	return
+}
+
+func (m *Memory) GetSignUploadURL(object string, expire int64, headers ...map[string]string) (upload SignedUpload, err error) {
+	return m.GetSignUploadURLContext(context.Background(), object, expire, headers...)
+}
+
+func (m *Memory) GetSignUploadURLContext(ctx context.Context, object string, expire int64, headers ...map[string]string) (upload SignedUpload, err error) {
+	defer func() { err = memoryError("GetSignUploadURL", object, err) }()
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	upload = signUploadURL(m.Domain, m.Secret, object, expire, headers...)
+	return
+}
+
+func (m *Memory) PostPolicy(keyPrefix string, maxSize int64, contentTypes []string, expire int64) (form PostForm, err error) {
+	return m.PostPolicyContext(context.Background(), keyPrefix, maxSize, contentTypes, expire)
+}
+
+func (m *Memory) PostPolicyContext(ctx context.Context, keyPrefix string, maxSize int64, contentTypes []string, expire int64) (form PostForm, err error) {
+	defer func() { err = memoryError("PostPolicy", keyPrefix, err) }()
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	form = signPostPolicy(m.Domain, m.Secret, keyPrefix, maxSize, contentTypes, expire)
+	return
+}
+
+// ServeHTTP 使 Memory 可以作为 http.Handler 提供文件访问，测试时可以直接用于 httptest.NewServer
+func (m *Memory) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h := &signedHandler{
+		store:  m,
+		secret: m.Secret,
+		public: m.Public,
+		open:   m.open,
+	}
+	h.ServeHTTP(w, r)
+}
+
+func (m *Memory) Lists(prefix string) (files []File, err error) {
+	return m.ListsContext(context.Background(), prefix)
+}
+
+func (m *Memory) ListsContext(ctx context.Context, prefix string) (files []File, err error) {
+	defer func() { err = memoryError("Lists", prefix, err) }()
+	return listAll(ctx, m, prefix)
+}
+
+// prefix 下大于 marker 的全部文件，keys 按字典序排列
+func (m *Memory) snapshot(prefix, marker string) (keys []string, objects map[string]memoryObject) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	objects = make(map[string]memoryObject)
+	for key, obj := range m.objects {
+		if strings.HasPrefix(key, prefix) && key > marker {
+			keys = append(keys, key)
+			objects[key] = obj
+		}
+	}
+	sort.Strings(keys)
+	return
+}
+
+func (m *Memory) ListPage(prefix, marker string, limit int) (files []File, nextMarker string, err error) {
+	return m.ListPageContext(context.Background(), prefix, marker, limit)
+}
+
+func (m *Memory) ListPageContext(ctx context.Context, prefix, marker string, limit int) (files []File, nextMarker string, err error) {
+	defer func() { err = memoryError("ListPage", prefix, err) }()
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	var all []File
+	keys, objects := m.snapshot(objectRel(prefix), marker)
+	for _, key := range keys {
+		all = append(all, objects[key].info(key))
+	}
+	return pageFiles(all, keys, limit)
+}
+
+func (m *Memory) ListDir(dir, marker string, limit int) (files []File, nextMarker string, err error) {
+	return m.ListDirContext(context.Background(), dir, marker, limit)
+}
+
+// 子目录以 "目录名/" 参与排序和分页，与云存储的 common prefix 一致
+func (m *Memory) ListDirContext(ctx context.Context, dir, marker string, limit int) (files []File, nextMarker string, err error) {
+	defer func() { err = memoryError("ListDir", dir, err) }()
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	var (
+		prefix = dirPrefix(dir)
+		all    []File
+		keys   []string
+	)
+	sorted, objects := m.snapshot(prefix, marker)
+	for _, key := range sorted {
+		// marker 为子目录时，跳过子目录下的文件
+		if key == prefix || isDirKey(marker) && strings.HasPrefix(key, marker) {
+			continue
+		}
+		if i := strings.Index(key[len(prefix):], delimiter); i >= 0 {
+			key = key[:len(prefix)+i+1]
+			if len(keys) > 0 && keys[len(keys)-1] == key {
+				continue
+			}
+			all = append(all, dirFile(key))
+		} else {
+			all = append(all, objects[key].info(key))
+		}
+		keys = append(keys, key)
+	}
+	return pageFiles(all, keys, limit)
+}
+
+// 将错误转换为 StoreError
+func memoryError(op, object string, err error) error {
+	return fsError("memory", op, object, err)
+}
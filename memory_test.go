@@ -0,0 +1,168 @@
+package CloudStore
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemory(t *testing.T) {
+	mem := NewMemory("", "secret")
+	if err := mem.Upload(objectSVGGzip, objectSVGGzip, headerGzip, headerSVG); err != nil {
+		t.Fatal(err)
+	}
+	if err := mem.IsExist(objectSVGGzip); err != nil {
+		t.Error(err)
+	}
+	if err := mem.IsExist(objectNotExist); !errors.Is(err, ErrNotExist) {
+		t.Errorf("IsExist(%q) = %v, want ErrNotExist", objectNotExist, err)
+	}
+
+	info, err := mem.GetInfo(objectSVGGzip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Header["Content-Encoding"] != "gzip" || info.Header["Content-Type"] != "image/svg+xml" {
+		t.Errorf("GetInfo header = %v", info.Header)
+	}
+	want, _ := ioutil.ReadFile(objectSVGGzip)
+	if info.Size != int64(len(want)) {
+		t.Errorf("GetInfo size = %v, want %v", info.Size, len(want))
+	}
+
+	reader, _, err := mem.Get(objectSVGGzip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := ioutil.ReadAll(reader)
+	reader.Close()
+	if string(got) != string(want) {
+		t.Error("Get returns different content")
+	}
+
+	if err = mem.Move(objectSVGGzip, "moved/test.svg"); err != nil {
+		t.Fatal(err)
+	}
+	if err = mem.IsExist(objectSVGGzip); !errors.Is(err, ErrNotExist) {
+		t.Errorf("source still exists after Move: %v", err)
+	}
+	if info, _ = mem.GetInfo("moved/test.svg"); info.Header["Content-Encoding"] != "gzip" {
+		t.Errorf("Move lost header: %v", info.Header)
+	}
+}
+
+func TestMemory_List(t *testing.T) {
+	mem := NewMemory("", "")
+	for _, object := range []string{"a/1.txt", "a/2.txt", "a/b/3.txt", "a/b/4.txt", "a/c/", "a-d.txt"} {
+		if err := mem.Put(object, strings.NewReader(object), int64(len(object))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := mem.Lists("a/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 5 {
+		t.Errorf("Lists returns %v files, want 5", len(files))
+	}
+
+	var names []string
+	marker := ""
+	for {
+		var page []File
+		page, marker, err = mem.ListDir("a", marker, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, file := range page {
+			names = append(names, file.Name)
+		}
+		if marker == "" {
+			break
+		}
+	}
+	if got := strings.Join(names, ","); got != "a/1.txt,a/2.txt,a/b,a/c" {
+		t.Errorf("ListDir = %v", got)
+	}
+}
+
+func TestMemory_SignURL(t *testing.T) {
+	mem := NewMemory("", "secret")
+	srv := httptest.NewServer(mem)
+	defer srv.Close()
+	mem.Domain = srv.URL
+	mem.Put("signed.txt", strings.NewReader("hello"), 5)
+
+	link, _ := mem.GetSignURL("signed.txt", 60)
+	if status := httpStatus(t, link); status != http.StatusOK {
+		t.Errorf("GET signed url: %v", status)
+	}
+	if status := httpStatus(t, srv.URL+"/signed.txt"); status != http.StatusForbidden {
+		t.Errorf("GET unsigned url: %v", status)
+	}
+	expired := srv.URL + "/signed.txt?" + signQuery(mem.Secret, http.MethodGet, "signed.txt", time.Now().Unix()-1).Encode()
+	if status := httpStatus(t, expired); status != http.StatusForbidden {
+		t.Errorf("GET expired url: %v", status)
+	}
+}
+
+// 文件名中的空格、#、? 等字符需要转义，否则链接会指向其他文件
+func TestMemory_SignURLEscape(t *testing.T) {
+	mem := NewMemory("", "secret")
+	srv := httptest.NewServer(mem)
+	defer srv.Close()
+	mem.Domain = srv.URL
+
+	object := "dir/a b#1?x=%2F.txt"
+	upload, _ := mem.GetSignUploadURL(object, 60)
+	req, _ := http.NewRequest(upload.Method, upload.URL, strings.NewReader("hello"))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("PUT signed upload url: %v", resp.StatusCode)
+	}
+	if err = mem.IsExist(object); err != nil {
+		t.Errorf("IsExist(%q): %v", object, err)
+	}
+
+	link, _ := mem.GetSignURL(object, 60)
+	if status := httpStatus(t, link); status != http.StatusOK {
+		t.Errorf("GET signed url: %v", status)
+	}
+}
+
+func TestMemory_Concurrent(t *testing.T) {
+	mem := NewMemory("", "")
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			mem.Put("concurrent.txt", strings.NewReader("x"), 1)
+			mem.Lists("")
+			mem.Copy("concurrent.txt", "copy.txt")
+		}()
+	}
+	wg.Wait()
+	if err := mem.IsExist("copy.txt"); err != nil {
+		t.Error(err)
+	}
+}
+
+func httpStatus(t *testing.T, link string) int {
+	resp, err := http.Get(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
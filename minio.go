@@ -1,11 +1,14 @@
 package CloudStore
 
 import (
+	"context"
 	"errors"
 	"io"
+	"net/http"
 	"net/url"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/minio/minio-go"
@@ -18,6 +21,7 @@ type MinIO struct {
 	Endpoint  string
 	Domain    string
 	Client    *minio.Client
+	transport *minioTransport // 为条件上传加上请求头，NewMinIO 创建
 }
 
 func NewMinIO(accessKey, secretKey, bucket, endpoint, domain string) (m *MinIO, err error) {
@@ -32,16 +36,70 @@ func NewMinIO(accessKey, secretKey, bucket, endpoint, domain string) (m *MinIO,
 		Domain:    domain,
 	}
 	m.Client, err = minio.New(endpoint, accessKey, secretKey, false)
+	if err == nil {
+		m.transport = &minioTransport{RoundTripper: minio.DefaultTransport}
+		m.Client.SetCustomTransport(m.transport)
+	}
 	m.Domain = strings.TrimRight(m.Domain, "/")
 	return
 }
 
+type minioConditionsKey struct{}
+
+// minio-go v6 会把 PutObjectOptions 中不认识的请求头作为自定义元数据，条件上传的 If-Match、If-None-Match
+// 由 minioTransport 在发送请求时加上：简单上传通过 ctx 传入，SDK 完成分片上传时不传递 ctx，按 uploadId 查找
+type minioTransport struct {
+	http.RoundTripper
+	uploads sync.Map // uploadId → http.Header
+}
+
+func (t *minioTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	query := req.URL.Query()
+	_, multipart := query["uploadId"]
+	// 上传分片时不需要条件请求头，只加在上传文件以及完成分片上传的请求上
+	if req.Method == http.MethodPut && !multipart || req.Method == http.MethodPost && multipart {
+		header, _ := req.Context().Value(minioConditionsKey{}).(http.Header)
+		if v, ok := t.uploads.Load(query.Get("uploadId")); ok && multipart {
+			header = v.(http.Header)
+		}
+		if len(header) > 0 {
+			req = req.Clone(req.Context())
+			for k, v := range header {
+				req.Header[k] = v
+			}
+		}
+	}
+	return t.RoundTripper.RoundTrip(req)
+}
+
+// 条件上传的请求头，由 MinIO 检查
+func (m *MinIO) uploadConditions(h objectHeader) (header http.Header, err error) {
+	header = Conditions{IfMatch: h.IfMatch, IfNoneMatch: h.IfNoneMatch}.header()
+	if len(header) > 0 && m.transport == nil {
+		err = errors.New("conditional upload requires a MinIO created by NewMinIO")
+	}
+	return
+}
+
 func (m *MinIO) IsExist(object string) (err error) {
-	_, err = m.GetInfo(object)
+	return m.IsExistContext(context.Background(), object)
+}
+
+func (m *MinIO) IsExistContext(ctx context.Context, object string) (err error) {
+	defer func() { err = minioError("IsExist", object, err) }()
+	_, err = m.GetInfoContext(ctx, object)
 	return
 }
 
 func (m *MinIO) Upload(tmpFile, saveFile string, headers ...map[string]string) (err error) {
+	return m.UploadContext(context.Background(), tmpFile, saveFile, headers...)
+}
+
+func (m *MinIO) UploadContext(ctx context.Context, tmpFile, saveFile string, headers ...map[string]string) (err error) {
+	defer func() { err = minioError("Upload", saveFile, err) }()
+	if useMultipart(tmpFile) {
+		return m.UploadMultipartContext(ctx, tmpFile, saveFile, MultipartOptions{}, headers...)
+	}
 	var (
 		fp   *os.File
 		info os.FileInfo
@@ -57,55 +115,269 @@ func (m *MinIO) Upload(tmpFile, saveFile string, headers ...map[string]string) (
 		return
 	}
 
-	opts := minio.PutObjectOptions{
-		UserMetadata: make(map[string]string),
+	return m.PutContext(ctx, saveFile, fp, info.Size(), headers...)
+}
+
+func (m *MinIO) Put(object string, reader io.Reader, size int64, headers ...map[string]string) (err error) {
+	return m.PutContext(context.Background(), object, reader, size, headers...)
+}
+
+func (m *MinIO) PutContext(ctx context.Context, object string, reader io.Reader, size int64, headers ...map[string]string) (err error) {
+	h := parseHeaders(headers...)
+	defer func() { err = uploadConditionError(minioError("Put", object, err), h) }()
+	var cond http.Header
+	if cond, err = m.uploadConditions(h); err != nil {
+		return
+	}
+	if len(cond) > 0 {
+		ctx = context.WithValue(ctx, minioConditionsKey{}, cond)
 	}
+	opts := minioPutOptions(headers...)
+	_, err = m.Client.PutObjectWithContext(ctx, m.Bucket, objectRel(object), reader, size, opts)
+	return
+}
 
-	for _, header := range headers {
-		for k, v := range header {
-			switch strings.ToLower(k) {
-			case "content-disposition":
-				opts.ContentDisposition = v
-			case "content-encoding":
-				opts.ContentEncoding = v
-			case "content-type":
-				opts.ContentType = v
-			default:
-				opts.UserMetadata[k] = v
-			}
-		}
+// minio-go v6 不支持设置 Expires 和 Content-MD5（由 SDK 自己计算校验），这里忽略；
+// UserMetadata 中 x-amz- 开头的请求头会原样发送，其他的会加上 x-amz-meta- 前缀
+func minioPutOptions(headers ...map[string]string) (opts minio.PutObjectOptions) {
+	h := parseHeaders(headers...)
+	opts = minio.PutObjectOptions{
+		CacheControl:       h.CacheControl,
+		ContentDisposition: h.ContentDisposition,
+		ContentEncoding:    h.ContentEncoding,
+		ContentLanguage:    h.ContentLanguage,
+		ContentType:        h.ContentType,
+		UserMetadata:       make(map[string]string),
+	}
+	if h.StorageClass != "" {
+		opts.StorageClass = minioDialect.nativeStorageClass(h.StorageClass)
+	}
+	if h.ACL != "" {
+		opts.UserMetadata[minioDialect.acl] = h.ACL
+	}
+	for k, v := range h.Metadata {
+		opts.UserMetadata[minioDialect.metaPrefix+k] = v
 	}
+	return
+}
+
+func (m *MinIO) Get(object string) (reader io.ReadCloser, info File, err error) {
+	return m.GetContext(context.Background(), object)
+}
+
+func (m *MinIO) GetContext(ctx context.Context, object string) (reader io.ReadCloser, info File, err error) {
+	defer func() { err = minioError("Get", object, err) }()
+	return m.get(ctx, object, minio.GetObjectOptions{})
+}
 
-	_, err = m.Client.PutObject(m.Bucket, objectRel(saveFile), fp, info.Size(), opts)
+func (m *MinIO) GetIf(object string, cond Conditions) (reader io.ReadCloser, info File, err error) {
+	return m.GetIfContext(context.Background(), object, cond)
+}
+
+func (m *MinIO) GetIfContext(ctx context.Context, object string, cond Conditions) (reader io.ReadCloser, info File, err error) {
+	defer func() { err = minioError("GetIf", object, err) }()
+	return m.get(ctx, object, minioConditions(cond))
+}
+
+// 条件请求的请求头
+func minioConditions(cond Conditions) (opts minio.GetObjectOptions) {
+	header := cond.header()
+	for k := range header {
+		opts.Set(k, header.Get(k))
+	}
 	return
 }
 
+func (m *MinIO) get(ctx context.Context, object string, opts minio.GetObjectOptions) (reader io.ReadCloser, info File, err error) {
+	var (
+		obj     *minio.Object
+		objInfo minio.ObjectInfo
+	)
+	object = objectRel(object)
+	obj, err = m.Client.GetObjectWithContext(ctx, m.Bucket, object, opts)
+	if err != nil {
+		return
+	}
+
+	objInfo, err = obj.Stat()
+	if err != nil {
+		obj.Close()
+		return
+	}
+	info = File{
+		ModTime: objInfo.LastModified,
+		Name:    object,
+		Size:    objInfo.Size,
+	}
+	minioDialect.setHeader(&info, minioHeader(objInfo))
+	reader = obj
+	return
+}
+
+func (m *MinIO) UploadMultipart(tmpFile, saveFile string, opts MultipartOptions, headers ...map[string]string) (err error) {
+	return m.UploadMultipartContext(context.Background(), tmpFile, saveFile, opts, headers...)
+}
+
+func (m *MinIO) UploadMultipartContext(ctx context.Context, tmpFile, saveFile string, opts MultipartOptions, headers ...map[string]string) (err error) {
+	h := parseHeaders(headers...)
+	defer func() { err = uploadConditionError(minioError("UploadMultipart", saveFile, err), h) }()
+	return uploadMultipart(ctx, m, tmpFile, saveFile, opts, headers...)
+}
+
+// minio-go 的 Core 不支持 context，分片上传的各个步骤在 ctx 结束时提前返回
+func (m *MinIO) initMultipart(ctx context.Context, object string, size, partSize int64, header map[string]string) (uploadID string, err error) {
+	opts := minioPutOptions(header)
+	core := minio.Core{Client: m.Client}
+	var id string
+	err = doContext(ctx, func() (e error) {
+		id, e = core.NewMultipartUpload(m.Bucket, object, opts)
+		return
+	})
+	if err != nil {
+		return
+	}
+	uploadID = id
+	return
+}
+
+func (m *MinIO) uploadPart(ctx context.Context, object, uploadID string, number int, reader io.Reader, size int64) (etag string, err error) {
+	core := minio.Core{Client: m.Client}
+	var part minio.ObjectPart
+	err = doContextReader(ctx, reader, func(reader io.Reader) (e error) {
+		part, e = core.PutObjectPart(m.Bucket, object, uploadID, number, reader, size, "", "", nil)
+		return
+	})
+	if err != nil {
+		return
+	}
+	etag = part.ETag
+	return
+}
+
+func (m *MinIO) completeMultipart(ctx context.Context, object, uploadID string, size int64, parts []uploadedPart, header map[string]string) (err error) {
+	completeParts := make([]minio.CompletePart, 0, len(parts))
+	for _, part := range parts {
+		completeParts = append(completeParts, minio.CompletePart{PartNumber: part.Number, ETag: part.ETag})
+	}
+	cond, err := m.uploadConditions(parseHeaders(header))
+	if err != nil {
+		return
+	}
+	if len(cond) > 0 {
+		m.transport.uploads.Store(uploadID, cond)
+		defer m.transport.uploads.Delete(uploadID)
+	}
+	core := minio.Core{Client: m.Client}
+	return doContext(ctx, func() (e error) {
+		_, e = core.CompleteMultipartUpload(m.Bucket, object, uploadID, completeParts)
+		return
+	})
+}
+
+func (m *MinIO) listParts(ctx context.Context, object, uploadID string) (parts []uploadedPart, err error) {
+	core := minio.Core{Client: m.Client}
+	var marker int
+	for {
+		var res minio.ListObjectPartsResult
+		err = doContext(ctx, func() (e error) {
+			res, e = core.ListObjectParts(m.Bucket, object, uploadID, marker, 1000)
+			return
+		})
+		if err != nil {
+			return
+		}
+		for _, part := range res.ObjectParts {
+			parts = append(parts, uploadedPart{Number: part.PartNumber, ETag: part.ETag})
+		}
+		if !res.IsTruncated {
+			return
+		}
+		marker = res.NextPartNumberMarker
+	}
+}
+
+func (m *MinIO) abortMultipart(ctx context.Context, object, uploadID string) (err error) {
+	core := minio.Core{Client: m.Client}
+	return doContext(ctx, func() error {
+		return core.AbortMultipartUpload(m.Bucket, object, uploadID)
+	})
+}
+
 func (m *MinIO) Delete(objects ...string) (err error) {
+	return m.DeleteContext(context.Background(), objects...)
+}
+
+func (m *MinIO) DeleteContext(ctx context.Context, objects ...string) (err error) {
+	defer func() { err = minioError("Delete", strings.Join(objects, ", "), err) }()
 	if len(objects) == 0 {
 		return
 	}
 
-	var errs []string
+	var errs []error
 
 	objectsChan := make(chan string)
 	go func() {
 		defer close(objectsChan)
 		for _, object := range objects {
-			objectsChan <- objectRel(object)
+			select {
+			case objectsChan <- objectRel(object):
+			case <-ctx.Done():
+				return
+			}
 		}
 	}()
-	for errRm := range m.Client.RemoveObjects(m.Bucket, objectsChan) {
+	for errRm := range m.Client.RemoveObjectsWithContext(ctx, m.Bucket, objectsChan) {
 		if errRm.Err != nil {
-			errs = append(errs, errRm.Err.Error())
+			errs = append(errs, minioError("Delete", errRm.ObjectName, errRm.Err))
 		}
 	}
-	if len(errs) > 0 {
-		err = errors.New(strings.Join(errs, "; "))
+	if err = deleteError(errs); err == nil {
+		err = ctx.Err()
 	}
 	return
 }
 
+func (m *MinIO) Copy(src, dst string) (err error) {
+	return m.CopyContext(context.Background(), src, dst)
+}
+
+// minio-go 的 CopyObject 不支持 context，在 ctx 结束时提前返回
+func (m *MinIO) CopyContext(ctx context.Context, src, dst string) (err error) {
+	defer func() { err = minioError("Copy", src, err) }()
+	var dstInfo minio.DestinationInfo
+	// userMeta 为 nil 时保留源文件的元数据
+	dstInfo, err = minio.NewDestinationInfo(m.Bucket, objectRel(dst), nil, nil)
+	if err != nil {
+		return
+	}
+	srcInfo := minio.NewSourceInfo(m.Bucket, objectRel(src), nil)
+	return doContext(ctx, func() error {
+		return m.Client.CopyObject(dstInfo, srcInfo)
+	})
+}
+
+func (m *MinIO) Move(src, dst string) (err error) {
+	return m.MoveContext(context.Background(), src, dst)
+}
+
+// MinIO 没有移动文件的接口，先复制再删除源文件
+func (m *MinIO) MoveContext(ctx context.Context, src, dst string) (err error) {
+	defer func() { err = minioError("Move", src, err) }()
+	if err = m.CopyContext(ctx, src, dst); err != nil {
+		return
+	}
+	return m.DeleteContext(ctx, src)
+}
+
 func (m *MinIO) GetSignURL(object string, expire int64) (link string, err error) {
+	return m.GetSignURLContext(context.Background(), object, expire)
+}
+
+func (m *MinIO) GetSignURLContext(ctx context.Context, object string, expire int64) (link string, err error) {
+	defer func() { err = minioError("GetSignURL", object, err) }()
+	if err = ctx.Err(); err != nil {
+		return
+	}
 	if expire <= 0 {
 		link = m.Domain + objectAbs(object)
 		return
@@ -126,35 +398,133 @@ func (m *MinIO) GetSignURL(object string, expire int64) (link string, err error)
 	return
 }
 
-func (m *MinIO) Download(object string, savePath string) (err error) {
-	obj := &minio.Object{}
-	obj, err = m.Client.GetObject(m.Bucket, objectRel(object), minio.GetObjectOptions{})
+func (m *MinIO) GetSignUploadURL(object string, expire int64, headers ...map[string]string) (upload SignedUpload, err error) {
+	return m.GetSignUploadURLContext(context.Background(), object, expire, headers...)
+}
+
+// MinIO 的预签名 PUT 不对请求头签名，headers 原样返回，由客户端上传时带上；
+// 签名中包含了 Host，因此不替换为绑定的域名
+func (m *MinIO) GetSignUploadURLContext(ctx context.Context, object string, expire int64, headers ...map[string]string) (upload SignedUpload, err error) {
+	defer func() { err = minioError("GetSignUploadURL", object, err) }()
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	if expire <= 0 {
+		expire = oneHour
+	}
+	if expire > sevenDays {
+		expire = sevenDays
+	}
+	upload = SignedUpload{
+		Method: http.MethodPut,
+		Header: minioDialect.native(parseHeaders(headers...)),
+	}
+	var u *url.URL
+	u, err = m.Client.PresignedPutObject(m.Bucket, objectRel(object), time.Duration(expire)*time.Second)
 	if err != nil {
 		return
 	}
+	upload.URL = u.String()
+	return
+}
 
-	_, err = obj.Stat()
-	if err != nil {
+func (m *MinIO) PostPolicy(keyPrefix string, maxSize int64, contentTypes []string, expire int64) (form PostForm, err error) {
+	return m.PostPolicyContext(context.Background(), keyPrefix, maxSize, contentTypes, expire)
+}
+
+// minio-go 的 PostPolicy 只支持单个文件类型，多个文件类型时返回错误
+func (m *MinIO) PostPolicyContext(ctx context.Context, keyPrefix string, maxSize int64, contentTypes []string, expire int64) (form PostForm, err error) {
+	defer func() { err = minioError("PostPolicy", keyPrefix, err) }()
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	if expire <= 0 {
+		expire = oneHour
+	}
+	if expire > sevenDays {
+		expire = sevenDays
+	}
+	if len(contentTypes) > 1 {
+		err = errPolicyContentTypes
+		return
+	}
+	policy := minio.NewPostPolicy()
+	if err = policy.SetBucket(m.Bucket); err != nil {
+		return
+	}
+	if err = policy.SetExpires(time.Now().Add(time.Duration(expire) * time.Second).UTC()); err != nil {
+		return
+	}
+	if keyPrefix = objectRel(keyPrefix); keyPrefix != "" {
+		if err = policy.SetKeyStartsWith(keyPrefix); err != nil {
+			return
+		}
+	}
+	if maxSize > 0 {
+		if err = policy.SetContentLengthRange(0, maxSize); err != nil {
+			return
+		}
+	}
+	if len(contentTypes) == 1 {
+		if err = policy.SetContentType(contentTypes[0]); err != nil {
+			return
+		}
+	}
+	if err = policy.SetSuccessStatusAction("200"); err != nil {
 		return
 	}
 
-	var fp *os.File
-	fp, err = os.Create(savePath)
+	var u *url.URL
+	u, form.Fields, err = m.Client.PresignedPostPolicy(policy)
 	if err != nil {
 		return
 	}
-	defer fp.Close()
+	form.URL = u.String()
+	form.Fields["key"] = postKey(keyPrefix)
+	return
+}
 
-	_, err = io.Copy(fp, obj)
+func (m *MinIO) Download(object string, savePath string) (err error) {
+	return m.DownloadContext(context.Background(), object, savePath)
+}
 
-	return
+func (m *MinIO) DownloadContext(ctx context.Context, object string, savePath string) (err error) {
+	defer func() { err = minioError("Download", object, err) }()
+	var reader io.ReadCloser
+	reader, _, err = m.GetContext(ctx, object)
+	if err != nil {
+		return
+	}
+	defer reader.Close()
+	return saveTo(reader, savePath)
 }
 
 func (m *MinIO) GetInfo(object string) (info File, err error) {
+	return m.GetInfoContext(context.Background(), object)
+}
+
+// minio-go 的 StatObject 不支持 context，在 ctx 结束时提前返回
+func (m *MinIO) GetInfoContext(ctx context.Context, object string) (info File, err error) {
+	defer func() { err = minioError("GetInfo", object, err) }()
+	return m.getInfo(ctx, object, minio.StatObjectOptions{})
+}
+
+func (m *MinIO) GetInfoIf(object string, cond Conditions) (info File, err error) {
+	return m.GetInfoIfContext(context.Background(), object, cond)
+}
+
+func (m *MinIO) GetInfoIfContext(ctx context.Context, object string, cond Conditions) (info File, err error) {
+	defer func() { err = minioError("GetInfoIf", object, err) }()
+	return m.getInfo(ctx, object, minio.StatObjectOptions{GetObjectOptions: minioConditions(cond)})
+}
+
+func (m *MinIO) getInfo(ctx context.Context, object string, opts minio.StatObjectOptions) (info File, err error) {
 	var objInfo minio.ObjectInfo
-	opts := minio.StatObjectOptions{}
 	object = objectRel(object)
-	objInfo, err = m.Client.StatObject(m.Bucket, object, opts)
+	err = doContext(ctx, func() (e error) {
+		objInfo, e = m.Client.StatObject(m.Bucket, object, opts)
+		return
+	})
 	if err != nil {
 		return
 	}
@@ -162,32 +532,97 @@ func (m *MinIO) GetInfo(object string) (info File, err error) {
 		ModTime: objInfo.LastModified,
 		Name:    object,
 		Size:    objInfo.Size,
-		IsDir:   objInfo.Size == 0,
-		Header:  make(map[string]string),
-	}
-	for k, _ := range objInfo.Metadata {
-		info.Header[k] = objInfo.Metadata.Get(k)
+		IsDir:   isDirKey(object),
 	}
+	minioDialect.setHeader(&info, minioHeader(objInfo))
 	return
 }
 
+// minio-go 从 Metadata 中去掉了 Content-Type 和 ETag
+func minioHeader(objInfo minio.ObjectInfo) map[string]string {
+	header := make(map[string]string)
+	for k := range objInfo.Metadata {
+		header[k] = objInfo.Metadata.Get(k)
+	}
+	if objInfo.ContentType != "" {
+		header[HeaderContentType] = objInfo.ContentType
+	}
+	if objInfo.ETag != "" {
+		header["ETag"] = objInfo.ETag
+	}
+	return header
+}
+
 func (m *MinIO) Lists(prefix string) (files []File, err error) {
-	prefix = objectRel(prefix)
-	doneCh := make(chan struct{})
-	defer close(doneCh)
-	objects := m.Client.ListObjectsV2(m.Bucket, prefix, true, doneCh)
-	for object := range objects {
-		header := make(map[string]string)
-		file := File{
-			ModTime: object.LastModified,
-			Size:    object.Size,
-			IsDir:   object.Size == 0,
-			Name:    objectRel(object.Key),
-		}
-		for k, _ := range object.Metadata {
-			header[k] = object.Metadata.Get(k)
+	return m.ListsContext(context.Background(), prefix)
+}
+
+func (m *MinIO) ListsContext(ctx context.Context, prefix string) (files []File, err error) {
+	defer func() { err = minioError("Lists", prefix, err) }()
+	return listAll(ctx, m, prefix)
+}
+
+func (m *MinIO) ListPage(prefix, marker string, limit int) (files []File, nextMarker string, err error) {
+	return m.ListPageContext(context.Background(), prefix, marker, limit)
+}
+
+func (m *MinIO) ListPageContext(ctx context.Context, prefix, marker string, limit int) (files []File, nextMarker string, err error) {
+	defer func() { err = minioError("ListPage", prefix, err) }()
+	return m.list(ctx, objectRel(prefix), "", marker, limit)
+}
+
+func (m *MinIO) ListDir(dir, marker string, limit int) (files []File, nextMarker string, err error) {
+	return m.ListDirContext(context.Background(), dir, marker, limit)
+}
+
+func (m *MinIO) ListDirContext(ctx context.Context, dir, marker string, limit int) (files []File, nextMarker string, err error) {
+	defer func() { err = minioError("ListDir", dir, err) }()
+	return m.list(ctx, dirPrefix(dir), delimiter, marker, limit)
+}
+
+// minio-go 的 Client 只提供了基于 channel 的列表接口，分页需要使用 Core
+func (m *MinIO) list(ctx context.Context, prefix, delim, marker string, limit int) (files []File, nextMarker string, err error) {
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	var res minio.ListBucketV2Result
+	core := minio.Core{Client: m.Client}
+	err = doContext(ctx, func() (e error) {
+		res, e = core.ListObjectsV2(m.Bucket, prefix, marker, false, delim, limit, "")
+		return
+	})
+	if err != nil {
+		return
+	}
+	for _, dir := range res.CommonPrefixes {
+		files = append(files, dirFile(dir.Prefix))
+	}
+	for _, object := range res.Contents {
+		if delim != "" && object.Key == prefix {
+			continue
 		}
-		files = append(files, file)
+		files = append(files, File{
+			ModTime:      object.LastModified,
+			Size:         object.Size,
+			IsDir:        isDirKey(object.Key),
+			Name:         objectRel(object.Key),
+			ETag:         strings.Trim(object.ETag, `"`),
+			StorageClass: minioDialect.canonicalStorageClass(object.StorageClass),
+		})
+	}
+	if res.IsTruncated {
+		nextMarker = res.NextContinuationToken
 	}
 	return
 }
+
+// 将 SDK 返回的错误转换为 StoreError
+func minioError(op, object string, err error) error {
+	if keepError(err) {
+		return err
+	}
+	if e, ok := err.(minio.ErrorResponse); ok {
+		return newStoreError("minio", op, object, e.StatusCode, e.Code, e.RequestID, err)
+	}
+	return newStoreError("minio", op, object, 0, "", "", err)
+}
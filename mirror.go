@@ -0,0 +1,439 @@
+package CloudStore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultMirrorCooldown = 30 * time.Second
+
+// Mirror 把写入和删除同时发送到多个云存储（副本），至少 Quorum 个副本成功时视为成功；
+// 读取时按顺序使用第一个可用的副本，失败时依次尝试下一个副本。
+// 读取失败（文件不存在除外）的副本在 Cooldown 时间内排在其他副本之后
+type Mirror struct {
+	Stores   []CloudStore
+	Quorum   int           // 写入和删除至少需要成功的副本数，小于等于 0 时为全部副本
+	Cooldown time.Duration // 默认 30s
+
+	// OnReplicaError 在副本操作失败时调用（即使整体操作成功），replica 为副本在 Stores 中的序号，可以用于记录日志或者修复数据
+	OnReplicaError func(op, key string, replica int, err error)
+
+	lock      sync.Mutex
+	unhealthy map[int]time.Time // 副本读取失败的时间
+}
+
+// NewMirror 创建 Mirror，quorum 见 Mirror.Quorum
+func NewMirror(quorum int, stores ...CloudStore) *Mirror {
+	return &Mirror{Stores: stores, Quorum: quorum}
+}
+
+// MirrorError 为 Mirror 操作失败时各副本的错误，Errors 与 Mirror.Stores 一一对应，成功或者没有尝试的副本为 nil
+type MirrorError struct {
+	Op        string
+	Key       string
+	Succeeded int // 成功的副本数
+	Quorum    int // 需要成功的副本数，读取时为 1
+	Errors    []error
+}
+
+func (e *MirrorError) Error() string {
+	var errs []string
+	for i, err := range e.Errors {
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("replica %d: %v", i, err))
+		}
+	}
+	s := "mirror " + e.Op
+	if e.Key != "" {
+		s += " " + e.Key
+	}
+	return fmt.Sprintf("%v: %d/%d replicas succeeded, quorum %d: %v", s, e.Succeeded, len(e.Errors), e.Quorum, strings.Join(errs, "; "))
+}
+
+// Is 在所有失败的副本都是 target 时返回 true，如所有副本都返回 ErrNotExist
+func (e *MirrorError) Is(target error) bool {
+	found := false
+	for _, err := range e.Errors {
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, target) {
+			return false
+		}
+		found = true
+	}
+	return found
+}
+
+// As 使 errors.As 可以取得第一个符合的副本错误，如 *StoreError
+func (e *MirrorError) As(target interface{}) bool {
+	for _, err := range e.Errors {
+		if err != nil && errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Mirror) quorum() int {
+	if m.Quorum <= 0 || m.Quorum > len(m.Stores) {
+		return len(m.Stores)
+	}
+	return m.Quorum
+}
+
+func (m *Mirror) replicaError(op, key string, replica int, err error) {
+	if m.OnReplicaError != nil {
+		m.OnReplicaError(op, key, replica, err)
+	}
+}
+
+// 并发地在所有副本上执行 fn，成功的副本数达到 quorum 时返回 nil
+func (m *Mirror) fanOut(op, key string, fn func(replica int, store CloudStore) error) (err error) {
+	errs := make([]error, len(m.Stores))
+	var wg sync.WaitGroup
+	for i, store := range m.Stores {
+		wg.Add(1)
+		go func(i int, store CloudStore) {
+			defer wg.Done()
+			errs[i] = fn(i, store)
+		}(i, store)
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for i, e := range errs {
+		if e == nil {
+			succeeded++
+			continue
+		}
+		m.replicaError(op, key, i, e)
+	}
+	if succeeded >= m.quorum() {
+		return nil
+	}
+	return &MirrorError{Op: op, Key: key, Succeeded: succeeded, Quorum: m.quorum(), Errors: errs}
+}
+
+// 读取的顺序：可用的副本在前，冷却中的副本在后，各自保持 Stores 中的顺序
+func (m *Mirror) readOrder() (order []int) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	cooldown := m.Cooldown
+	if cooldown <= 0 {
+		cooldown = defaultMirrorCooldown
+	}
+	var cooling []int
+	for i := range m.Stores {
+		if t, ok := m.unhealthy[i]; ok && time.Since(t) < cooldown {
+			cooling = append(cooling, i)
+			continue
+		}
+		order = append(order, i)
+	}
+	return append(order, cooling...)
+}
+
+func (m *Mirror) setHealthy(replica int, err error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if err == nil || errors.Is(err, ErrNotExist) || errors.Is(err, ErrPreconditionFailed) || errors.Is(err, ErrNotModified) {
+		delete(m.unhealthy, replica)
+		return
+	}
+	if m.unhealthy == nil {
+		m.unhealthy = make(map[int]time.Time)
+	}
+	m.unhealthy[replica] = time.Now()
+}
+
+// 按顺序在副本上执行 fn，直到成功；ctx 被取消时不再尝试其他副本，
+// 条件请求不满足是副本正常的响应，直接返回，不算作副本的错误
+func (m *Mirror) failover(ctx context.Context, op, key string, fn func(replica int, store CloudStore) error) (err error) {
+	errs := make([]error, len(m.Stores))
+	for _, i := range m.readOrder() {
+		err = fn(i, m.Stores[i])
+		if ctx.Err() != nil {
+			return err
+		}
+		m.setHealthy(i, err)
+		if err == nil || errors.Is(err, ErrNotModified) || errors.Is(err, ErrPreconditionFailed) {
+			return err
+		}
+		errs[i] = err
+		m.replicaError(op, key, i, err)
+	}
+	return &MirrorError{Op: op, Key: key, Quorum: 1, Errors: errs}
+}
+
+func (m *Mirror) Delete(objects ...string) (err error) {
+	return m.DeleteContext(context.Background(), objects...)
+}
+
+func (m *Mirror) DeleteContext(ctx context.Context, objects ...string) (err error) {
+	return m.fanOut("Delete", strings.Join(objects, ","), func(_ int, store CloudStore) error {
+		return store.DeleteContext(ctx, objects...)
+	})
+}
+
+func (m *Mirror) GetSignURL(object string, expire int64) (link string, err error) {
+	return m.GetSignURLContext(context.Background(), object, expire)
+}
+
+func (m *Mirror) GetSignURLContext(ctx context.Context, object string, expire int64) (link string, err error) {
+	err = m.failover(ctx, "GetSignURL", object, func(_ int, store CloudStore) (err error) {
+		link, err = store.GetSignURLContext(ctx, object, expire)
+		return
+	})
+	return
+}
+
+func (m *Mirror) IsExist(object string) (err error) {
+	return m.IsExistContext(context.Background(), object)
+}
+
+func (m *Mirror) IsExistContext(ctx context.Context, object string) (err error) {
+	return m.failover(ctx, "IsExist", object, func(_ int, store CloudStore) error {
+		return store.IsExistContext(ctx, object)
+	})
+}
+
+func (m *Mirror) Lists(prefix string) (files []File, err error) {
+	return m.ListsContext(context.Background(), prefix)
+}
+
+func (m *Mirror) ListsContext(ctx context.Context, prefix string) (files []File, err error) {
+	err = m.failover(ctx, "Lists", prefix, func(_ int, store CloudStore) (err error) {
+		files, err = store.ListsContext(ctx, prefix)
+		return
+	})
+	return
+}
+
+func (m *Mirror) Upload(tmpFile string, saveFile string, headers ...map[string]string) (err error) {
+	return m.UploadContext(context.Background(), tmpFile, saveFile, headers...)
+}
+
+func (m *Mirror) UploadContext(ctx context.Context, tmpFile string, saveFile string, headers ...map[string]string) (err error) {
+	return m.fanOut("Upload", saveFile, func(_ int, store CloudStore) error {
+		return store.UploadContext(ctx, tmpFile, saveFile, headers...)
+	})
+}
+
+func (m *Mirror) Download(object string, savePath string) (err error) {
+	return m.DownloadContext(context.Background(), object, savePath)
+}
+
+func (m *Mirror) DownloadContext(ctx context.Context, object string, savePath string) (err error) {
+	return m.failover(ctx, "Download", object, func(_ int, store CloudStore) error {
+		return store.DownloadContext(ctx, object, savePath)
+	})
+}
+
+func (m *Mirror) GetInfo(object string) (info File, err error) {
+	return m.GetInfoContext(context.Background(), object)
+}
+
+func (m *Mirror) GetInfoContext(ctx context.Context, object string) (info File, err error) {
+	err = m.failover(ctx, "GetInfo", object, func(_ int, store CloudStore) (err error) {
+		info, err = store.GetInfoContext(ctx, object)
+		return
+	})
+	return
+}
+
+func (m *Mirror) GetInfoIf(object string, cond Conditions) (info File, err error) {
+	return m.GetInfoIfContext(context.Background(), object, cond)
+}
+
+func (m *Mirror) GetInfoIfContext(ctx context.Context, object string, cond Conditions) (info File, err error) {
+	err = m.failover(ctx, "GetInfoIf", object, func(_ int, store CloudStore) (err error) {
+		info, err = store.GetInfoIfContext(ctx, object, cond)
+		return
+	})
+	return
+}
+
+func (m *Mirror) Put(object string, reader io.Reader, size int64, headers ...map[string]string) (err error) {
+	return m.PutContext(context.Background(), object, reader, size, headers...)
+}
+
+// reader 只能读取一次，通过 io.Pipe 同时发送给所有副本，上传的速度取决于最慢的副本；
+// 某个副本失败时不影响其他副本继续上传
+func (m *Mirror) PutContext(ctx context.Context, object string, reader io.Reader, size int64, headers ...map[string]string) (err error) {
+	writers := make([]*io.PipeWriter, len(m.Stores))
+	readers := make([]*io.PipeReader, len(m.Stores))
+	for i := range m.Stores {
+		readers[i], writers[i] = io.Pipe()
+	}
+	done := make(chan struct{})
+	defer func() { <-done }()
+	go func() {
+		defer close(done)
+		var readErr error
+		alive := len(writers)
+		failed := make([]bool, len(writers))
+		buf := make([]byte, 32*1024)
+		for readErr == nil && alive > 0 {
+			var n int
+			n, readErr = reader.Read(buf)
+			for i, w := range writers {
+				// 写入失败说明该副本已经结束，不再发送给它
+				if !failed[i] && n > 0 {
+					if _, e := w.Write(buf[:n]); e != nil {
+						failed[i] = true
+						alive--
+					}
+				}
+			}
+		}
+		if readErr == io.EOF {
+			readErr = nil
+		}
+		for _, w := range writers {
+			w.CloseWithError(readErr)
+		}
+	}()
+	return m.fanOut("Put", object, func(i int, store CloudStore) error {
+		err := store.PutContext(ctx, object, readers[i], size, headers...)
+		// 副本没有读取完数据就返回时，使之后的写入立即失败
+		readers[i].CloseWithError(err)
+		return err
+	})
+}
+
+func (m *Mirror) Get(object string) (reader io.ReadCloser, info File, err error) {
+	return m.GetContext(context.Background(), object)
+}
+
+func (m *Mirror) GetContext(ctx context.Context, object string) (reader io.ReadCloser, info File, err error) {
+	err = m.failover(ctx, "Get", object, func(_ int, store CloudStore) (err error) {
+		reader, info, err = store.GetContext(ctx, object)
+		return
+	})
+	return
+}
+
+func (m *Mirror) GetIf(object string, cond Conditions) (reader io.ReadCloser, info File, err error) {
+	return m.GetIfContext(context.Background(), object, cond)
+}
+
+func (m *Mirror) GetIfContext(ctx context.Context, object string, cond Conditions) (reader io.ReadCloser, info File, err error) {
+	err = m.failover(ctx, "GetIf", object, func(_ int, store CloudStore) (err error) {
+		reader, info, err = store.GetIfContext(ctx, object, cond)
+		return
+	})
+	return
+}
+
+func (m *Mirror) ListPage(prefix, marker string, limit int) (files []File, nextMarker string, err error) {
+	return m.ListPageContext(context.Background(), prefix, marker, limit)
+}
+
+// 不同云存储的 marker 不通用，返回的 nextMarker 带上副本的序号，翻页时使用同一个副本，只在第一页时切换副本
+func (m *Mirror) ListPageContext(ctx context.Context, prefix, marker string, limit int) (files []File, nextMarker string, err error) {
+	return m.listPage(ctx, "ListPage", prefix, marker, func(store CloudStore, marker string) ([]File, string, error) {
+		return store.ListPageContext(ctx, prefix, marker, limit)
+	})
+}
+
+func (m *Mirror) ListDir(dir, marker string, limit int) (files []File, nextMarker string, err error) {
+	return m.ListDirContext(context.Background(), dir, marker, limit)
+}
+
+func (m *Mirror) ListDirContext(ctx context.Context, dir, marker string, limit int) (files []File, nextMarker string, err error) {
+	return m.listPage(ctx, "ListDir", dir, marker, func(store CloudStore, marker string) ([]File, string, error) {
+		return store.ListDirContext(ctx, dir, marker, limit)
+	})
+}
+
+// marker 为 {副本序号}:{副本的 marker}
+func (m *Mirror) listPage(ctx context.Context, op, prefix, marker string, list func(store CloudStore, marker string) ([]File, string, error)) (files []File, nextMarker string, err error) {
+	setMarker := func(replica int) {
+		if nextMarker != "" {
+			nextMarker = strconv.Itoa(replica) + ":" + nextMarker
+		}
+	}
+	if marker == "" {
+		err = m.failover(ctx, op, prefix, func(replica int, store CloudStore) (err error) {
+			files, nextMarker, err = list(store, "")
+			setMarker(replica)
+			return
+		})
+		return
+	}
+
+	replica := -1
+	i := strings.Index(marker, ":")
+	if i > 0 {
+		replica, _ = strconv.Atoi(marker[:i])
+	}
+	if replica < 0 || replica >= len(m.Stores) {
+		return nil, "", fmt.Errorf("mirror %v %v: invalid marker %q", op, prefix, marker)
+	}
+	files, nextMarker, err = list(m.Stores[replica], marker[i+1:])
+	setMarker(replica)
+	return
+}
+
+func (m *Mirror) Copy(src, dst string) (err error) {
+	return m.CopyContext(context.Background(), src, dst)
+}
+
+func (m *Mirror) CopyContext(ctx context.Context, src, dst string) (err error) {
+	return m.fanOut("Copy", dst, func(_ int, store CloudStore) error {
+		return store.CopyContext(ctx, src, dst)
+	})
+}
+
+func (m *Mirror) Move(src, dst string) (err error) {
+	return m.MoveContext(context.Background(), src, dst)
+}
+
+func (m *Mirror) MoveContext(ctx context.Context, src, dst string) (err error) {
+	return m.fanOut("Move", dst, func(_ int, store CloudStore) error {
+		return store.MoveContext(ctx, src, dst)
+	})
+}
+
+func (m *Mirror) UploadMultipart(tmpFile, saveFile string, opts MultipartOptions, headers ...map[string]string) (err error) {
+	return m.UploadMultipartContext(context.Background(), tmpFile, saveFile, opts, headers...)
+}
+
+func (m *Mirror) UploadMultipartContext(ctx context.Context, tmpFile, saveFile string, opts MultipartOptions, headers ...map[string]string) (err error) {
+	return m.fanOut("UploadMultipart", saveFile, func(_ int, store CloudStore) error {
+		return store.UploadMultipartContext(ctx, tmpFile, saveFile, opts, headers...)
+	})
+}
+
+func (m *Mirror) GetSignUploadURL(object string, expire int64, headers ...map[string]string) (upload SignedUpload, err error) {
+	return m.GetSignUploadURLContext(context.Background(), object, expire, headers...)
+}
+
+// 客户端直传只会上传到第一个可用的副本，需要由调用方在上传完成后同步到其他副本
+func (m *Mirror) GetSignUploadURLContext(ctx context.Context, object string, expire int64, headers ...map[string]string) (upload SignedUpload, err error) {
+	err = m.failover(ctx, "GetSignUploadURL", object, func(_ int, store CloudStore) (err error) {
+		upload, err = store.GetSignUploadURLContext(ctx, object, expire, headers...)
+		return
+	})
+	return
+}
+
+func (m *Mirror) PostPolicy(keyPrefix string, maxSize int64, contentTypes []string, expire int64) (form PostForm, err error) {
+	return m.PostPolicyContext(context.Background(), keyPrefix, maxSize, contentTypes, expire)
+}
+
+// 与 GetSignUploadURL 相同，只会上传到第一个可用的副本
+func (m *Mirror) PostPolicyContext(ctx context.Context, keyPrefix string, maxSize int64, contentTypes []string, expire int64) (form PostForm, err error) {
+	err = m.failover(ctx, "PostPolicy", keyPrefix, func(_ int, store CloudStore) (err error) {
+		form, err = store.PostPolicyContext(ctx, keyPrefix, maxSize, contentTypes, expire)
+		return
+	})
+	return
+}
@@ -0,0 +1,134 @@
+package CloudStore
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestMirrorPut(t *testing.T) {
+	a, b := NewMemory("", ""), NewMemory("", "")
+	m := NewMirror(0, a, b)
+	if err := m.Put("a.txt", strings.NewReader(strings.Repeat("hello", 20000)), 100000); err != nil {
+		t.Fatal(err)
+	}
+	for i, store := range []*Memory{a, b} {
+		reader, _, err := store.Get("a.txt")
+		if err != nil {
+			t.Fatalf("replica %v: %v", i, err)
+		}
+		data, _ := ioutil.ReadAll(reader)
+		reader.Close()
+		if len(data) != 100000 {
+			t.Errorf("replica %v: %v bytes", i, len(data))
+		}
+	}
+
+	// 一个副本失败
+	unavailable := newStoreError("memory", "Put", "b.txt", 503, "", "", errors.New("unavailable"))
+	var failed []int
+	m = NewMirror(1, a, &flakyStore{CloudStore: b, failures: 10, err: unavailable})
+	m.OnReplicaError = func(op, key string, replica int, err error) {
+		failed = append(failed, replica)
+	}
+	if err := m.Put("b.txt", strings.NewReader("hello"), 5); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(failed) != "[1]" || a.IsExist("b.txt") != nil {
+		t.Errorf("failed = %v", failed)
+	}
+
+	m.Quorum = 2
+	err := m.Put("c.txt", strings.NewReader("hello"), 5)
+	var e *MirrorError
+	if !errors.As(err, &e) || e.Succeeded != 1 || e.Errors[0] != nil || e.Errors[1] != unavailable {
+		t.Errorf("Put = %#v", err)
+	}
+}
+
+func TestMirrorFailover(t *testing.T) {
+	a, b := NewMemory("", ""), NewMemory("", "")
+	unavailable := newStoreError("memory", "GetInfo", "a.txt", 503, "", "", errors.New("unavailable"))
+	for _, store := range []*Memory{a, b} {
+		if err := store.Put("a.txt", strings.NewReader("hello"), 5); err != nil {
+			t.Fatal(err)
+		}
+	}
+	flaky := &flakyStore{CloudStore: a, failures: 1, err: unavailable}
+	m := NewMirror(0, flaky, b)
+	if _, err := m.GetInfo("a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	// 失败的副本在冷却期间排在后面
+	if _, err := m.GetInfo("a.txt"); err != nil || flaky.calls != 1 {
+		t.Errorf("GetInfo = %v, calls = %v", err, flaky.calls)
+	}
+
+	_, err := m.GetInfo("missing.txt")
+	var e *StoreError
+	if !errors.Is(err, ErrNotExist) || !errors.As(err, &e) {
+		t.Errorf("GetInfo(missing) = %v", err)
+	}
+}
+
+// 条件不满足时不尝试其他副本，也不报告为副本的错误
+func TestMirrorConditionalRead(t *testing.T) {
+	a, b := NewMemory("", ""), NewMemory("", "")
+	for _, store := range []*Memory{a, b} {
+		if err := store.Put("a.txt", strings.NewReader("hello"), 5); err != nil {
+			t.Fatal(err)
+		}
+	}
+	counted := &flakyStore{CloudStore: b}
+	m := NewMirror(0, a, counted)
+	var failed []int
+	m.OnReplicaError = func(op, key string, replica int, err error) {
+		failed = append(failed, replica)
+	}
+	info, err := m.GetInfo("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = m.GetInfoIf("a.txt", Conditions{IfNoneMatch: info.ETag}); !errors.Is(err, ErrNotModified) {
+		t.Errorf("GetInfoIf(If-None-Match) = %v, want ErrNotModified", err)
+	}
+	if _, _, err = m.GetIf("a.txt", Conditions{IfMatch: "other"}); !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("GetIf(If-Match) = %v, want ErrPreconditionFailed", err)
+	}
+	if counted.calls != 0 || len(failed) != 0 {
+		t.Errorf("second replica calls = %v, replica errors = %v", counted.calls, failed)
+	}
+}
+
+func TestMirrorListPage(t *testing.T) {
+	a, b := NewMemory("", ""), NewMemory("", "")
+	m := NewMirror(0, a, b)
+	for _, key := range []string{"a", "b", "c"} {
+		if err := m.Put(key, strings.NewReader(key), 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var names []string
+	marker := ""
+	for {
+		files, next, err := m.ListPage("", marker, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range files {
+			names = append(names, f.Name)
+		}
+		if next == "" {
+			break
+		}
+		marker = next
+	}
+	if fmt.Sprint(names) != "[a b c]" {
+		t.Errorf("ListPage = %v", names)
+	}
+	if _, _, err := m.ListPage("", "invalid", 2); err == nil {
+		t.Error("ListPage with invalid marker succeeded")
+	}
+}
@@ -0,0 +1,250 @@
+package CloudStore
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	defaultPartSize    int64 = 8 << 20  // 默认分片大小 8MB
+	defaultParallel          = 3        // 默认同时上传的分片数
+	maxPartCount             = 10000    // 各云存储一次分片上传最多支持 10000 个分片
+	partSizeUnit       int64 = 1 << 20  // 分片大小取整到 1MB，又拍云要求分片大小为 1MB 的整数倍
+	multipartThreshold int64 = 64 << 20 // Upload 在文件大于 64MB 时自动使用分片上传
+)
+
+// MultipartOptions 分片上传的参数，零值表示使用默认值
+type MultipartOptions struct {
+	PartSize int64 // 分片大小，默认 8MB，分片数超过 10000 时会自动调大
+	Parallel int   // 同时上传的分片数，默认 3
+
+	// 断点续传：上传进度记录在 checkpoint 文件中，上传失败或者进程退出之后，
+	// 再次上传同一个文件会跳过已上传的分片；上传成功后 checkpoint 文件会被删除
+	Checkpoint    bool
+	CheckpointDir string // checkpoint 文件所在目录，默认为待上传文件所在目录
+}
+
+func (opts MultipartOptions) normalize(size int64) MultipartOptions {
+	if opts.PartSize <= 0 {
+		opts.PartSize = defaultPartSize
+	}
+	if opts.Parallel <= 0 {
+		opts.Parallel = defaultParallel
+	}
+	opts.PartSize = (opts.PartSize + partSizeUnit - 1) / partSizeUnit * partSizeUnit
+	for partCount(size, opts.PartSize) > maxPartCount {
+		opts.PartSize *= 2
+	}
+	return opts
+}
+
+// 分片数量，空文件也需要上传一个分片
+func partCount(size, partSize int64) int {
+	count := int((size + partSize - 1) / partSize)
+	if count == 0 {
+		count = 1
+	}
+	return count
+}
+
+// 已上传的分片，Number 从 1 开始
+type uploadedPart struct {
+	Number int    `json:"number"`
+	ETag   string `json:"etag"`
+}
+
+// multipartUploader 为各云存储分片上传的基本操作，
+// 文件的切分、并发上传以及失败时取消上传由 uploadMultipart 统一处理；
+// header 中的 If-Match、If-None-Match 由各云存储在完成上传时检查
+type multipartUploader interface {
+	initMultipart(ctx context.Context, object string, size, partSize int64, header map[string]string) (uploadID string, err error)
+	uploadPart(ctx context.Context, object, uploadID string, number int, reader io.Reader, size int64) (etag string, err error)
+	completeMultipart(ctx context.Context, object, uploadID string, size int64, parts []uploadedPart, header map[string]string) (err error)
+	abortMultipart(ctx context.Context, object, uploadID string) (err error)
+}
+
+// 支持查询已上传分片的云存储实现此接口，断点续传时以服务端的分片为准
+type partLister interface {
+	listParts(ctx context.Context, object, uploadID string) (parts []uploadedPart, err error)
+}
+
+// 文件是否需要使用分片上传
+func useMultipart(tmpFile string) bool {
+	info, err := os.Stat(tmpFile)
+	return err == nil && info.Size() > multipartThreshold
+}
+
+func uploadMultipart(ctx context.Context, u multipartUploader, tmpFile, object string, opts MultipartOptions, headers ...map[string]string) (err error) {
+	var (
+		fp    *os.File
+		info  os.FileInfo
+		cp    *checkpoint
+		parts []uploadedPart
+	)
+	fp, err = os.Open(tmpFile)
+	if err != nil {
+		return
+	}
+	defer fp.Close()
+	info, err = fp.Stat()
+	if err != nil {
+		return
+	}
+
+	size := info.Size()
+	opts = opts.normalize(size)
+	header := mergeHeaders(headers...)
+	object = objectRel(object)
+
+	if opts.Checkpoint {
+		cp, err = resumeCheckpoint(ctx, u, checkpointFile(tmpFile, object, opts.CheckpointDir), object, info, opts.PartSize)
+		if err != nil {
+			return
+		}
+	}
+	if cp == nil || cp.UploadID == "" {
+		var uploadID string
+		uploadID, err = u.initMultipart(ctx, object, size, opts.PartSize, header)
+		if err != nil {
+			return
+		}
+		if cp != nil {
+			cp.UploadID = uploadID
+			if err = cp.save(); err != nil {
+				u.abortMultipart(context.Background(), object, uploadID)
+				return
+			}
+		} else {
+			cp = &checkpoint{Object: object, UploadID: uploadID}
+		}
+	}
+
+	parts, err = uploadParts(ctx, u, fp, cp, size, opts)
+	if err != nil {
+		// 断点续传时保留已上传的分片，否则使用新的 ctx 清理已上传的分片（ctx 可能已经被取消）
+		if !opts.Checkpoint {
+			u.abortMultipart(context.Background(), object, cp.UploadID)
+		}
+		return
+	}
+	if err = u.completeMultipart(ctx, object, cp.UploadID, size, parts, header); err != nil {
+		if !opts.Checkpoint {
+			u.abortMultipart(context.Background(), object, cp.UploadID)
+		}
+		return
+	}
+	if opts.Checkpoint {
+		cp.remove()
+	}
+	return
+}
+
+// 读取 checkpoint 文件，能够继续上传时返回记录了 uploadID 和已上传分片的 checkpoint，
+// 否则返回一个新的 checkpoint，uploadID 为空
+func resumeCheckpoint(ctx context.Context, u multipartUploader, file, object string, info os.FileInfo, partSize int64) (cp *checkpoint, err error) {
+	cp = loadCheckpoint(file)
+	if cp != nil && cp.valid(object, info, partSize) {
+		lister, ok := u.(partLister)
+		if !ok && !cp.expired() {
+			return
+		}
+		if ok {
+			remote, errList := lister.listParts(ctx, object, cp.UploadID)
+			if err = ctx.Err(); err != nil {
+				return
+			}
+			// 查询失败一般是分片上传已经过期或者被取消，重新开始上传
+			if errList == nil {
+				cp.retain(remote)
+				return
+			}
+		}
+	}
+	// 文件已经修改，之前未完成的分片上传不再需要
+	if cp != nil && cp.UploadID != "" && cp.Object == object {
+		u.abortMultipart(ctx, object, cp.UploadID)
+	}
+	cp = &checkpoint{
+		Object:    object,
+		Size:      info.Size(),
+		ModTime:   info.ModTime().UnixNano(),
+		PartSize:  partSize,
+		CreatedAt: time.Now().UnixNano(),
+		file:      file,
+	}
+	return
+}
+
+// 并发上传文件中未上传的分片，任何一个分片失败都会取消其余分片的上传；
+// 断点续传时每个分片上传成功之后都会更新 checkpoint 文件
+func uploadParts(ctx context.Context, u multipartUploader, fp *os.File, cp *checkpoint, size int64, opts MultipartOptions) (parts []uploadedPart, err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg      sync.WaitGroup
+		once    sync.Once
+		numbers = make(chan int)
+		count   = partCount(size, opts.PartSize)
+		done    = cp.done()
+	)
+	parts = make([]uploadedPart, count)
+	for i := 0; i < opts.Parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for number := range numbers {
+				offset := int64(number-1) * opts.PartSize
+				partSize := opts.PartSize
+				if offset+partSize > size {
+					partSize = size - offset
+				}
+				etag, e := u.uploadPart(ctx, cp.Object, cp.UploadID, number, io.NewSectionReader(fp, offset, partSize), partSize)
+				if e == nil && opts.Checkpoint {
+					e = cp.add(uploadedPart{Number: number, ETag: etag})
+				}
+				if e != nil {
+					once.Do(func() {
+						err = e
+						cancel()
+					})
+					return
+				}
+				parts[number-1] = uploadedPart{Number: number, ETag: etag}
+			}
+		}()
+	}
+
+loop:
+	for number := 1; number <= count; number++ {
+		if part, ok := done[number]; ok {
+			parts[number-1] = part
+			continue
+		}
+		select {
+		case numbers <- number:
+		case <-ctx.Done():
+			break loop
+		}
+	}
+	close(numbers)
+	wg.Wait()
+
+	if err == nil {
+		err = ctx.Err()
+	}
+	return
+}
+
+func mergeHeaders(headers ...map[string]string) (header map[string]string) {
+	header = make(map[string]string)
+	for _, h := range headers {
+		for k, v := range h {
+			header[k] = v
+		}
+	}
+	return
+}
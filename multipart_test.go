@@ -0,0 +1,163 @@
+package CloudStore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stubUploader 记录分片上传的调用，completeErr 不为空时完成上传失败
+type stubUploader struct {
+	completeErr    error
+	completeHeader map[string]string
+	aborted        []string
+}
+
+func (u *stubUploader) initMultipart(ctx context.Context, object string, size, partSize int64, header map[string]string) (uploadID string, err error) {
+	return "upload-1", nil
+}
+
+func (u *stubUploader) uploadPart(ctx context.Context, object, uploadID string, number int, reader io.Reader, size int64) (etag string, err error) {
+	_, err = io.Copy(ioutil.Discard, reader)
+	return "etag", err
+}
+
+func (u *stubUploader) completeMultipart(ctx context.Context, object, uploadID string, size int64, parts []uploadedPart, header map[string]string) (err error) {
+	u.completeHeader = header
+	return u.completeErr
+}
+
+func (u *stubUploader) abortMultipart(ctx context.Context, object, uploadID string) (err error) {
+	u.aborted = append(u.aborted, uploadID)
+	return nil
+}
+
+func TestUploadMultipartAbortOnCompleteError(t *testing.T) {
+	tmpFile := filepath.Join(t.TempDir(), "data.bin")
+	if err := ioutil.WriteFile(tmpFile, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	completeErr := errors.New("complete failed")
+	u := &stubUploader{completeErr: completeErr}
+	if err := uploadMultipart(context.Background(), u, tmpFile, "data.bin", MultipartOptions{}); !errors.Is(err, completeErr) {
+		t.Fatalf("uploadMultipart: err = %v, want %v", err, completeErr)
+	}
+	if len(u.aborted) != 1 || u.aborted[0] != "upload-1" {
+		t.Errorf("aborted = %v, want [upload-1]", u.aborted)
+	}
+
+	// 断点续传时保留已上传的分片
+	u = &stubUploader{completeErr: completeErr}
+	opts := MultipartOptions{Checkpoint: true, CheckpointDir: t.TempDir()}
+	if err := uploadMultipart(context.Background(), u, tmpFile, "data.bin", opts); !errors.Is(err, completeErr) {
+		t.Fatalf("uploadMultipart with checkpoint: err = %v, want %v", err, completeErr)
+	}
+	if len(u.aborted) != 0 {
+		t.Errorf("aborted with checkpoint = %v, want none", u.aborted)
+	}
+}
+
+// recordUploader 记录上传成功的分片，failPart 分片上传失败，用于模拟进程在上传过程中退出
+type recordUploader struct {
+	stubUploader
+	lock      sync.Mutex
+	failPart  int
+	inits     int
+	uploaded  []int
+	completed []uploadedPart
+}
+
+func (u *recordUploader) initMultipart(ctx context.Context, object string, size, partSize int64, header map[string]string) (uploadID string, err error) {
+	u.inits++
+	return fmt.Sprintf("upload-%d", time.Now().UnixNano()), nil
+}
+
+func (u *recordUploader) uploadPart(ctx context.Context, object, uploadID string, number int, reader io.Reader, size int64) (etag string, err error) {
+	if number == u.failPart {
+		return "", errors.New("crashed")
+	}
+	if _, err = io.Copy(ioutil.Discard, reader); err != nil {
+		return
+	}
+	u.lock.Lock()
+	u.uploaded = append(u.uploaded, number)
+	u.lock.Unlock()
+	return fmt.Sprintf("etag-%d", number), nil
+}
+
+func (u *recordUploader) completeMultipart(ctx context.Context, object, uploadID string, size int64, parts []uploadedPart, header map[string]string) (err error) {
+	u.completed = parts
+	return nil
+}
+
+// 上传中断之后再次上传同一个文件时，使用 checkpoint 中的 uploadID，只上传剩余的分片
+func TestUploadMultipartResume(t *testing.T) {
+	dir, cpDir := t.TempDir(), t.TempDir()
+	tmpFile := filepath.Join(dir, "data.bin")
+	if err := ioutil.WriteFile(tmpFile, make([]byte, 3<<20+10), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts := MultipartOptions{PartSize: 1 << 20, Parallel: 1, Checkpoint: true, CheckpointDir: cpDir}
+
+	crashed := &recordUploader{failPart: 3}
+	if err := uploadMultipart(context.Background(), crashed, tmpFile, "data.bin", opts); err == nil {
+		t.Fatal("uploadMultipart: err = nil, want crashed")
+	}
+	if fmt.Sprint(crashed.uploaded) != "[1 2]" || len(crashed.aborted) != 0 {
+		t.Fatalf("crashed upload: uploaded %v, aborted %v", crashed.uploaded, crashed.aborted)
+	}
+
+	resumed := &recordUploader{}
+	if err := uploadMultipart(context.Background(), resumed, tmpFile, "data.bin", opts); err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	if resumed.inits != 0 || fmt.Sprint(resumed.uploaded) != "[3 4]" {
+		t.Errorf("resume: inits %v, uploaded %v, want 0 and [3 4]", resumed.inits, resumed.uploaded)
+	}
+	if fmt.Sprint(resumed.completed) != "[{1 etag-1} {2 etag-2} {3 etag-3} {4 etag-4}]" {
+		t.Errorf("resume: completed parts = %v", resumed.completed)
+	}
+	if entries, _ := ioutil.ReadDir(cpDir); len(entries) != 0 {
+		t.Errorf("checkpoint files left after upload: %v", entries)
+	}
+
+	// 文件修改之后重新上传，并取消之前的分片上传
+	crashed = &recordUploader{failPart: 2}
+	if err := uploadMultipart(context.Background(), crashed, tmpFile, "data.bin", opts); err == nil {
+		t.Fatal("uploadMultipart: err = nil, want crashed")
+	}
+	modTime := time.Now().Add(time.Minute)
+	if err := os.Chtimes(tmpFile, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	restarted := &recordUploader{}
+	if err := uploadMultipart(context.Background(), restarted, tmpFile, "data.bin", opts); err != nil {
+		t.Fatalf("restart: %v", err)
+	}
+	if restarted.inits != 1 || len(restarted.uploaded) != 4 || len(restarted.aborted) != 1 {
+		t.Errorf("restart: inits %v, uploaded %v, aborted %v", restarted.inits, restarted.uploaded, restarted.aborted)
+	}
+}
+
+// 条件上传的请求头传给 completeMultipart，由云存储检查
+func TestUploadMultipartConditions(t *testing.T) {
+	tmpFile := filepath.Join(t.TempDir(), "data.bin")
+	if err := ioutil.WriteFile(tmpFile, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	u := &stubUploader{}
+	header := map[string]string{HeaderIfMatch: `"abc"`, HeaderContentType: "text/plain"}
+	if err := uploadMultipart(context.Background(), u, tmpFile, "data.bin", MultipartOptions{}, header); err != nil {
+		t.Fatal(err)
+	}
+	if h := parseHeaders(u.completeHeader); h.IfMatch != `"abc"` || h.ContentType != "text/plain" {
+		t.Errorf("complete header = %v", u.completeHeader)
+	}
+}
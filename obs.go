@@ -1,9 +1,10 @@
 package CloudStore
 
 import (
-	"bytes"
+	"context"
+	"errors"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"net/http"
 	"net/url"
 	"os"
@@ -37,43 +38,280 @@ func NewOBS(accessKey, secretKey, bucket, endpoint, domain string) (o *OBS, err
 	return
 }
 
+// SetOBSLogger 把 obs 包的日志输出到 logger，代替 obs.InitLog 设置的控制台和滚动日志文件，
+// level 为 obs 包的日志级别，如 obs.LEVEL_WARN；logger 为 nil 时关闭 obs 包的日志
+func SetOBSLogger(logger Logger, level obs.Level) {
+	if logger == nil {
+		obs.SetLogSink(obs.LEVEL_OFF, nil)
+		return
+	}
+	obs.SetLogSink(level, func(l obs.Level, msg string) {
+		logger.Log(obsLogLevel(l), msg, "driver", "obs")
+	})
+}
+
+func obsLogLevel(level obs.Level) LogLevel {
+	switch {
+	case level >= obs.LEVEL_ERROR:
+		return LevelError
+	case level >= obs.LEVEL_WARN:
+		return LevelWarn
+	case level >= obs.LEVEL_INFO:
+		return LevelInfo
+	}
+	return LevelDebug
+}
+
 func (o *OBS) IsExist(object string) (err error) {
-	_, err = o.GetInfo(object)
+	return o.IsExistContext(context.Background(), object)
+}
+
+// obs 包不支持 context，以下 XXXContext 方法在 ctx 结束时提前返回
+func (o *OBS) IsExistContext(ctx context.Context, object string) (err error) {
+	defer func() { err = obsError("IsExist", object, err) }()
+	_, err = o.GetInfoContext(ctx, object)
 	return
 }
 
 func (o *OBS) Upload(tmpFile, saveFile string, headers ...map[string]string) (err error) {
-	var p []byte
-	p, err = ioutil.ReadFile(tmpFile)
+	return o.UploadContext(context.Background(), tmpFile, saveFile, headers...)
+}
+
+func (o *OBS) UploadContext(ctx context.Context, tmpFile, saveFile string, headers ...map[string]string) (err error) {
+	defer func() { err = obsError("Upload", saveFile, err) }()
+	if useMultipart(tmpFile) {
+		return o.UploadMultipartContext(ctx, tmpFile, saveFile, MultipartOptions{}, headers...)
+	}
+	var (
+		fp   *os.File
+		info os.FileInfo
+	)
+	fp, err = os.Open(tmpFile)
 	if err != nil {
 		return
 	}
+	defer fp.Close()
+	info, err = fp.Stat()
+	if err != nil {
+		return
+	}
+	return o.PutContext(ctx, saveFile, fp, info.Size(), headers...)
+}
+
+func (o *OBS) Put(object string, reader io.Reader, size int64, headers ...map[string]string) (err error) {
+	return o.PutContext(context.Background(), object, reader, size, headers...)
+}
 
+func (o *OBS) PutContext(ctx context.Context, object string, reader io.Reader, size int64, headers ...map[string]string) (err error) {
+	h := parseHeaders(headers...)
+	defer func() { err = uploadConditionError(obsError("Put", object, err), h) }()
 	input := &obs.PutObjectInput{}
+	input.ObjectOperationInput = obsOperationInput(o.Bucket, objectRel(object), h)
+	input.ContentType = h.ContentType
+	input.ContentMD5 = h.ContentMD5
+	input.ContentEncoding = h.ContentEncoding
+	input.ContentDisposition = h.ContentDisposition
+	input.CacheControl = h.CacheControl
+	input.ContentLanguage = h.ContentLanguage
+	input.HttpExpires = h.Expires
+	if size < 0 {
+		size = readerSize(reader)
+	}
+	if size >= 0 {
+		input.ContentLength = size
+	}
+	// 条件上传的请求头由 OBS 检查，为空时不设置
+	return doContextReader(ctx, reader, func(reader io.Reader) (e error) {
+		input.Body = reader
+		_, e = o.Client.PutObject(input,
+			obs.WithCustomHeader(obs.HEADER_IF_MATCH, quoteETags(h.IfMatch)),
+			obs.WithCustomHeader(obs.HEADER_IF_NONE_MATCH, quoteETags(h.IfNoneMatch)),
+		)
+		return
+	})
+}
+
+// 访问权限、存储类型以及自定义元数据，SDK 会根据签名方式加上 x-obs-meta- 或者 x-amz-meta- 前缀
+func obsOperationInput(bucket, object string, h objectHeader) (input obs.ObjectOperationInput) {
+	input.Bucket = bucket
+	input.Key = object
+	input.ACL = obs.AclType(h.ACL)
+	input.Metadata = h.Metadata
+	if h.StorageClass != "" {
+		input.StorageClass = obs.StorageClassType(obsDialect.nativeStorageClass(h.StorageClass))
+	}
+	return
+}
+
+func (o *OBS) Get(object string) (reader io.ReadCloser, info File, err error) {
+	return o.GetContext(context.Background(), object)
+}
+
+func (o *OBS) GetContext(ctx context.Context, object string) (reader io.ReadCloser, info File, err error) {
+	defer func() { err = obsError("Get", object, err) }()
+	return o.get(ctx, object, Conditions{})
+}
+
+func (o *OBS) GetIf(object string, cond Conditions) (reader io.ReadCloser, info File, err error) {
+	return o.GetIfContext(context.Background(), object, cond)
+}
+
+func (o *OBS) GetIfContext(ctx context.Context, object string, cond Conditions) (reader io.ReadCloser, info File, err error) {
+	defer func() { err = obsError("GetIf", object, err) }()
+	return o.get(ctx, object, cond)
+}
+
+func (o *OBS) get(ctx context.Context, object string, cond Conditions) (reader io.ReadCloser, info File, err error) {
+	input := &obs.GetObjectInput{}
+	input.Key = objectRel(object)
 	input.Bucket = o.Bucket
-	input.Key = objectRel(saveFile)
-	input.Metadata = make(map[string]string)
-	input.Body = bytes.NewBuffer(p)
-
-	for _, header := range headers {
-		for k, v := range header {
-			switch strings.ToLower(k) {
-			case "content-type":
-				input.ContentType = v
-			case "content-encoding":
-				input.ContentEncoding = v
-			case "content-disposition":
-				input.ContentDisposition = v
-			default:
-				input.Metadata[k] = v
-			}
+	if cond.IfMatch != "" {
+		input.IfMatch = quoteETags(cond.IfMatch)
+	}
+	if cond.IfNoneMatch != "" {
+		input.IfNoneMatch = quoteETags(cond.IfNoneMatch)
+	}
+	input.IfModifiedSince = cond.IfModifiedSince
+	input.IfUnmodifiedSince = cond.IfUnmodifiedSince
+
+	var output *obs.GetObjectOutput
+	err = doContext(ctx, func() (e error) {
+		output, e = o.Client.GetObject(input)
+		if e == nil && ctx.Err() != nil {
+			output.Body.Close()
 		}
+		return
+	})
+	if err != nil {
+		return
+	}
+	info = File{
+		Name:    input.Key,
+		Size:    output.ContentLength,
+		ModTime: output.LastModified,
+	}
+	obsDialect.setHeader(&info, obsHeader(output.ResponseHeaders, output.Metadata))
+	info.VersionID = output.VersionId
+	reader = &contextReader{ctx: ctx, ReadCloser: output.Body}
+	return
+}
+
+func (o *OBS) UploadMultipart(tmpFile, saveFile string, opts MultipartOptions, headers ...map[string]string) (err error) {
+	return o.UploadMultipartContext(context.Background(), tmpFile, saveFile, opts, headers...)
+}
+
+func (o *OBS) UploadMultipartContext(ctx context.Context, tmpFile, saveFile string, opts MultipartOptions, headers ...map[string]string) (err error) {
+	h := parseHeaders(headers...)
+	defer func() { err = uploadConditionError(obsError("UploadMultipart", saveFile, err), h) }()
+	return uploadMultipart(ctx, o, tmpFile, saveFile, opts, headers...)
+}
+
+func (o *OBS) initMultipart(ctx context.Context, object string, size, partSize int64, header map[string]string) (uploadID string, err error) {
+	h := parseHeaders(header)
+	input := &obs.InitiateMultipartUploadInput{
+		ObjectOperationInput: obsOperationInput(o.Bucket, object, h),
+		ContentType:          h.ContentType,
+		ContentEncoding:      h.ContentEncoding,
+		ContentDisposition:   h.ContentDisposition,
+		CacheControl:         h.CacheControl,
+		ContentLanguage:      h.ContentLanguage,
+		HttpExpires:          h.Expires,
+	}
+	var output *obs.InitiateMultipartUploadOutput
+	err = doContext(ctx, func() (e error) {
+		output, e = o.Client.InitiateMultipartUpload(input)
+		return
+	})
+	if err != nil {
+		return
+	}
+	uploadID = output.UploadId
+	return
+}
+
+func (o *OBS) uploadPart(ctx context.Context, object, uploadID string, number int, reader io.Reader, size int64) (etag string, err error) {
+	input := &obs.UploadPartInput{
+		Bucket:     o.Bucket,
+		Key:        object,
+		PartNumber: number,
+		UploadId:   uploadID,
+		PartSize:   size,
+	}
+	var output *obs.UploadPartOutput
+	err = doContextReader(ctx, reader, func(reader io.Reader) (e error) {
+		input.Body = reader
+		output, e = o.Client.UploadPart(input)
+		return
+	})
+	if err != nil {
+		return
 	}
-	_, err = o.Client.PutObject(input)
+	etag = output.ETag
 	return
 }
 
+func (o *OBS) completeMultipart(ctx context.Context, object, uploadID string, size int64, parts []uploadedPart, header map[string]string) (err error) {
+	input := &obs.CompleteMultipartUploadInput{
+		Bucket:   o.Bucket,
+		Key:      object,
+		UploadId: uploadID,
+	}
+	for _, part := range parts {
+		input.Parts = append(input.Parts, obs.Part{PartNumber: part.Number, ETag: part.ETag})
+	}
+	h := parseHeaders(header)
+	return doContext(ctx, func() (e error) {
+		_, e = o.Client.CompleteMultipartUpload(input,
+			obs.WithCustomHeader(obs.HEADER_IF_MATCH, quoteETags(h.IfMatch)),
+			obs.WithCustomHeader(obs.HEADER_IF_NONE_MATCH, quoteETags(h.IfNoneMatch)),
+		)
+		return
+	})
+}
+
+func (o *OBS) listParts(ctx context.Context, object, uploadID string) (parts []uploadedPart, err error) {
+	input := &obs.ListPartsInput{
+		Bucket:   o.Bucket,
+		Key:      object,
+		UploadId: uploadID,
+	}
+	for {
+		var output *obs.ListPartsOutput
+		err = doContext(ctx, func() (e error) {
+			output, e = o.Client.ListParts(input)
+			return
+		})
+		if err != nil {
+			return
+		}
+		for _, part := range output.Parts {
+			parts = append(parts, uploadedPart{Number: part.PartNumber, ETag: part.ETag})
+		}
+		if !output.IsTruncated {
+			return
+		}
+		input.PartNumberMarker = output.NextPartNumberMarker
+	}
+}
+
+func (o *OBS) abortMultipart(ctx context.Context, object, uploadID string) (err error) {
+	input := &obs.AbortMultipartUploadInput{
+		Bucket:   o.Bucket,
+		Key:      object,
+		UploadId: uploadID,
+	}
+	return doContext(ctx, func() (e error) {
+		_, e = o.Client.AbortMultipartUpload(input)
+		return
+	})
+}
+
 func (o *OBS) Delete(objects ...string) (err error) {
+	return o.DeleteContext(context.Background(), objects...)
+}
+
+func (o *OBS) DeleteContext(ctx context.Context, objects ...string) (err error) {
+	defer func() { err = obsError("Delete", strings.Join(objects, ", "), err) }()
 	if len(objects) <= 0 {
 		return
 	}
@@ -87,11 +325,62 @@ func (o *OBS) Delete(objects ...string) (err error) {
 		Bucket:  o.Bucket,
 		Objects: objs,
 	}
-	_, err = o.Client.DeleteObjects(input)
-	return
+	return doContext(ctx, func() (e error) {
+		var output *obs.DeleteObjectsOutput
+		if output, e = o.Client.DeleteObjects(input); e != nil {
+			return
+		}
+		// 与其他云存储一致，删除不存在的文件不算失败
+		var errs []error
+		for _, r := range output.Errors {
+			if r.Code != "NoSuchKey" {
+				errs = append(errs, newStoreError("obs", "Delete", r.Key, 0, r.Code, "", errors.New(r.Code+": "+r.Message)))
+			}
+		}
+		return deleteError(errs)
+	})
+}
+
+func (o *OBS) Copy(src, dst string) (err error) {
+	return o.CopyContext(context.Background(), src, dst)
+}
+
+func (o *OBS) CopyContext(ctx context.Context, src, dst string) (err error) {
+	defer func() { err = obsError("Copy", src, err) }()
+	input := &obs.CopyObjectInput{}
+	input.Bucket = o.Bucket
+	input.Key = objectRel(dst)
+	input.CopySourceBucket = o.Bucket
+	input.CopySourceKey = objectRel(src)
+	input.MetadataDirective = obs.CopyMetadata
+	return doContext(ctx, func() (e error) {
+		_, e = o.Client.CopyObject(input)
+		return
+	})
+}
+
+func (o *OBS) Move(src, dst string) (err error) {
+	return o.MoveContext(context.Background(), src, dst)
+}
+
+// OBS 没有移动文件的接口，先复制再删除源文件
+func (o *OBS) MoveContext(ctx context.Context, src, dst string) (err error) {
+	defer func() { err = obsError("Move", src, err) }()
+	if err = o.CopyContext(ctx, src, dst); err != nil {
+		return
+	}
+	return o.DeleteContext(ctx, src)
 }
 
 func (o *OBS) GetSignURL(object string, expire int64) (link string, err error) {
+	return o.GetSignURLContext(context.Background(), object, expire)
+}
+
+func (o *OBS) GetSignURLContext(ctx context.Context, object string, expire int64) (link string, err error) {
+	defer func() { err = obsError("GetSignURL", object, err) }()
+	if err = ctx.Err(); err != nil {
+		return
+	}
 	if expire <= 0 {
 		link = o.Domain + objectAbs(object)
 		return
@@ -116,65 +405,260 @@ func (o *OBS) GetSignURL(object string, expire int64) (link string, err error) {
 	return
 }
 
-func (o *OBS) Download(object string, savePath string) (err error) {
-	input := &obs.GetObjectInput{}
-	input.Key = objectRel(object)
-	input.Bucket = o.Bucket
+func (o *OBS) GetSignUploadURL(object string, expire int64, headers ...map[string]string) (upload SignedUpload, err error) {
+	return o.GetSignUploadURLContext(context.Background(), object, expire, headers...)
+}
 
-	output := &obs.GetObjectOutput{}
-	output, err = o.Client.GetObject(input)
+// 上传签名不替换为绑定的域名，CDN 域名一般不支持 PUT
+func (o *OBS) GetSignUploadURLContext(ctx context.Context, object string, expire int64, headers ...map[string]string) (upload SignedUpload, err error) {
+	defer func() { err = obsError("GetSignUploadURL", object, err) }()
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	if expire <= 0 {
+		expire = oneHour
+	}
+	input := &obs.CreateSignedUrlInput{
+		Method:  obs.HttpMethodPut,
+		Bucket:  o.Bucket,
+		Key:     objectRel(object),
+		Expires: int(expire),
+		Headers: obsDialect.native(parseHeaders(headers...)),
+	}
+	output := &obs.CreateSignedUrlOutput{}
+	output, err = o.Client.CreateSignedUrl(input)
 	if err != nil {
 		return
 	}
-	defer output.Body.Close()
+	upload = SignedUpload{Method: http.MethodPut, URL: output.SignedUrl, Header: make(map[string]string)}
+	for k := range output.ActualSignedRequestHeaders {
+		// Host 由客户端根据 URL 自行设置
+		if strings.ToLower(k) != "host" {
+			upload.Header[k] = output.ActualSignedRequestHeaders.Get(k)
+		}
+	}
+	return
+}
 
-	var b []byte
-	b, err = ioutil.ReadAll(output.Body)
+func (o *OBS) PostPolicy(keyPrefix string, maxSize int64, contentTypes []string, expire int64) (form PostForm, err error) {
+	return o.PostPolicyContext(context.Background(), keyPrefix, maxSize, contentTypes, expire)
+}
+
+// obs 包生成的 policy 只支持完全匹配，这里把 key 固定为 keyPrefix${filename}，
+// 不能限制文件大小，也只能限制一个文件类型，这些限制无法满足时返回错误
+func (o *OBS) PostPolicyContext(ctx context.Context, keyPrefix string, maxSize int64, contentTypes []string, expire int64) (form PostForm, err error) {
+	defer func() { err = obsError("PostPolicy", keyPrefix, err) }()
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	if expire <= 0 {
+		expire = oneHour
+	}
+	if maxSize > 0 {
+		err = errPolicyMaxSize
+		return
+	}
+	if len(contentTypes) > 1 {
+		err = errPolicyContentTypes
+		return
+	}
+	fields := postFields(keyPrefix, contentTypes)
+	input := &obs.CreateBrowserBasedSignatureInput{
+		Bucket:     o.Bucket,
+		Key:        fields["key"],
+		Expires:    int(expire),
+		FormParams: make(map[string]string),
+	}
+	for k, v := range fields {
+		if k != "key" {
+			input.FormParams[k] = v
+		}
+	}
+	var output *obs.CreateBrowserBasedSignatureOutput
+	output, err = o.Client.CreateBrowserBasedSignature(input)
 	if err != nil {
 		return
 	}
+	form = PostForm{
+		URL:    "https://" + o.Bucket + "." + o.Endpoint,
+		Fields: fields,
+	}
+	form.Fields["bucket"] = o.Bucket
+	form.Fields["policy"] = output.Policy
+	form.Fields["X-Amz-Algorithm"] = output.Algorithm
+	form.Fields["X-Amz-Credential"] = output.Credential
+	form.Fields["X-Amz-Date"] = output.Date
+	form.Fields["X-Amz-Signature"] = output.Signature
+	return
+}
+
+func (o *OBS) Download(object string, savePath string) (err error) {
+	return o.DownloadContext(context.Background(), object, savePath)
+}
 
-	return ioutil.WriteFile(savePath, b, os.ModePerm)
+func (o *OBS) DownloadContext(ctx context.Context, object string, savePath string) (err error) {
+	defer func() { err = obsError("Download", object, err) }()
+	var reader io.ReadCloser
+	reader, _, err = o.GetContext(ctx, object)
+	if err != nil {
+		return
+	}
+	defer reader.Close()
+	return saveTo(reader, savePath)
 }
 
 func (o *OBS) GetInfo(object string) (info File, err error) {
+	return o.GetInfoContext(context.Background(), object)
+}
+
+func (o *OBS) GetInfoContext(ctx context.Context, object string) (info File, err error) {
+	defer func() { err = obsError("GetInfo", object, err) }()
+	return o.getInfo(ctx, object, Conditions{})
+}
+
+// GetObjectMetadataInput 中没有条件请求的参数，条件请求头由 OBS 检查，为空时不设置
+func (o *OBS) getInfo(ctx context.Context, object string, cond Conditions) (info File, err error) {
 	input := &obs.GetObjectMetadataInput{
 		Bucket: o.Bucket,
 		Key:    objectRel(object),
 	}
+	header := cond.header()
 	output := &obs.GetObjectMetadataOutput{}
-	output, err = o.Client.GetObjectMetadata(input)
+	err = doContext(ctx, func() (e error) {
+		output, e = o.Client.GetObjectMetadata(input,
+			obs.WithCustomHeader(obs.HEADER_IF_MATCH, header.Get(obs.HEADER_IF_MATCH)),
+			obs.WithCustomHeader(obs.HEADER_IF_NONE_MATCH, header.Get(obs.HEADER_IF_NONE_MATCH)),
+			obs.WithCustomHeader(obs.HEADER_IF_MODIFIED_SINCE, header.Get(obs.HEADER_IF_MODIFIED_SINCE)),
+			obs.WithCustomHeader(obs.HEADER_IF_UNMODIFIED_SINCE, header.Get(obs.HEADER_IF_UNMODIFIED_SINCE)),
+		)
+		return
+	})
 	if err != nil {
 		return
 	}
 	info = File{
 		Name:    objectRel(object),
 		Size:    output.ContentLength,
-		IsDir:   output.ContentLength == 0,
+		IsDir:   isDirKey(object),
 		ModTime: output.LastModified,
 	}
+	// obs 包去掉了响应头的 x-obs- 前缀，由 obsHeader 转换为统一的 header；版本号由 obs 包解析到 VersionId 中
+	obsDialect.setHeader(&info, obsHeader(output.ResponseHeaders, output.Metadata))
+	info.VersionID = output.VersionId
 	return
 }
 
+func (o *OBS) GetInfoIf(object string, cond Conditions) (info File, err error) {
+	return o.GetInfoIfContext(context.Background(), object, cond)
+}
+
+func (o *OBS) GetInfoIfContext(ctx context.Context, object string, cond Conditions) (info File, err error) {
+	defer func() { err = obsError("GetInfoIf", object, err) }()
+	return o.getInfo(ctx, object, cond)
+}
+
+// obs 包返回的响应头为小写并且去掉了 x-amz- 前缀，自定义元数据在 metadata 中
+func obsHeader(responseHeaders map[string][]string, metadata map[string]string) map[string]string {
+	header := make(map[string]string)
+	for k, v := range responseHeaders {
+		switch {
+		case len(v) == 0 || strings.HasPrefix(k, "meta-"):
+		case k == "storage-class":
+			header[HeaderStorageClass] = obsStorageClass(v[0])
+		default:
+			header[http.CanonicalHeaderKey(k)] = v[0]
+		}
+	}
+	for k, v := range metadata {
+		header[MetaPrefix+http.CanonicalHeaderKey(k)] = v
+	}
+	return header
+}
+
+// 使用 AWS 签名时返回的存储类型是 STANDARD_IA、GLACIER
+func obsStorageClass(class string) string {
+	if c := string(obs.ParseStringToStorageClassType(class)); c != "" {
+		class = c
+	}
+	return obsDialect.canonicalStorageClass(class)
+}
+
 func (o *OBS) Lists(prefix string) (files []File, err error) {
-	prefix = objectRel(prefix)
+	return o.ListsContext(context.Background(), prefix)
+}
+
+func (o *OBS) ListsContext(ctx context.Context, prefix string) (files []File, err error) {
+	defer func() { err = obsError("Lists", prefix, err) }()
+	return listAll(ctx, o, prefix)
+}
+
+func (o *OBS) ListPage(prefix, marker string, limit int) (files []File, nextMarker string, err error) {
+	return o.ListPageContext(context.Background(), prefix, marker, limit)
+}
+
+func (o *OBS) ListPageContext(ctx context.Context, prefix, marker string, limit int) (files []File, nextMarker string, err error) {
+	defer func() { err = obsError("ListPage", prefix, err) }()
+	return o.list(ctx, objectRel(prefix), "", marker, limit)
+}
+
+func (o *OBS) ListDir(dir, marker string, limit int) (files []File, nextMarker string, err error) {
+	return o.ListDirContext(context.Background(), dir, marker, limit)
+}
+
+func (o *OBS) ListDirContext(ctx context.Context, dir, marker string, limit int) (files []File, nextMarker string, err error) {
+	defer func() { err = obsError("ListDir", dir, err) }()
+	return o.list(ctx, dirPrefix(dir), delimiter, marker, limit)
+}
+
+func (o *OBS) list(ctx context.Context, prefix, delim, marker string, limit int) (files []File, nextMarker string, err error) {
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
 	input := &obs.ListObjectsInput{}
 	input.Prefix = prefix
+	input.Delimiter = delim
 	input.Bucket = o.Bucket
+	input.Marker = marker
+	input.MaxKeys = limit
 	output := &obs.ListObjectsOutput{}
-	output, err = o.Client.ListObjects(input)
+	err = doContext(ctx, func() (e error) {
+		output, e = o.Client.ListObjects(input)
+		return
+	})
 	if err != nil {
 		return
 	}
 
+	for _, dir := range output.CommonPrefixes {
+		files = append(files, dirFile(dir))
+	}
 	for _, item := range output.Contents {
+		if delim != "" && item.Key == prefix {
+			continue
+		}
 		files = append(files, File{
-			ModTime: item.LastModified,
-			Name:    objectRel(item.Key),
-			Size:    item.Size,
-			IsDir:   item.Size == 0,
+			ModTime:      item.LastModified,
+			Name:         objectRel(item.Key),
+			Size:         item.Size,
+			IsDir:        isDirKey(item.Key),
+			ETag:         strings.Trim(item.ETag, `"`),
+			StorageClass: obsStorageClass(string(item.StorageClass)),
 		})
 	}
+	if output.IsTruncated {
+		nextMarker = output.NextMarker
+	}
 
 	return
 }
+
+// 将 SDK 返回的错误转换为 StoreError
+func obsError(op, object string, err error) error {
+	if keepError(err) {
+		return err
+	}
+	if e, ok := err.(obs.ObsError); ok {
+		return newStoreError("obs", op, object, e.StatusCode, e.Code, e.RequestId, err)
+	}
+	return newStoreError("obs", op, object, 0, "", "", err)
+}
@@ -626,12 +626,12 @@ func (obsClient ObsClient) RestoreObject(input *RestoreObjectInput) (output *Bas
 	return
 }
 
-func (obsClient ObsClient) GetObjectMetadata(input *GetObjectMetadataInput) (output *GetObjectMetadataOutput, err error) {
+func (obsClient ObsClient) GetObjectMetadata(input *GetObjectMetadataInput, extensions ...extensionOptions) (output *GetObjectMetadataOutput, err error) {
 	if input == nil {
 		return nil, errors.New("GetObjectMetadataInput is nil")
 	}
 	output = &GetObjectMetadataOutput{}
-	err = obsClient.doActionWithBucketAndKey("GetObjectMetadata", HTTP_HEAD, input.Bucket, input.Key, input, output)
+	err = obsClient.doActionWithBucketAndKey("GetObjectMetadata", HTTP_HEAD, input.Bucket, input.Key, input, output, extensions...)
 	if err != nil {
 		output = nil
 	} else {
@@ -654,7 +654,7 @@ func (obsClient ObsClient) GetObject(input *GetObjectInput) (output *GetObjectOu
 	return
 }
 
-func (obsClient ObsClient) PutObject(input *PutObjectInput) (output *PutObjectOutput, err error) {
+func (obsClient ObsClient) PutObject(input *PutObjectInput, extensions ...extensionOptions) (output *PutObjectOutput, err error) {
 	if input == nil {
 		return nil, errors.New("PutObjectInput is nil")
 	}
@@ -674,9 +674,9 @@ func (obsClient ObsClient) PutObject(input *PutObjectInput) (output *PutObjectOu
 		}
 	}
 	if repeatable {
-		err = obsClient.doActionWithBucketAndKey("PutObject", HTTP_PUT, input.Bucket, input.Key, input, output)
+		err = obsClient.doActionWithBucketAndKey("PutObject", HTTP_PUT, input.Bucket, input.Key, input, output, extensions...)
 	} else {
-		err = obsClient.doActionWithBucketAndKeyUnRepeatable("PutObject", HTTP_PUT, input.Bucket, input.Key, input, output)
+		err = obsClient.doActionWithBucketAndKeyUnRepeatable("PutObject", HTTP_PUT, input.Bucket, input.Key, input, output, extensions...)
 	}
 	if err != nil {
 		output = nil
@@ -853,7 +853,7 @@ func (obsClient ObsClient) UploadPart(input *UploadPartInput) (output *UploadPar
 	return
 }
 
-func (obsClient ObsClient) CompleteMultipartUpload(input *CompleteMultipartUploadInput) (output *CompleteMultipartUploadOutput, err error) {
+func (obsClient ObsClient) CompleteMultipartUpload(input *CompleteMultipartUploadInput, extensions ...extensionOptions) (output *CompleteMultipartUploadOutput, err error) {
 	if input == nil {
 		return nil, errors.New("CompleteMultipartUploadInput is nil")
 	}
@@ -866,7 +866,7 @@ func (obsClient ObsClient) CompleteMultipartUpload(input *CompleteMultipartUploa
 	sort.Sort(parts)
 
 	output = &CompleteMultipartUploadOutput{}
-	err = obsClient.doActionWithBucketAndKey("CompleteMultipartUpload", HTTP_POST, input.Bucket, input.Key, input, output)
+	err = obsClient.doActionWithBucketAndKey("CompleteMultipartUpload", HTTP_POST, input.Bucket, input.Key, input, output, extensions...)
 	if err != nil {
 		output = nil
 	} else {
@@ -0,0 +1,15 @@
+package obs
+
+type extensionOptions interface{}
+
+type extensionHeaders func(headers map[string][]string, isObs bool) error
+
+// WithCustomHeader 为请求加上额外的请求头，value 为空时不设置
+func WithCustomHeader(key string, value string) extensionHeaders {
+	return func(headers map[string][]string, isObs bool) error {
+		if value != "" {
+			headers[key] = []string{value}
+		}
+		return nil
+	}
+}
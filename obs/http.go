@@ -58,25 +58,25 @@ func (obsClient ObsClient) doActionWithBucket(action, method, bucketName string,
 	return obsClient.doAction(action, method, bucketName, "", input, output, true, true)
 }
 
-func (obsClient ObsClient) doActionWithBucketAndKey(action, method, bucketName, objectKey string, input ISerializable, output IBaseModel) error {
-	return obsClient._doActionWithBucketAndKey(action, method, bucketName, objectKey, input, output, true)
+func (obsClient ObsClient) doActionWithBucketAndKey(action, method, bucketName, objectKey string, input ISerializable, output IBaseModel, extensions ...extensionOptions) error {
+	return obsClient._doActionWithBucketAndKey(action, method, bucketName, objectKey, input, output, true, extensions...)
 }
 
-func (obsClient ObsClient) doActionWithBucketAndKeyUnRepeatable(action, method, bucketName, objectKey string, input ISerializable, output IBaseModel) error {
-	return obsClient._doActionWithBucketAndKey(action, method, bucketName, objectKey, input, output, false)
+func (obsClient ObsClient) doActionWithBucketAndKeyUnRepeatable(action, method, bucketName, objectKey string, input ISerializable, output IBaseModel, extensions ...extensionOptions) error {
+	return obsClient._doActionWithBucketAndKey(action, method, bucketName, objectKey, input, output, false, extensions...)
 }
 
-func (obsClient ObsClient) _doActionWithBucketAndKey(action, method, bucketName, objectKey string, input ISerializable, output IBaseModel, repeatable bool) error {
+func (obsClient ObsClient) _doActionWithBucketAndKey(action, method, bucketName, objectKey string, input ISerializable, output IBaseModel, repeatable bool, extensions ...extensionOptions) error {
 	if strings.TrimSpace(bucketName) == "" && !obsClient.conf.cname{
 		return errors.New("Bucket is empty")
 	}
 	if strings.TrimSpace(objectKey) == "" {
 		return errors.New("Key is empty")
 	}
-	return obsClient.doAction(action, method, bucketName, objectKey, input, output, true, repeatable)
+	return obsClient.doAction(action, method, bucketName, objectKey, input, output, true, repeatable, extensions...)
 }
 
-func (obsClient ObsClient) doAction(action, method, bucketName, objectKey string, input ISerializable, output IBaseModel, xmlResult bool, repeatable bool) error {
+func (obsClient ObsClient) doAction(action, method, bucketName, objectKey string, input ISerializable, output IBaseModel, xmlResult bool, repeatable bool, extensions ...extensionOptions) error {
 
 	var resp *http.Response
 	var respError error
@@ -92,6 +92,14 @@ func (obsClient ObsClient) doAction(action, method, bucketName, objectKey string
 		headers = make(map[string][]string)
 	}
 
+	for _, extension := range extensions {
+		if extensionHeader, ok := extension.(extensionHeaders); ok {
+			if err := extensionHeader(headers, obsClient.conf.signature == SignatureObs); err != nil {
+				doLog(LEVEL_WARN, "Set header with error: %v", err)
+			}
+		}
+	}
+
 	switch method {
 	case HTTP_GET:
 		resp, respError = obsClient.doHttpGet(bucketName, objectKey, params, headers, data, repeatable)
@@ -134,6 +134,7 @@ func (lw *loggerWrapper) Printf(format string, v ...interface{}) {
 
 var consoleLogger *log.Logger
 var fileLogger *loggerWrapper
+var logSink func(level Level, msg string)
 var lock *sync.RWMutex = new(sync.RWMutex)
 
 func isDebugLogEnabled() bool {
@@ -158,6 +159,7 @@ func reset() {
 		fileLogger = nil
 	}
 	consoleLogger = nil
+	logSink = nil
 	logConf = getDefaultLogConf()
 }
 
@@ -236,6 +238,14 @@ func InitLogWithCacheCnt(logFullPath string, maxLogSize int64, backups int, leve
 	return nil
 }
 
+func SetLogSink(level Level, sink func(level Level, msg string)) {
+	lock.Lock()
+	defer lock.Unlock()
+	reset()
+	logConf.level = level
+	logSink = sink
+}
+
 func CloseLog() {
 	if logEnabled() {
 		lock.Lock()
@@ -248,11 +258,11 @@ func SyncLog() {
 }
 
 func logEnabled() bool {
-	return consoleLogger != nil || fileLogger != nil
+	return consoleLogger != nil || fileLogger != nil || logSink != nil
 }
 
 func DoLog(level Level, format string, v ...interface{}) {
-	doLog(level, format, v)
+	doLog(level, format, v...)
 }
 
 func doLog(level Level, format string, v ...interface{}) {
@@ -265,6 +275,10 @@ func doLog(level Level, format string, v ...interface{}) {
 			}
 			msg = fmt.Sprintf("%s:%d|%s", file, line, msg)
 		}
+		if logSink != nil {
+			logSink(level, msg)
+			return
+		}
 		prefix := logLevelMap[level]
 		if consoleLogger != nil {
 			consoleLogger.Printf("%s%s", prefix, msg)
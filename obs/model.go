@@ -768,6 +768,9 @@ type PutObjectBasicInput struct {
 	ContentLength       int64
 	ContentEncoding     string
 	ContentDisposition  string
+	CacheControl        string
+	ContentLanguage     string
+	HttpExpires         string
 }
 
 type PutObjectInput struct {
@@ -826,7 +829,12 @@ type AbortMultipartUploadInput struct {
 
 type InitiateMultipartUploadInput struct {
 	ObjectOperationInput
-	ContentType string
+	ContentType        string
+	ContentEncoding    string
+	ContentDisposition string
+	CacheControl       string
+	ContentLanguage    string
+	HttpExpires        string
 }
 
 type InitiateMultipartUploadOutput struct {
@@ -4,10 +4,10 @@ import (
 	"bytes"
 	"fmt"
 	"io/ioutil"
-	"obs"
 	"os"
 	"testing"
 
+	"github.com/TruthHun/CloudStore/obs"
 	"github.com/astaxie/beego"
 )
 
@@ -19,6 +19,10 @@ func init() {
 	bucket := beego.AppConfig.String("obs::bucket")
 	endpoint := beego.AppConfig.String("obs::endpoint")
 	domain := beego.AppConfig.String("obs::domain")
+	// 没有配置 obs 时跳过测试
+	if accessKey == "" {
+		return
+	}
 	Obs, err = NewOBS(accessKey, secretKey, bucket, endpoint, domain)
 	if err != nil {
 		panic(err)
@@ -26,6 +30,9 @@ func init() {
 }
 
 func TestOBS(t *testing.T) {
+	if Obs == nil {
+		t.Skip("obs is not configured")
+	}
 	// upload
 	t.Log("=====Upload=====", objectSVG, objectSVGGzip)
 	err = Obs.Upload(objectSVG, objectSVG)
@@ -67,6 +74,9 @@ func TestOBS(t *testing.T) {
 }
 
 func TestOBS_Upload(t *testing.T) {
+	if Obs == nil {
+		t.Skip("obs is not configured")
+	}
 	input := &obs.PutObjectInput{}
 	input.Bucket = Obs.Bucket
 	input.Key = "official.html"
@@ -83,6 +93,9 @@ func TestOBS_Upload(t *testing.T) {
 }
 
 func TestOBS_Delete(t *testing.T) {
+	if Obs == nil {
+		t.Skip("obs is not configured")
+	}
 	if err := Obs.Delete(objectSVG, objectSVGGzip, objectHtml, objectHtmlGzip); err != nil {
 		t.Error(err)
 	} else {
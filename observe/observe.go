@@ -0,0 +1,399 @@
+// Package observe 为 CloudStore 加上 Prometheus 指标、OpenTelemetry 链路追踪以及结构化日志，
+// 单独成包以免只使用云存储的程序也要引入 Prometheus 和 OpenTelemetry。
+package observe
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/TruthHun/CloudStore"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Metrics 为云存储操作的 Prometheus 指标，多个云存储可以共用同一个 Metrics，以 driver 标签区分
+type Metrics struct {
+	Duration *prometheus.HistogramVec // cloudstore_operation_duration_seconds{driver, op}，操作耗时
+	Bytes    *prometheus.CounterVec   // cloudstore_bytes_total{driver, op}，上传和下载的字节数
+	Errors   *prometheus.CounterVec   // cloudstore_errors_total{driver, op, kind}，kind 为 not_exist、permission、throttled、exist、precondition_failed、not_modified、canceled 或 other
+}
+
+// NewMetrics 创建指标并注册到 reg，reg 为 nil 时使用 prometheus.DefaultRegisterer；
+// 指标已经注册过时复用已注册的指标
+func NewMetrics(reg prometheus.Registerer) (m *Metrics, err error) {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	m = &Metrics{
+		Duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "cloudstore_operation_duration_seconds",
+			Help:    "Duration of cloud storage operations.",
+			Buckets: prometheus.ExponentialBuckets(0.005, 2, 14),
+		}, []string{"driver", "op"}),
+		Bytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "cloudstore_bytes_total",
+			Help: "Bytes uploaded to or downloaded from cloud storage.",
+		}, []string{"driver", "op"}),
+		Errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "cloudstore_errors_total",
+			Help: "Failed cloud storage operations.",
+		}, []string{"driver", "op", "kind"}),
+	}
+	var c prometheus.Collector
+	if c, err = registerCollector(reg, m.Duration); err != nil {
+		return nil, err
+	}
+	m.Duration = c.(*prometheus.HistogramVec)
+	if c, err = registerCollector(reg, m.Bytes); err != nil {
+		return nil, err
+	}
+	m.Bytes = c.(*prometheus.CounterVec)
+	if c, err = registerCollector(reg, m.Errors); err != nil {
+		return nil, err
+	}
+	m.Errors = c.(*prometheus.CounterVec)
+	return
+}
+
+func registerCollector(reg prometheus.Registerer, c prometheus.Collector) (collector prometheus.Collector, err error) {
+	if err = reg.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			return are.ExistingCollector, nil
+		}
+	}
+	return c, err
+}
+
+// Options 为 Wrap 的参数，零值表示使用默认值
+type Options struct {
+	Driver  string            // 指标、链路和日志中的 driver，默认为云存储类型名称的小写，如 oss、qiniu
+	Metrics *Metrics          // 为 nil 时不统计指标
+	Tracer  trace.Tracer      // 默认使用 otel 全局的 TracerProvider
+	Logger  CloudStore.Logger // 为 nil 时不输出日志；成功的操作为 debug，文件不存在为 info，其他错误为 error
+}
+
+// observedStore 记录每个操作的耗时、传输的字节数以及错误，并为每个操作创建一个 span
+type observedStore struct {
+	store  CloudStore.CloudStore
+	driver string
+	opts   Options
+}
+
+// Wrap 为 store 加上指标、链路追踪以及结构化日志
+func Wrap(store CloudStore.CloudStore, opts Options) CloudStore.CloudStore {
+	if opts.Driver == "" {
+		opts.Driver = strings.ToLower(reflect.Indirect(reflect.ValueOf(store)).Type().Name())
+	}
+	if opts.Tracer == nil {
+		opts.Tracer = otel.Tracer("github.com/TruthHun/CloudStore")
+	}
+	return &observedStore{store: store, driver: opts.Driver, opts: opts}
+}
+
+// 错误类型，作为 cloudstore_errors_total 的 kind 标签
+func errorKindLabel(err error) string {
+	switch {
+	case errors.Is(err, CloudStore.ErrNotExist):
+		return "not_exist"
+	case errors.Is(err, CloudStore.ErrPermission):
+		return "permission"
+	case errors.Is(err, CloudStore.ErrThrottled):
+		return "throttled"
+	case errors.Is(err, CloudStore.ErrExist):
+		return "exist"
+	case errors.Is(err, CloudStore.ErrPreconditionFailed):
+		return "precondition_failed"
+	case errors.Is(err, CloudStore.ErrNotModified):
+		return "not_modified"
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return "canceled"
+	}
+	return "other"
+}
+
+// start 开始一个操作，操作结束时调用返回的 done，n 为传输的字节数，小于 0 表示不统计
+func (o *observedStore) start(ctx context.Context, op, key string) (context.Context, func(n int64, err error)) {
+	ctx, span := o.opts.Tracer.Start(ctx, "CloudStore."+op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("cloudstore.driver", o.driver),
+			attribute.String("cloudstore.op", op),
+			attribute.String("cloudstore.key", key),
+		),
+	)
+	begin := time.Now()
+	return ctx, func(n int64, err error) {
+		elapsed := time.Since(begin)
+		if n >= 0 {
+			span.SetAttributes(attribute.Int64("cloudstore.bytes", n))
+		}
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+
+		if m := o.opts.Metrics; m != nil {
+			m.Duration.WithLabelValues(o.driver, op).Observe(elapsed.Seconds())
+			if n > 0 && err == nil {
+				m.Bytes.WithLabelValues(o.driver, op).Add(float64(n))
+			}
+			if err != nil {
+				m.Errors.WithLabelValues(o.driver, op, errorKindLabel(err)).Inc()
+			}
+		}
+
+		if o.opts.Logger == nil {
+			return
+		}
+		level := CloudStore.LevelDebug
+		keyvals := []interface{}{"driver", o.driver, "op", op, "key", key, "duration", elapsed}
+		if n >= 0 {
+			keyvals = append(keyvals, "bytes", n)
+		}
+		if err != nil {
+			level = CloudStore.LevelError
+			if errors.Is(err, CloudStore.ErrNotExist) || errors.Is(err, CloudStore.ErrPreconditionFailed) || errors.Is(err, CloudStore.ErrNotModified) {
+				level = CloudStore.LevelInfo
+			}
+			keyvals = append(keyvals, "error", err)
+			var e *CloudStore.StoreError
+			if errors.As(err, &e) && e.StatusCode > 0 {
+				keyvals = append(keyvals, "status", e.StatusCode, "request_id", e.RequestID)
+			}
+		}
+		o.opts.Logger.Log(level, "cloudstore "+op, keyvals...)
+	}
+}
+
+// 文件大小，获取失败时为 -1
+func fileSize(name string) int64 {
+	info, err := os.Stat(name)
+	if err != nil {
+		return -1
+	}
+	return info.Size()
+}
+
+func (o *observedStore) Delete(objects ...string) (err error) {
+	return o.DeleteContext(context.Background(), objects...)
+}
+
+func (o *observedStore) DeleteContext(ctx context.Context, objects ...string) (err error) {
+	ctx, done := o.start(ctx, "Delete", strings.Join(objects, ","))
+	defer func() { done(-1, err) }()
+	return o.store.DeleteContext(ctx, objects...)
+}
+
+func (o *observedStore) GetSignURL(object string, expire int64) (link string, err error) {
+	return o.GetSignURLContext(context.Background(), object, expire)
+}
+
+func (o *observedStore) GetSignURLContext(ctx context.Context, object string, expire int64) (link string, err error) {
+	ctx, done := o.start(ctx, "GetSignURL", object)
+	defer func() { done(-1, err) }()
+	return o.store.GetSignURLContext(ctx, object, expire)
+}
+
+func (o *observedStore) IsExist(object string) (err error) {
+	return o.IsExistContext(context.Background(), object)
+}
+
+func (o *observedStore) IsExistContext(ctx context.Context, object string) (err error) {
+	ctx, done := o.start(ctx, "IsExist", object)
+	defer func() { done(-1, err) }()
+	return o.store.IsExistContext(ctx, object)
+}
+
+func (o *observedStore) Lists(prefix string) (files []CloudStore.File, err error) {
+	return o.ListsContext(context.Background(), prefix)
+}
+
+func (o *observedStore) ListsContext(ctx context.Context, prefix string) (files []CloudStore.File, err error) {
+	ctx, done := o.start(ctx, "Lists", prefix)
+	defer func() { done(-1, err) }()
+	return o.store.ListsContext(ctx, prefix)
+}
+
+func (o *observedStore) Upload(tmpFile string, saveFile string, headers ...map[string]string) (err error) {
+	return o.UploadContext(context.Background(), tmpFile, saveFile, headers...)
+}
+
+func (o *observedStore) UploadContext(ctx context.Context, tmpFile string, saveFile string, headers ...map[string]string) (err error) {
+	ctx, done := o.start(ctx, "Upload", saveFile)
+	defer func() { done(fileSize(tmpFile), err) }()
+	return o.store.UploadContext(ctx, tmpFile, saveFile, headers...)
+}
+
+func (o *observedStore) Download(object string, savePath string) (err error) {
+	return o.DownloadContext(context.Background(), object, savePath)
+}
+
+func (o *observedStore) DownloadContext(ctx context.Context, object string, savePath string) (err error) {
+	ctx, done := o.start(ctx, "Download", object)
+	defer func() {
+		n := int64(-1)
+		if err == nil {
+			n = fileSize(savePath)
+		}
+		done(n, err)
+	}()
+	return o.store.DownloadContext(ctx, object, savePath)
+}
+
+func (o *observedStore) GetInfo(object string) (info CloudStore.File, err error) {
+	return o.GetInfoContext(context.Background(), object)
+}
+
+func (o *observedStore) GetInfoContext(ctx context.Context, object string) (info CloudStore.File, err error) {
+	ctx, done := o.start(ctx, "GetInfo", object)
+	defer func() { done(-1, err) }()
+	return o.store.GetInfoContext(ctx, object)
+}
+
+func (o *observedStore) GetInfoIf(object string, cond CloudStore.Conditions) (info CloudStore.File, err error) {
+	return o.GetInfoIfContext(context.Background(), object, cond)
+}
+
+func (o *observedStore) GetInfoIfContext(ctx context.Context, object string, cond CloudStore.Conditions) (info CloudStore.File, err error) {
+	ctx, done := o.start(ctx, "GetInfoIf", object)
+	defer func() { done(-1, err) }()
+	return o.store.GetInfoIfContext(ctx, object, cond)
+}
+
+func (o *observedStore) Put(object string, reader io.Reader, size int64, headers ...map[string]string) (err error) {
+	return o.PutContext(context.Background(), object, reader, size, headers...)
+}
+
+// reader 原样传给云存储，不做包装，以免丢失 io.Seeker 等接口
+func (o *observedStore) PutContext(ctx context.Context, object string, reader io.Reader, size int64, headers ...map[string]string) (err error) {
+	ctx, done := o.start(ctx, "Put", object)
+	defer func() { done(size, err) }()
+	return o.store.PutContext(ctx, object, reader, size, headers...)
+}
+
+func (o *observedStore) Get(object string) (reader io.ReadCloser, info CloudStore.File, err error) {
+	return o.GetContext(context.Background(), object)
+}
+
+// span 在获取到 reader 时结束，读取的字节数在 reader 关闭时计入 cloudstore_bytes_total
+func (o *observedStore) GetContext(ctx context.Context, object string) (reader io.ReadCloser, info CloudStore.File, err error) {
+	ctx, done := o.start(ctx, "Get", object)
+	defer func() { done(-1, err) }()
+	reader, info, err = o.store.GetContext(ctx, object)
+	if err == nil && o.opts.Metrics != nil {
+		reader = &countingReader{ReadCloser: reader, counter: o.opts.Metrics.Bytes.WithLabelValues(o.driver, "Get")}
+	}
+	return
+}
+
+func (o *observedStore) GetIf(object string, cond CloudStore.Conditions) (reader io.ReadCloser, info CloudStore.File, err error) {
+	return o.GetIfContext(context.Background(), object, cond)
+}
+
+func (o *observedStore) GetIfContext(ctx context.Context, object string, cond CloudStore.Conditions) (reader io.ReadCloser, info CloudStore.File, err error) {
+	ctx, done := o.start(ctx, "GetIf", object)
+	defer func() { done(-1, err) }()
+	reader, info, err = o.store.GetIfContext(ctx, object, cond)
+	if err == nil && o.opts.Metrics != nil {
+		reader = &countingReader{ReadCloser: reader, counter: o.opts.Metrics.Bytes.WithLabelValues(o.driver, "GetIf")}
+	}
+	return
+}
+
+type countingReader struct {
+	io.ReadCloser
+	counter prometheus.Counter
+	n       int64
+}
+
+func (r *countingReader) Read(p []byte) (n int, err error) {
+	n, err = r.ReadCloser.Read(p)
+	r.n += int64(n)
+	return
+}
+
+func (r *countingReader) Close() error {
+	r.counter.Add(float64(r.n))
+	r.n = 0
+	return r.ReadCloser.Close()
+}
+
+func (o *observedStore) ListPage(prefix, marker string, limit int) (files []CloudStore.File, nextMarker string, err error) {
+	return o.ListPageContext(context.Background(), prefix, marker, limit)
+}
+
+func (o *observedStore) ListPageContext(ctx context.Context, prefix, marker string, limit int) (files []CloudStore.File, nextMarker string, err error) {
+	ctx, done := o.start(ctx, "ListPage", prefix)
+	defer func() { done(-1, err) }()
+	return o.store.ListPageContext(ctx, prefix, marker, limit)
+}
+
+func (o *observedStore) ListDir(dir, marker string, limit int) (files []CloudStore.File, nextMarker string, err error) {
+	return o.ListDirContext(context.Background(), dir, marker, limit)
+}
+
+func (o *observedStore) ListDirContext(ctx context.Context, dir, marker string, limit int) (files []CloudStore.File, nextMarker string, err error) {
+	ctx, done := o.start(ctx, "ListDir", dir)
+	defer func() { done(-1, err) }()
+	return o.store.ListDirContext(ctx, dir, marker, limit)
+}
+
+func (o *observedStore) Copy(src, dst string) (err error) {
+	return o.CopyContext(context.Background(), src, dst)
+}
+
+func (o *observedStore) CopyContext(ctx context.Context, src, dst string) (err error) {
+	ctx, done := o.start(ctx, "Copy", dst)
+	defer func() { done(-1, err) }()
+	return o.store.CopyContext(ctx, src, dst)
+}
+
+func (o *observedStore) Move(src, dst string) (err error) {
+	return o.MoveContext(context.Background(), src, dst)
+}
+
+func (o *observedStore) MoveContext(ctx context.Context, src, dst string) (err error) {
+	ctx, done := o.start(ctx, "Move", dst)
+	defer func() { done(-1, err) }()
+	return o.store.MoveContext(ctx, src, dst)
+}
+
+func (o *observedStore) UploadMultipart(tmpFile, saveFile string, opts CloudStore.MultipartOptions, headers ...map[string]string) (err error) {
+	return o.UploadMultipartContext(context.Background(), tmpFile, saveFile, opts, headers...)
+}
+
+func (o *observedStore) UploadMultipartContext(ctx context.Context, tmpFile, saveFile string, opts CloudStore.MultipartOptions, headers ...map[string]string) (err error) {
+	ctx, done := o.start(ctx, "UploadMultipart", saveFile)
+	defer func() { done(fileSize(tmpFile), err) }()
+	return o.store.UploadMultipartContext(ctx, tmpFile, saveFile, opts, headers...)
+}
+
+func (o *observedStore) GetSignUploadURL(object string, expire int64, headers ...map[string]string) (upload CloudStore.SignedUpload, err error) {
+	return o.GetSignUploadURLContext(context.Background(), object, expire, headers...)
+}
+
+func (o *observedStore) GetSignUploadURLContext(ctx context.Context, object string, expire int64, headers ...map[string]string) (upload CloudStore.SignedUpload, err error) {
+	ctx, done := o.start(ctx, "GetSignUploadURL", object)
+	defer func() { done(-1, err) }()
+	return o.store.GetSignUploadURLContext(ctx, object, expire, headers...)
+}
+
+func (o *observedStore) PostPolicy(keyPrefix string, maxSize int64, contentTypes []string, expire int64) (form CloudStore.PostForm, err error) {
+	return o.PostPolicyContext(context.Background(), keyPrefix, maxSize, contentTypes, expire)
+}
+
+func (o *observedStore) PostPolicyContext(ctx context.Context, keyPrefix string, maxSize int64, contentTypes []string, expire int64) (form CloudStore.PostForm, err error) {
+	ctx, done := o.start(ctx, "PostPolicy", keyPrefix)
+	defer func() { done(-1, err) }()
+	return o.store.PostPolicyContext(ctx, keyPrefix, maxSize, contentTypes, expire)
+}
@@ -0,0 +1,87 @@
+package observe
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/TruthHun/CloudStore"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestWrap(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	metrics, err := NewMetrics(reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 重复注册时复用已注册的指标
+	if again, err := NewMetrics(reg); err != nil || again.Bytes != metrics.Bytes {
+		t.Fatalf("NewMetrics again = %v, %v", again, err)
+	}
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	var logs bytes.Buffer
+	store := Wrap(CloudStore.NewMemory("", ""), Options{
+		Metrics: metrics,
+		Tracer:  provider.Tracer("test"),
+		Logger:  CloudStore.NewJSONLogger(&logs, CloudStore.LevelDebug),
+	})
+
+	if err = store.Put("a.txt", strings.NewReader("hello"), 5); err != nil {
+		t.Fatal(err)
+	}
+	reader, _, err := store.Get("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioutil.ReadAll(reader)
+	reader.Close()
+	if _, err = store.GetInfo("missing.txt"); !errors.Is(err, CloudStore.ErrNotExist) {
+		t.Fatalf("GetInfo = %v", err)
+	}
+
+	if v := testutil.ToFloat64(metrics.Bytes.WithLabelValues("memory", "Put")); v != 5 {
+		t.Errorf("Put bytes = %v", v)
+	}
+	if v := testutil.ToFloat64(metrics.Bytes.WithLabelValues("memory", "Get")); v != 5 {
+		t.Errorf("Get bytes = %v", v)
+	}
+	if v := testutil.ToFloat64(metrics.Errors.WithLabelValues("memory", "GetInfo", "not_exist")); v != 1 {
+		t.Errorf("GetInfo errors = %v", v)
+	}
+	if n := testutil.CollectAndCount(metrics.Duration); n != 3 {
+		t.Errorf("duration series = %v", n)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("spans = %v", len(spans))
+	}
+	if spans[0].Name() != "CloudStore.Put" || spans[0].Status().Code == codes.Error {
+		t.Errorf("span = %v, %v", spans[0].Name(), spans[0].Status())
+	}
+	if spans[2].Name() != "CloudStore.GetInfo" || spans[2].Status().Code != codes.Error {
+		t.Errorf("span = %v, %v", spans[2].Name(), spans[2].Status())
+	}
+
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("logs = %v", logs.String())
+	}
+	var entry map[string]interface{}
+	if err = json.Unmarshal([]byte(lines[2]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["level"] != "info" || entry["driver"] != "memory" || entry["op"] != "GetInfo" || entry["key"] != "missing.txt" || entry["error"] == nil {
+		t.Errorf("log = %v", lines[2])
+	}
+}
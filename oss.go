@@ -1,12 +1,16 @@
 package CloudStore
 
 import (
-	"errors"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"io"
 	"net/http"
 	"net/url"
+	"regexp"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/aliyun/aliyun-oss-go-sdk/oss"
 )
@@ -43,42 +47,255 @@ func NewOSS(accessKey, secretKey, endpoint, bucket, domain string) (o *OSS, err
 }
 
 func (o *OSS) IsExist(object string) (err error) {
+	return o.IsExistContext(context.Background(), object)
+}
+
+// 阿里云 SDK 不支持 context，以下 XXXContext 方法在 ctx 结束时提前返回
+func (o *OSS) IsExistContext(ctx context.Context, object string) (err error) {
+	defer func() { err = ossError("IsExist", object, err) }()
 	var b bool
-	b, err = o.Client.IsObjectExist(objectRel(object))
+	err = doContext(ctx, func() (e error) {
+		b, e = o.Client.IsObjectExist(objectRel(object))
+		return
+	})
 	if err != nil {
 		return
 	}
 	if !b {
-		return errors.New("file is not exist")
+		return ErrNotExist
 	}
 	return
 }
 
 func (o *OSS) Upload(tmpFile, saveFile string, headers ...map[string]string) (err error) {
-	var opts []oss.Option
-	for _, header := range headers {
-		for k, v := range header {
-			switch strings.ToLower(k) {
-			case "content-type":
-				opts = append(opts, oss.ContentType(v))
-			case "content-encoding":
-				opts = append(opts, oss.ContentEncoding(v))
-			case "content-disposition":
-				opts = append(opts, oss.ContentDisposition(v))
-				// TODO: more
-			}
+	return o.UploadContext(context.Background(), tmpFile, saveFile, headers...)
+}
+
+func (o *OSS) UploadContext(ctx context.Context, tmpFile, saveFile string, headers ...map[string]string) (err error) {
+	defer func() { err = ossError("Upload", saveFile, err) }()
+	if useMultipart(tmpFile) {
+		return o.UploadMultipartContext(ctx, tmpFile, saveFile, MultipartOptions{}, headers...)
+	}
+	opts := ossPutOptions(headers...)
+	return doContext(ctx, func() error {
+		return o.Client.PutObjectFromFile(strings.TrimLeft(saveFile, "./"), tmpFile, opts...)
+	})
+}
+
+func (o *OSS) Put(object string, reader io.Reader, size int64, headers ...map[string]string) (err error) {
+	return o.PutContext(context.Background(), object, reader, size, headers...)
+}
+
+func (o *OSS) PutContext(ctx context.Context, object string, reader io.Reader, size int64, headers ...map[string]string) (err error) {
+	defer func() { err = ossError("Put", object, err) }()
+	opts := ossPutOptions(headers...)
+	if size < 0 {
+		size = readerSize(reader)
+	}
+	if size >= 0 {
+		opts = append(opts, oss.ContentLength(size))
+	}
+	return doContextReader(ctx, reader, func(reader io.Reader) error {
+		return o.Client.PutObject(objectRel(object), reader, opts...)
+	})
+}
+
+func (o *OSS) Get(object string) (reader io.ReadCloser, info File, err error) {
+	return o.GetContext(context.Background(), object)
+}
+
+func (o *OSS) GetContext(ctx context.Context, object string) (reader io.ReadCloser, info File, err error) {
+	defer func() { err = ossError("Get", object, err) }()
+	return o.get(ctx, object, nil)
+}
+
+func (o *OSS) GetIf(object string, cond Conditions) (reader io.ReadCloser, info File, err error) {
+	return o.GetIfContext(context.Background(), object, cond)
+}
+
+func (o *OSS) GetIfContext(ctx context.Context, object string, cond Conditions) (reader io.ReadCloser, info File, err error) {
+	defer func() { err = ossError("GetIf", object, err) }()
+	return o.get(ctx, object, ossConditions(cond))
+}
+
+func (o *OSS) get(ctx context.Context, object string, opts []oss.Option) (reader io.ReadCloser, info File, err error) {
+	var res *oss.GetObjectResult
+	path := objectRel(object)
+	err = doContext(ctx, func() (e error) {
+		res, e = o.Client.DoGetObject(&oss.GetObjectRequest{ObjectKey: path}, opts)
+		if e == nil && ctx.Err() != nil {
+			res.Response.Body.Close()
 		}
+		return
+	})
+	if err != nil {
+		return
 	}
-	err = o.Client.PutObjectFromFile(strings.TrimLeft(saveFile, "./"), tmpFile, opts...)
+	info = fileFromHeader(path, res.Response.Headers, ossDialect)
+	reader = &contextReader{ctx: ctx, ReadCloser: res.Response.Body}
 	return
 }
 
-func (o *OSS) Delete(objects ...string) (err error) {
-	_, err = o.Client.DeleteObjects(objects)
+// OSS 的上传参数都是请求头，见 ossDialect
+func ossOptions(headers ...map[string]string) (opts []oss.Option) {
+	for k, v := range ossDialect.native(parseHeaders(headers...)) {
+		opts = append(opts, oss.SetHeader(k, v))
+	}
+	return
+}
+
+// 上传的选项，包括条件上传的请求头
+func ossPutOptions(headers ...map[string]string) (opts []oss.Option) {
+	h := parseHeaders(headers...)
+	opts = ossUploadConditions(h)
+	for k, v := range ossDialect.native(h) {
+		opts = append(opts, oss.SetHeader(k, v))
+	}
+	return
+}
+
+// 条件上传由 OSS 检查：If-None-Match 为 "*" 时使用 x-oss-forbid-overwrite，文件已存在时返回 FileAlreadyExists；
+// 其他条件作为 If-Match、If-None-Match 请求头发送，简单上传和完成分片上传时使用
+func ossUploadConditions(h objectHeader) (opts []oss.Option) {
+	if h.IfNoneMatch == "*" {
+		opts = append(opts, oss.ForbidOverWrite(true))
+		h.IfNoneMatch = ""
+	}
+	return append(opts, ossConditions(Conditions{IfMatch: h.IfMatch, IfNoneMatch: h.IfNoneMatch})...)
+}
+
+// 条件请求的请求头
+func ossConditions(cond Conditions) (opts []oss.Option) {
+	header := cond.header()
+	for k := range header {
+		opts = append(opts, oss.SetHeader(k, header.Get(k)))
+	}
 	return
 }
 
+func (o *OSS) UploadMultipart(tmpFile, saveFile string, opts MultipartOptions, headers ...map[string]string) (err error) {
+	return o.UploadMultipartContext(context.Background(), tmpFile, saveFile, opts, headers...)
+}
+
+func (o *OSS) UploadMultipartContext(ctx context.Context, tmpFile, saveFile string, opts MultipartOptions, headers ...map[string]string) (err error) {
+	defer func() { err = ossError("UploadMultipart", saveFile, err) }()
+	return uploadMultipart(ctx, o, tmpFile, saveFile, opts, headers...)
+}
+
+func (o *OSS) imur(object, uploadID string) oss.InitiateMultipartUploadResult {
+	return oss.InitiateMultipartUploadResult{Bucket: o.Bucket, Key: object, UploadID: uploadID}
+}
+
+func (o *OSS) initMultipart(ctx context.Context, object string, size, partSize int64, header map[string]string) (uploadID string, err error) {
+	var imur oss.InitiateMultipartUploadResult
+	err = doContext(ctx, func() (e error) {
+		imur, e = o.Client.InitiateMultipartUpload(object, ossOptions(header)...)
+		return
+	})
+	if err != nil {
+		return
+	}
+	uploadID = imur.UploadID
+	return
+}
+
+func (o *OSS) uploadPart(ctx context.Context, object, uploadID string, number int, reader io.Reader, size int64) (etag string, err error) {
+	var part oss.UploadPart
+	err = doContextReader(ctx, reader, func(reader io.Reader) (e error) {
+		part, e = o.Client.UploadPart(o.imur(object, uploadID), reader, size, number)
+		return
+	})
+	if err != nil {
+		return
+	}
+	etag = part.ETag
+	return
+}
+
+func (o *OSS) completeMultipart(ctx context.Context, object, uploadID string, size int64, parts []uploadedPart, header map[string]string) (err error) {
+	ossParts := make([]oss.UploadPart, 0, len(parts))
+	for _, part := range parts {
+		ossParts = append(ossParts, oss.UploadPart{PartNumber: part.Number, ETag: part.ETag})
+	}
+	return doContext(ctx, func() (e error) {
+		_, e = o.Client.CompleteMultipartUpload(o.imur(object, uploadID), ossParts, ossUploadConditions(parseHeaders(header))...)
+		return
+	})
+}
+
+func (o *OSS) listParts(ctx context.Context, object, uploadID string) (parts []uploadedPart, err error) {
+	var marker int
+	for {
+		var res oss.ListUploadedPartsResult
+		err = doContext(ctx, func() (e error) {
+			res, e = o.Client.ListUploadedParts(o.imur(object, uploadID), oss.PartNumberMarker(marker))
+			return
+		})
+		if err != nil {
+			return
+		}
+		for _, part := range res.UploadedParts {
+			parts = append(parts, uploadedPart{Number: part.PartNumber, ETag: part.ETag})
+		}
+		if !res.IsTruncated {
+			return
+		}
+		marker, _ = strconv.Atoi(res.NextPartNumberMarker)
+	}
+}
+
+func (o *OSS) abortMultipart(ctx context.Context, object, uploadID string) (err error) {
+	return doContext(ctx, func() error {
+		return o.Client.AbortMultipartUpload(o.imur(object, uploadID))
+	})
+}
+
+func (o *OSS) Delete(objects ...string) (err error) {
+	return o.DeleteContext(context.Background(), objects...)
+}
+
+func (o *OSS) DeleteContext(ctx context.Context, objects ...string) (err error) {
+	defer func() { err = ossError("Delete", strings.Join(objects, ", "), err) }()
+	return doContext(ctx, func() (e error) {
+		_, e = o.Client.DeleteObjects(objects)
+		return
+	})
+}
+
+func (o *OSS) Copy(src, dst string) (err error) {
+	return o.CopyContext(context.Background(), src, dst)
+}
+
+func (o *OSS) CopyContext(ctx context.Context, src, dst string) (err error) {
+	defer func() { err = ossError("Copy", src, err) }()
+	return doContext(ctx, func() (e error) {
+		_, e = o.Client.CopyObject(objectRel(src), objectRel(dst), oss.MetadataDirective(oss.MetaCopy))
+		return
+	})
+}
+
+func (o *OSS) Move(src, dst string) (err error) {
+	return o.MoveContext(context.Background(), src, dst)
+}
+
+// OSS 没有移动文件的接口，先复制再删除源文件
+func (o *OSS) MoveContext(ctx context.Context, src, dst string) (err error) {
+	defer func() { err = ossError("Move", src, err) }()
+	if err = o.CopyContext(ctx, src, dst); err != nil {
+		return
+	}
+	return o.DeleteContext(ctx, objectRel(src))
+}
+
 func (o *OSS) GetSignURL(object string, expire int64) (link string, err error) {
+	return o.GetSignURLContext(context.Background(), object, expire)
+}
+
+func (o *OSS) GetSignURLContext(ctx context.Context, object string, expire int64) (link string, err error) {
+	defer func() { err = ossError("GetSignURL", object, err) }()
+	if err = ctx.Err(); err != nil {
+		return
+	}
 	path := objectRel(object)
 	if expire <= 0 {
 		return o.Domain + "/" + path, nil
@@ -96,12 +313,95 @@ func (o *OSS) GetSignURL(object string, expire int64) (link string, err error) {
 	return
 }
 
-func (o *OSS) Download(object string, savePath string) (err error) {
-	err = o.Client.DownloadFile(objectRel(object), savePath, 1048576)
+func (o *OSS) GetSignUploadURL(object string, expire int64, headers ...map[string]string) (upload SignedUpload, err error) {
+	return o.GetSignUploadURLContext(context.Background(), object, expire, headers...)
+}
+
+// 上传签名不替换为绑定的域名，CDN 域名一般不支持 PUT
+func (o *OSS) GetSignUploadURLContext(ctx context.Context, object string, expire int64, headers ...map[string]string) (upload SignedUpload, err error) {
+	defer func() { err = ossError("GetSignUploadURL", object, err) }()
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	if expire <= 0 {
+		expire = oneHour
+	}
+	upload = SignedUpload{
+		Method: http.MethodPut,
+		Header: ossDialect.native(parseHeaders(headers...)),
+	}
+	opts := ossOptions(headers...)
+	upload.URL, err = o.Client.SignURL(objectRel(object), oss.HTTPPut, expire, opts...)
 	return
 }
 
+func (o *OSS) PostPolicy(keyPrefix string, maxSize int64, contentTypes []string, expire int64) (form PostForm, err error) {
+	return o.PostPolicyContext(context.Background(), keyPrefix, maxSize, contentTypes, expire)
+}
+
+// OSS 的 policy 支持使用 in 匹配多个文件类型
+// https://help.aliyun.com/document_detail/31988.html
+func (o *OSS) PostPolicyContext(ctx context.Context, keyPrefix string, maxSize int64, contentTypes []string, expire int64) (form PostForm, err error) {
+	defer func() { err = ossError("PostPolicy", keyPrefix, err) }()
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	if expire <= 0 {
+		expire = oneHour
+	}
+	var p *postPolicy
+	if p, err = newPostPolicy(keyPrefix, maxSize, contentTypes, expire, true); err != nil {
+		return
+	}
+	policy := p.base64()
+	mac := hmac.New(sha1.New, []byte(o.SecretKey))
+	mac.Write([]byte(policy))
+
+	form = PostForm{
+		URL:    "https://" + o.Bucket + "." + o.Endpoint,
+		Fields: postFields(keyPrefix, contentTypes),
+	}
+	form.Fields["OSSAccessKeyId"] = o.AccessKey
+	form.Fields["policy"] = policy
+	form.Fields["Signature"] = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	return
+}
+
+func (o *OSS) Download(object string, savePath string) (err error) {
+	return o.DownloadContext(context.Background(), object, savePath)
+}
+
+func (o *OSS) DownloadContext(ctx context.Context, object string, savePath string) (err error) {
+	defer func() { err = ossError("Download", object, err) }()
+	// SDK 的 DownloadFile 不支持 context，取消之后仍然会继续写入 savePath，这里使用 Get 的数据流
+	var reader io.ReadCloser
+	reader, _, err = o.GetContext(ctx, object)
+	if err != nil {
+		return
+	}
+	defer reader.Close()
+	return saveTo(reader, savePath)
+}
+
 func (o *OSS) GetInfo(object string) (info File, err error) {
+	return o.GetInfoContext(context.Background(), object)
+}
+
+func (o *OSS) GetInfoContext(ctx context.Context, object string) (info File, err error) {
+	defer func() { err = ossError("GetInfo", object, err) }()
+	return o.getInfo(ctx, object, nil)
+}
+
+func (o *OSS) GetInfoIf(object string, cond Conditions) (info File, err error) {
+	return o.GetInfoIfContext(context.Background(), object, cond)
+}
+
+func (o *OSS) GetInfoIfContext(ctx context.Context, object string, cond Conditions) (info File, err error) {
+	defer func() { err = ossError("GetInfoIf", object, err) }()
+	return o.getInfo(ctx, object, ossConditions(cond))
+}
+
+func (o *OSS) getInfo(ctx context.Context, object string, opts []oss.Option) (info File, err error) {
 	// https://help.aliyun.com/document_detail/31859.html?spm=a2c4g.11186623.2.10.713d1592IKig7s#concept-lkf-swy-5db
 	//Cache-Control	指定该 Object 被下载时的网页的缓存行为
 	//Content-Disposition	指定该 Object 被下载时的名称
@@ -115,42 +415,99 @@ func (o *OSS) GetInfo(object string) (info File, err error) {
 	var header http.Header
 
 	path := objectRel(object)
-	header, err = o.Client.GetObjectMeta(path)
+	err = doContext(ctx, func() (e error) {
+		header, e = o.Client.GetObjectDetailedMeta(path, opts...)
+		return
+	})
 	if err != nil {
 		return
 	}
+	info = fileFromHeader(path, header, ossDialect)
+	info.IsDir = isDirKey(path)
+	return
+}
 
-	headerMap := make(map[string]string)
+func (o *OSS) Lists(prefix string) (files []File, err error) {
+	return o.ListsContext(context.Background(), prefix)
+}
 
-	for k, _ := range header {
-		headerMap[k] = header.Get(k)
-	}
+func (o *OSS) ListsContext(ctx context.Context, prefix string) (files []File, err error) {
+	defer func() { err = ossError("Lists", prefix, err) }()
+	return listAll(ctx, o, prefix)
+}
 
-	info.Header = headerMap
-	info.Size, _ = strconv.ParseInt(header.Get("Content-Length"), 10, 64)
-	info.ModTime, _ = time.Parse(http.TimeFormat, header.Get("Last-Modified"))
-	info.Name = path
-	info.IsDir = false
-	return
+func (o *OSS) ListPage(prefix, marker string, limit int) (files []File, nextMarker string, err error) {
+	return o.ListPageContext(context.Background(), prefix, marker, limit)
 }
 
-func (o *OSS) Lists(prefix string) (files []File, err error) {
-	prefix = objectRel(prefix)
+func (o *OSS) ListPageContext(ctx context.Context, prefix, marker string, limit int) (files []File, nextMarker string, err error) {
+	defer func() { err = ossError("ListPage", prefix, err) }()
+	return o.list(ctx, objectRel(prefix), "", marker, limit)
+}
+
+func (o *OSS) ListDir(dir, marker string, limit int) (files []File, nextMarker string, err error) {
+	return o.ListDirContext(context.Background(), dir, marker, limit)
+}
+
+func (o *OSS) ListDirContext(ctx context.Context, dir, marker string, limit int) (files []File, nextMarker string, err error) {
+	defer func() { err = ossError("ListDir", dir, err) }()
+	return o.list(ctx, dirPrefix(dir), delimiter, marker, limit)
+}
+
+func (o *OSS) list(ctx context.Context, prefix, delim, marker string, limit int) (files []File, nextMarker string, err error) {
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
 
 	var res oss.ListObjectsResult
 
-	res, err = o.Client.ListObjects(oss.Prefix(prefix))
+	err = doContext(ctx, func() (e error) {
+		res, e = o.Client.ListObjects(oss.Prefix(prefix), oss.Delimiter(delim), oss.Marker(marker), oss.MaxKeys(limit))
+		return
+	})
 	if err != nil {
 		return
 	}
+	for _, dir := range res.CommonPrefixes {
+		files = append(files, dirFile(dir))
+	}
 	for _, object := range res.Objects {
+		if delim != "" && object.Key == prefix {
+			continue
+		}
 		files = append(files, File{
-			ModTime: object.LastModified,
-			Name:    object.Key,
-			Size:    object.Size,
-			IsDir:   object.Size == 0,
-			Header:  map[string]string{},
+			ModTime:      object.LastModified,
+			Name:         object.Key,
+			Size:         object.Size,
+			IsDir:        isDirKey(object.Key),
+			Header:       map[string]string{},
+			ETag:         strings.Trim(object.ETag, `"`),
+			StorageClass: ossDialect.canonicalStorageClass(object.StorageClass),
 		})
 	}
+	if res.IsTruncated {
+		nextMarker = res.NextMarker
+	}
 	return
 }
+
+var ossStatusRegexp = regexp.MustCompile(`^oss: service returned (3\d{2}),`)
+
+// 将 SDK 返回的错误转换为 StoreError
+func ossError(op, object string, err error) error {
+	if keepError(err) {
+		return err
+	}
+	switch e := err.(type) {
+	case oss.ServiceError:
+		return newStoreError("oss", op, object, e.StatusCode, e.Code, e.RequestID, err)
+	case oss.UnexpectedStatusCodeError:
+		return newStoreError("oss", op, object, e.Got(), "", "", err)
+	}
+	// 3xx 的响应没有内容，SDK 返回的是文本错误，如 "oss: service returned 304,304 Not Modified"
+	if match := ossStatusRegexp.FindStringSubmatch(err.Error()); len(match) == 2 {
+		statusCode, _ := strconv.Atoi(match[1])
+		return newStoreError("oss", op, object, statusCode, "", "", err)
+	}
+	return newStoreError("oss", op, object, 0, "", "", err)
+}
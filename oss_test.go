@@ -23,6 +23,10 @@ func init() {
 	endpoint := beego.AppConfig.String("oss::endpoint")
 	bucket := beego.AppConfig.String("oss::bucket")
 	domain := strings.ToLower(beego.AppConfig.String("oss::domain"))
+	// 没有配置 oss 时跳过测试
+	if key == "" {
+		return
+	}
 
 	O, err = NewOSS(key, secret, endpoint, bucket, domain)
 	if err != nil {
@@ -33,6 +37,9 @@ func init() {
 
 
 func TestOSS(t *testing.T) {
+	if O == nil {
+		t.Skip("oss is not configured")
+	}
 	// upload
 	t.Log("=====Upload=====", objectSVG, objectSVGGzip)
 	err = O.Upload(objectSVG, objectSVG,headerSVG)
@@ -74,6 +81,9 @@ func TestOSS(t *testing.T) {
 }
 
 func TestOSS_Delete(t *testing.T) {
+	if O == nil {
+		t.Skip("oss is not configured")
+	}
 	if err := O.Delete(objectSVG, objectSVGGzip); err != nil {
 		t.Error(err)
 	} else {
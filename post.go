@@ -0,0 +1,74 @@
+package CloudStore
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// 云存储的 policy 无法准确表达的限制返回错误，而不是放宽限制
+var (
+	errPolicyContentTypes = errors.New("post policy: only one content type can be restricted")
+	errPolicyMaxSize      = errors.New("post policy: file size can't be restricted")
+)
+
+// S3 风格的 POST policy，OSS、COS、BOS 使用相同的格式
+// https://help.aliyun.com/document_detail/31988.html
+type postPolicy struct {
+	Expiration string        `json:"expiration"`
+	Conditions []interface{} `json:"conditions"`
+}
+
+// keyPrefix 为上传文件的前缀，maxSize 小于等于 0 时不限制文件大小，contentTypes 为空时不限制文件类型；
+// in 为云存储是否支持使用 in 匹配多个文件类型，不支持时多个文件类型返回 errPolicyContentTypes
+func newPostPolicy(keyPrefix string, maxSize int64, contentTypes []string, expire int64, in bool) (p *postPolicy, err error) {
+	p = &postPolicy{
+		Expiration: time.Now().Add(time.Duration(expire) * time.Second).UTC().Format("2006-01-02T15:04:05.000Z"),
+	}
+	p.add([]interface{}{"starts-with", "$key", objectRel(keyPrefix)})
+	p.add(map[string]string{"success_action_status": "200"})
+	if maxSize > 0 {
+		p.add([]interface{}{"content-length-range", 0, maxSize})
+	}
+	switch {
+	case len(contentTypes) == 1:
+		p.add([]interface{}{"eq", "$Content-Type", contentTypes[0]})
+	case len(contentTypes) > 1 && in:
+		p.add([]interface{}{"in", "$Content-Type", contentTypes})
+	case len(contentTypes) > 1:
+		// 不能使用公共前缀，如 image/png、image/jpeg 的公共前缀 image/ 也允许上传 image/svg+xml
+		return nil, errPolicyContentTypes
+	}
+	return
+}
+
+func (p *postPolicy) add(condition interface{}) {
+	p.Conditions = append(p.Conditions, condition)
+}
+
+func (p *postPolicy) json() []byte {
+	b, _ := json.Marshal(p)
+	return b
+}
+
+func (p *postPolicy) base64() string {
+	return base64.StdEncoding.EncodeToString(p.json())
+}
+
+// 表单中的文件名，${filename} 会被替换为用户上传的文件名
+func postKey(keyPrefix string) string {
+	return objectRel(keyPrefix) + "${filename}"
+}
+
+// 表单的公共字段，只有一个文件类型时由服务端直接设置 Content-Type
+func postFields(keyPrefix string, contentTypes []string) (fields map[string]string) {
+	fields = map[string]string{
+		"key":                   postKey(keyPrefix),
+		"success_action_status": "200",
+	}
+	if len(contentTypes) == 1 {
+		fields["Content-Type"] = contentTypes[0]
+	}
+	return
+}
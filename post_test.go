@@ -0,0 +1,152 @@
+package CloudStore
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+func TestNewPostPolicy(t *testing.T) {
+	tests := []struct {
+		contentTypes []string
+		in           bool
+		want         string // 文件类型的条件，为空时没有限制
+		err          error
+	}{
+		{nil, false, "", nil},
+		{[]string{"image/png"}, false, `["eq","$Content-Type","image/png"]`, nil},
+		{[]string{"image/png", "image/jpeg"}, true, `["in","$Content-Type",["image/png","image/jpeg"]]`, nil},
+		{[]string{"image/png", "image/jpeg"}, false, "", errPolicyContentTypes},
+		{[]string{"image/png", "application/pdf"}, false, "", errPolicyContentTypes},
+	}
+	for _, tt := range tests {
+		p, err := newPostPolicy("uploads/", 1024, tt.contentTypes, 60, tt.in)
+		if err != tt.err {
+			t.Errorf("newPostPolicy(%q, in %v): err = %v, want %v", tt.contentTypes, tt.in, err, tt.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		policy := string(p.json())
+		if !strings.Contains(policy, `["starts-with","$key","uploads/"]`) || !strings.Contains(policy, `["content-length-range",0,1024]`) {
+			t.Errorf("policy = %s, want key prefix and size range", policy)
+		}
+		if got := strings.Contains(policy, "$Content-Type"); got != (tt.want != "") || !strings.Contains(policy, tt.want) {
+			t.Errorf("policy = %s, want content type condition %s", policy, tt.want)
+		}
+	}
+}
+
+// 云存储无法准确限制时返回错误，而不是放宽限制
+func TestPostPolicyLimits(t *testing.T) {
+	ossStore, _ := NewOSS("ak", "sk", "oss-cn-hangzhou.aliyuncs.com", "bucket", "")
+	cosStore, _ := NewCOS("ak", "sk", "bucket", "1250000000", "ap-guangzhou", "")
+	bosStore, _ := NewBOS("ak", "sk", "bucket", "bj.bcebos.com", "")
+	obsStore, _ := NewOBS("ak", "sk", "bucket", "obs.cn-north-4.myhuaweicloud.com", "")
+	minioStore, _ := NewMinIO("ak", "sk", "bucket", "127.0.0.1:9000", "")
+	upyunStore := NewUpYun("bucket", "operator", "password", "", "")
+
+	images := []string{"image/png", "image/jpeg"}
+	tests := []struct {
+		name         string
+		store        CloudStore
+		maxSize      int64
+		contentTypes []string
+		wantErr      bool
+	}{
+		{"oss", ossStore, 1024, images, false},
+		{"cos", cosStore, 1024, images[:1], false},
+		{"cos", cosStore, 1024, images, true},
+		{"bos", bosStore, 1024, images[:1], false},
+		{"bos", bosStore, 1024, images, true},
+		{"obs", obsStore, 0, images[:1], false},
+		{"obs", obsStore, 1024, nil, true},
+		{"obs", obsStore, 0, images, true},
+		{"minio", minioStore, 1024, images, true},
+		{"upyun", upyunStore, 1024, images[:1], false},
+		{"upyun", upyunStore, 1024, []string{"application/x-cloudstore-unknown"}, true},
+	}
+	for _, tt := range tests {
+		_, err := tt.store.PostPolicy("uploads/", tt.maxSize, tt.contentTypes, 60)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%v PostPolicy(%v, %q): err = %v, want error %v", tt.name, tt.maxSize, tt.contentTypes, err, tt.wantErr)
+		}
+	}
+
+	form, err := upyunStore.PostPolicy("uploads/", 0, images[:1], 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := base64.StdEncoding.DecodeString(form.Fields["policy"])
+	var options map[string]interface{}
+	json.Unmarshal(b, &options)
+	if allow, _ := options["allow-file-type"].(string); !strings.Contains(allow, "png") {
+		t.Errorf("upyun allow-file-type = %q, want png", allow)
+	}
+}
+
+// 表单上传只接受 policy 中的文件类型、大小以及文件名前缀
+func TestMemory_PostPolicy(t *testing.T) {
+	mem := NewMemory("", "secret")
+	srv := httptest.NewServer(mem)
+	defer srv.Close()
+	mem.Domain = srv.URL
+
+	form, err := mem.PostPolicy("uploads/", 8, []string{"image/png", "image/jpeg"}, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		filename    string
+		contentType string
+		content     string
+		want        int
+	}{
+		{"a.png", "image/png", "png", http.StatusOK},
+		{"b.jpg", "image/jpeg", "jpeg", http.StatusOK},
+		{"c.svg", "image/svg+xml", "svg", http.StatusForbidden},
+		{"d.png", "image/png", "too large content", http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		if status := postForm(t, form, tt.filename, tt.contentType, tt.content); status != tt.want {
+			t.Errorf("POST %v: status = %v, want %v", tt.filename, status, tt.want)
+		}
+	}
+	if err = mem.IsExist("uploads/a.png"); err != nil {
+		t.Errorf("IsExist(uploads/a.png): %v", err)
+	}
+	for _, object := range []string{"uploads/c.svg", "uploads/d.png"} {
+		if err = mem.IsExist(object); !errors.Is(err, ErrNotExist) {
+			t.Errorf("IsExist(%v): err = %v, want ErrNotExist", object, err)
+		}
+	}
+}
+
+func postForm(t *testing.T, form PostForm, filename, contentType, content string) int {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for k, v := range form.Fields {
+		w.WriteField(k, v)
+	}
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", `form-data; name="file"; filename="`+filename+`"`)
+	h.Set("Content-Type", contentType)
+	part, _ := w.CreatePart(h)
+	part.Write([]byte(content))
+	w.Close()
+
+	resp, err := http.Post(form.URL, w.FormDataContentType(), &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
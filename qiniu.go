@@ -5,15 +5,16 @@ import (
 	"crypto/tls"
 	"errors"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"net/http"
 	"net/url"
-	"os"
+	"strconv"
 	"strings"
 	"time"
 
-	"github.com/astaxie/beego/httplib"
+	"github.com/qiniu/api.v7/v7/auth"
 	"github.com/qiniu/api.v7/v7/auth/qbox"
+	"github.com/qiniu/api.v7/v7/client"
 	"github.com/qiniu/api.v7/v7/storage"
 )
 
@@ -45,36 +46,233 @@ func NewQINIU(accessKey, secretKey, bucket, domain string) (q *QINIU, err error)
 }
 
 func (q *QINIU) IsExist(object string) (err error) {
-	_, err = q.GetInfo(object)
+	return q.IsExistContext(context.Background(), object)
+}
+
+func (q *QINIU) IsExistContext(ctx context.Context, object string) (err error) {
+	defer func() { err = qiniuError("IsExist", object, err) }()
+	_, err = q.GetInfoContext(ctx, object)
 	return
 }
 
-// TODO: 目前没发现有可以设置header的地方
 func (q *QINIU) Upload(tmpFile, saveFile string, headers ...map[string]string) (err error) {
-	policy := storage.PutPolicy{Scope: q.Bucket}
-	token := policy.UploadToken(q.mac)
+	return q.UploadContext(context.Background(), tmpFile, saveFile, headers...)
+}
+
+func (q *QINIU) UploadContext(ctx context.Context, tmpFile, saveFile string, headers ...map[string]string) (err error) {
+	defer func() { err = qiniuError("Upload", saveFile, err) }()
+	if useMultipart(tmpFile) {
+		return q.UploadMultipartContext(ctx, tmpFile, saveFile, MultipartOptions{}, headers...)
+	}
+	saveFile = objectRel(saveFile)
+	if err = q.checkUpload(ctx, saveFile, headers...); err != nil {
+		return
+	}
+	form, token, extra := q.formUploader(saveFile, headers...)
+	ret := &storage.PutRet{}
+	err = form.PutFile(ctx, ret, token, saveFile, tmpFile, extra)
+	return
+}
+
+func (q *QINIU) Put(object string, reader io.Reader, size int64, headers ...map[string]string) (err error) {
+	return q.PutContext(context.Background(), object, reader, size, headers...)
+}
+
+func (q *QINIU) PutContext(ctx context.Context, object string, reader io.Reader, size int64, headers ...map[string]string) (err error) {
+	defer func() { err = qiniuError("Put", object, err) }()
+	object = objectRel(object)
+	if err = q.checkUpload(ctx, object, headers...); err != nil {
+		return
+	}
+	form, token, extra := q.formUploader(object, headers...)
+	ret := &storage.PutRet{}
+	err = form.Put(ctx, ret, token, object, reader, size, extra)
+	return
+}
+
+// 上传凭证的 scope 为 bucket:key 时，七牛允许覆盖已存在的文件，新文件上传完成后才替换旧文件；
+// If-None-Match 为 "*" 时设置 insertOnly，文件已存在时返回 614
+func (q *QINIU) putPolicy(object string, h objectHeader) storage.PutPolicy {
+	policy := storage.PutPolicy{
+		Scope:    q.Bucket + ":" + object,
+		FileType: qiniuFileType(h.StorageClass),
+	}
+	if h.IfNoneMatch == "*" {
+		policy.InsertOnly = 1
+	}
+	return policy
+}
+
+// If-None-Match 为 "*" 时由上传凭证的 insertOnly 保证，其他条件七牛不支持，上传之前检查
+func (q *QINIU) checkUpload(ctx context.Context, object string, headers ...map[string]string) error {
+	h := parseHeaders(headers...)
+	if h.IfNoneMatch == "*" {
+		h.IfNoneMatch = ""
+	}
+	return checkUploadConditions(ctx, q.GetInfoContext, object, h)
+}
+
+func (q *QINIU) formUploader(object string, headers ...map[string]string) (form *storage.FormUploader, token string, extra *storage.PutExtra) {
+	h := parseHeaders(headers...)
+	policy := q.putPolicy(object, h)
+	token = policy.UploadToken(q.mac)
 	cfg := &storage.Config{
 		Zone: q.Zone,
 	}
-	form := storage.NewFormUploader(cfg)
-	ret := &storage.PutRet{}
+	form = storage.NewFormUploader(cfg)
+	extra = &storage.PutExtra{
+		Params:   qiniuMetaParams(h),
+		MimeType: h.ContentType,
+	}
+	return
+}
+
+// 七牛上传时只能设置 MimeType、存储类型（上传凭证的 fileType）以及 x-qn-meta- 开头的自定义元数据，
+// 其他标准 header 和访问权限没有对应的参数，会被忽略
+func qiniuMetaParams(h objectHeader) map[string]string {
 	params := make(map[string]string)
-	for _, header := range headers {
-		for k, v := range header {
-			params["x:"+k] = v
+	for k, v := range h.Metadata {
+		params[qiniuDialect.metaPrefix+k] = v
+	}
+	return params
+}
+
+func (q *QINIU) UploadMultipart(tmpFile, saveFile string, opts MultipartOptions, headers ...map[string]string) (err error) {
+	return q.UploadMultipartContext(context.Background(), tmpFile, saveFile, opts, headers...)
+}
+
+// 七牛使用分片上传 v1，文件按 4MB 分块，分片大小取整为 4MB 的整数倍，每个分片包含若干个块
+func (q *QINIU) UploadMultipartContext(ctx context.Context, tmpFile, saveFile string, opts MultipartOptions, headers ...map[string]string) (err error) {
+	defer func() { err = qiniuError("UploadMultipart", saveFile, err) }()
+	if opts.PartSize <= 0 {
+		opts.PartSize = defaultPartSize
+	}
+	opts.PartSize = (opts.PartSize + qiniuBlockSize - 1) / qiniuBlockSize * qiniuBlockSize
+	return uploadMultipart(ctx, q, tmpFile, saveFile, opts, headers...)
+}
+
+const qiniuBlockSize int64 = 4 << 20
+
+func (q *QINIU) resumeUploader(policy storage.PutPolicy) (uploader *storage.ResumeUploader, token string) {
+	token = policy.UploadToken(q.mac)
+	uploader = storage.NewResumeUploader(&storage.Config{Zone: q.Zone})
+	return
+}
+
+// 七牛分片上传 v1 没有 uploadID，这里返回上传域名，供后续步骤使用；
+// 七牛不支持条件上传，开始上传以及完成上传之前各检查一次
+func (q *QINIU) initMultipart(ctx context.Context, object string, size, partSize int64, header map[string]string) (uploadID string, err error) {
+	if err = q.checkUpload(ctx, object, header); err != nil {
+		return
+	}
+	uploader, _ := q.resumeUploader(q.putPolicy(object, objectHeader{}))
+	return uploader.UpHost(q.AccessKey, q.Bucket)
+}
+
+// 分片中的每个块单独上传，返回以逗号分隔的块 ctx
+func (q *QINIU) uploadPart(ctx context.Context, object, uploadID string, number int, reader io.Reader, size int64) (etag string, err error) {
+	uploader, token := q.resumeUploader(q.putPolicy(object, objectHeader{}))
+	var ctxs []string
+	for size > 0 {
+		blockSize := qiniuBlockSize
+		if size < blockSize {
+			blockSize = size
+		}
+		ret := storage.BlkputRet{}
+		err = uploader.Mkblk(ctx, token, uploadID, &ret, int(blockSize), io.LimitReader(reader, blockSize), int(blockSize))
+		if err != nil {
+			return
 		}
+		ctxs = append(ctxs, ret.Ctx)
+		size -= blockSize
 	}
-	extra := &storage.PutExtra{
-		Params: params,
+	etag = strings.Join(ctxs, ",")
+	return
+}
+
+func (q *QINIU) completeMultipart(ctx context.Context, object, uploadID string, size int64, parts []uploadedPart, header map[string]string) (err error) {
+	if err = q.checkUpload(ctx, object, header); err != nil {
+		return
 	}
-	saveFile = objectRel(saveFile)
-	// 需要先删除，文件已存在的话，没法覆盖
-	q.Delete(saveFile)
-	err = form.PutFile(context.Background(), ret, token, saveFile, tmpFile, extra)
+	h := parseHeaders(header)
+	uploader, token := q.resumeUploader(q.putPolicy(object, h))
+	extra := &storage.RputExtra{
+		Params:   qiniuMetaParams(h),
+		MimeType: h.ContentType,
+	}
+	for _, part := range parts {
+		for _, blkCtx := range strings.Split(part.ETag, ",") {
+			extra.Progresses = append(extra.Progresses, storage.BlkputRet{Ctx: blkCtx})
+		}
+	}
+	return uploader.Mkfile(ctx, token, uploadID, &storage.PutRet{}, object, true, size, extra)
+}
+
+// 未完成的块会在七牛服务端自动过期，不需要取消
+func (q *QINIU) abortMultipart(ctx context.Context, object, uploadID string) (err error) {
 	return
 }
 
+func (q *QINIU) Get(object string) (reader io.ReadCloser, info File, err error) {
+	return q.GetContext(context.Background(), object)
+}
+
+// 七牛没有获取文件内容的 API，这里通过签名链接下载
+func (q *QINIU) GetContext(ctx context.Context, object string) (reader io.ReadCloser, info File, err error) {
+	defer func() { err = qiniuError("Get", object, err) }()
+	var link string
+	link, err = q.GetSignURLContext(ctx, object, 3600)
+	if err != nil {
+		return
+	}
+
+	var req *http.Request
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return
+	}
+	httpClient := &http.Client{}
+	if strings.HasPrefix(strings.ToLower(link), "https://") {
+		httpClient.Transport = &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}
+	}
+
+	var resp *http.Response
+
+	resp, err = httpClient.Do(req)
+	if err != nil {
+		return
+	}
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		err = client.ResponseError(resp)
+		resp.Body.Close()
+		return
+	}
+
+	info = fileFromHeader(objectRel(object), resp.Header, qiniuDialect)
+	reader = resp.Body
+	return
+}
+
+func (q *QINIU) GetIf(object string, cond Conditions) (reader io.ReadCloser, info File, err error) {
+	return q.GetIfContext(context.Background(), object, cond)
+}
+
+// 七牛不支持条件请求，在客户端检查
+func (q *QINIU) GetIfContext(ctx context.Context, object string, cond Conditions) (reader io.ReadCloser, info File, err error) {
+	defer func() { err = qiniuError("GetIf", object, err) }()
+	return getIf(ctx, q.GetContext, object, cond)
+}
+
 func (q *QINIU) Delete(objects ...string) (err error) {
+	return q.DeleteContext(context.Background(), objects...)
+}
+
+func (q *QINIU) DeleteContext(ctx context.Context, objects ...string) (err error) {
+	defer func() { err = qiniuError("Delete", strings.Join(objects, ", "), err) }()
 	length := len(objects)
 	if length == 0 {
 		return
@@ -91,31 +289,50 @@ func (q *QINIU) Delete(objects ...string) (err error) {
 	for _, object := range objects {
 		deleteOps = append(deleteOps, storage.URIDelete(q.Bucket, objectRel(object)))
 	}
-	cfg := &storage.Config{
-		Zone: q.Zone,
-	}
-	manager := storage.NewBucketManager(q.mac, cfg)
 	var res []storage.BatchOpRet
-	res, err = manager.Batch(deleteOps)
+	res, err = q.batch(ctx, deleteOps)
 	if err != nil {
 		return
 	}
 
-	var errs []string
-	for _, item := range res {
-		if item.Code != http.StatusOK {
-			errs = append(errs, fmt.Errorf("%+v: %v", item.Data, item.Code).Error())
+	// 返回结果与 deleteOps 的顺序一致
+	var errs []error
+	for idx, item := range res {
+		// 与其他云存储一致，删除不存在的文件不算失败
+		if item.Code != http.StatusOK && item.Code != 612 {
+			errs = append(errs, qiniuError("Delete", objects[idx], &client.ErrorInfo{Code: item.Code, Err: item.Data.Error}))
 		}
 	}
+	return deleteError(errs)
+}
 
-	if len(errs) > 0 {
-		err = errors.New(strings.Join(errs, "; "))
-	}
+func (q *QINIU) Copy(src, dst string) (err error) {
+	return q.CopyContext(context.Background(), src, dst)
+}
 
-	return
+func (q *QINIU) CopyContext(ctx context.Context, src, dst string) (err error) {
+	defer func() { err = qiniuError("Copy", src, err) }()
+	return q.rsCall(ctx, nil, storage.URICopy(q.Bucket, objectRel(src), q.Bucket, objectRel(dst), true))
+}
+
+func (q *QINIU) Move(src, dst string) (err error) {
+	return q.MoveContext(context.Background(), src, dst)
+}
+
+func (q *QINIU) MoveContext(ctx context.Context, src, dst string) (err error) {
+	defer func() { err = qiniuError("Move", src, err) }()
+	return q.rsCall(ctx, nil, storage.URIMove(q.Bucket, objectRel(src), q.Bucket, objectRel(dst), true))
 }
 
 func (q *QINIU) GetSignURL(object string, expire int64) (link string, err error) {
+	return q.GetSignURLContext(context.Background(), object, expire)
+}
+
+func (q *QINIU) GetSignURLContext(ctx context.Context, object string, expire int64) (link string, err error) {
+	defer func() { err = qiniuError("GetSignURL", object, err) }()
+	if err = ctx.Err(); err != nil {
+		return
+	}
 	object = objectRel(object)
 	if expire > 0 {
 		deadline := time.Now().Add(time.Second * time.Duration(expire)).Unix()
@@ -133,41 +350,101 @@ func (q *QINIU) GetSignURL(object string, expire int64) (link string, err error)
 	return
 }
 
-func (q *QINIU) Download(object string, savePath string) (err error) {
-	var link string
-	link, err = q.GetSignURL(object, 3600)
-	if err != nil {
+func (q *QINIU) GetSignUploadURL(object string, expire int64, headers ...map[string]string) (upload SignedUpload, err error) {
+	return q.GetSignUploadURLContext(context.Background(), object, expire, headers...)
+}
+
+// 七牛没有预签名 PUT，返回表单上传所需的上传凭证，凭证的 scope 限定为 bucket:key；
+// 表单中没有 MimeType 字段，客户端需要把 Content-Type 设置在 file 字段上
+// https://developer.qiniu.com/kodo/api/1312/upload
+func (q *QINIU) GetSignUploadURLContext(ctx context.Context, object string, expire int64, headers ...map[string]string) (upload SignedUpload, err error) {
+	defer func() { err = qiniuError("GetSignUploadURL", object, err) }()
+	if err = ctx.Err(); err != nil {
 		return
 	}
-	req := httplib.Get(link).SetTimeout(30*time.Minute, 30*time.Minute)
-	if strings.HasPrefix(strings.ToLower(link), "https://") {
-		req.SetTLSClientConfig(&tls.Config{InsecureSkipVerify: true})
+	if expire <= 0 {
+		expire = oneHour
+	}
+	object = objectRel(object)
+	h := parseHeaders(headers...)
+	policy := q.putPolicy(object, h)
+	policy.Expires = uint64(expire)
+	upload = SignedUpload{
+		Method: http.MethodPost,
+		Header: make(map[string]string),
+		Form: map[string]string{
+			"key":   object,
+			"token": policy.UploadToken(q.mac),
+		},
+	}
+	for k, v := range qiniuMetaParams(h) {
+		upload.Form[k] = v
 	}
+	form := storage.NewFormUploader(&storage.Config{Zone: q.Zone})
+	upload.URL, err = form.UpHost(q.AccessKey, q.Bucket)
+	return
+}
 
-	var resp *http.Response
+func (q *QINIU) PostPolicy(keyPrefix string, maxSize int64, contentTypes []string, expire int64) (form PostForm, err error) {
+	return q.PostPolicyContext(context.Background(), keyPrefix, maxSize, contentTypes, expire)
+}
 
-	resp, err = req.Response()
-	if err != nil {
+// 七牛通过上传凭证限制上传，文件名由 saveKey 强制指定为 keyPrefix + 用户上传的文件名
+// https://developer.qiniu.com/kodo/manual/1206/put-policy
+func (q *QINIU) PostPolicyContext(ctx context.Context, keyPrefix string, maxSize int64, contentTypes []string, expire int64) (form PostForm, err error) {
+	defer func() { err = qiniuError("PostPolicy", keyPrefix, err) }()
+	if err = ctx.Err(); err != nil {
 		return
 	}
-	defer resp.Body.Close()
-
-	data, _ := ioutil.ReadAll(resp.Body)
-
-	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
-		return fmt.Errorf("%v: %v", resp.Status, string(data))
+	if expire <= 0 {
+		expire = oneHour
+	}
+	policy := storage.PutPolicy{
+		Scope:        q.Bucket,
+		Expires:      uint64(expire),
+		SaveKey:      objectRel(keyPrefix) + "$(fname)",
+		ForceSaveKey: true,
+		FsizeLimit:   maxSize,
+		MimeLimit:    strings.Join(contentTypes, ";"),
+	}
+	form = PostForm{
+		Fields: map[string]string{
+			"token": policy.UploadToken(q.mac),
+		},
 	}
+	uploader := storage.NewFormUploader(&storage.Config{Zone: q.Zone})
+	form.URL, err = uploader.UpHost(q.AccessKey, q.Bucket)
+	return
+}
 
-	err = ioutil.WriteFile(savePath, data, os.ModePerm)
+// 不带 context 的下载，保持原来 30 分钟的超时时间
+func (q *QINIU) Download(object string, savePath string) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+	return q.DownloadContext(ctx, object, savePath)
+}
 
-	return
+func (q *QINIU) DownloadContext(ctx context.Context, object string, savePath string) (err error) {
+	defer func() { err = qiniuError("Download", object, err) }()
+	var reader io.ReadCloser
+	reader, _, err = q.GetContext(ctx, object)
+	if err != nil {
+		return
+	}
+	defer reader.Close()
+	return saveTo(reader, savePath)
 }
 
 func (q *QINIU) GetInfo(object string) (info File, err error) {
-	var fileInfo storage.FileInfo
+	return q.GetInfoContext(context.Background(), object)
+}
+
+func (q *QINIU) GetInfoContext(ctx context.Context, object string) (info File, err error) {
+	defer func() { err = qiniuError("GetInfo", object, err) }()
+	var fileInfo qiniuStatRet
 
 	object = objectRel(object)
-	fileInfo, err = q.BucketManager.Stat(q.Bucket, object)
+	err = q.rsCall(ctx, &fileInfo, storage.URIStat(q.Bucket, object))
 	if err != nil {
 		return
 	}
@@ -175,34 +452,197 @@ func (q *QINIU) GetInfo(object string) (info File, err error) {
 		Name:    object,
 		Size:    fileInfo.Fsize,
 		ModTime: storage.ParsePutTime(fileInfo.PutTime),
-		IsDir:   fileInfo.Fsize == 0,
+		IsDir:   isDirKey(object),
+	}
+	header := map[string]string{
+		HeaderContentType:  fileInfo.MimeType,
+		HeaderStorageClass: qiniuStorageClass(fileInfo.Type),
+		"ETag":             fileInfo.Hash,
+	}
+	for k, v := range fileInfo.Meta {
+		header[qiniuDialect.metaPrefix+strings.TrimPrefix(strings.ToLower(k), qiniuDialect.metaPrefix)] = v
 	}
+	qiniuDialect.setHeader(&info, header)
 	return
 }
 
+func (q *QINIU) GetInfoIf(object string, cond Conditions) (info File, err error) {
+	return q.GetInfoIfContext(context.Background(), object, cond)
+}
+
+// 七牛的 stat 接口不支持条件请求，在客户端检查
+func (q *QINIU) GetInfoIfContext(ctx context.Context, object string, cond Conditions) (info File, err error) {
+	defer func() { err = qiniuError("GetInfoIf", object, err) }()
+	return getInfoIf(ctx, q.GetInfoContext, object, cond)
+}
+
+// stat 接口返回的自定义元数据在 x-qn-meta 中，SDK 的 FileInfo 没有这个字段
+type qiniuStatRet struct {
+	storage.FileInfo
+	Meta map[string]string `json:"x-qn-meta"`
+}
+
 func (q *QINIU) Lists(prefix string) (files []File, err error) {
-	var items []storage.ListItem
+	return q.ListsContext(context.Background(), prefix)
+}
 
-	prefix = objectRel(prefix)
-	limit := 1000
-	cfg := &storage.Config{
-		Zone: q.Zone,
+func (q *QINIU) ListsContext(ctx context.Context, prefix string) (files []File, err error) {
+	defer func() { err = qiniuError("Lists", prefix, err) }()
+	return listAll(ctx, q, prefix)
+}
+
+func (q *QINIU) ListPage(prefix, marker string, limit int) (files []File, nextMarker string, err error) {
+	return q.ListPageContext(context.Background(), prefix, marker, limit)
+}
+
+func (q *QINIU) ListPageContext(ctx context.Context, prefix, marker string, limit int) (files []File, nextMarker string, err error) {
+	defer func() { err = qiniuError("ListPage", prefix, err) }()
+	return q.list(ctx, objectRel(prefix), "", marker, limit)
+}
+
+func (q *QINIU) ListDir(dir, marker string, limit int) (files []File, nextMarker string, err error) {
+	return q.ListDirContext(context.Background(), dir, marker, limit)
+}
+
+func (q *QINIU) ListDirContext(ctx context.Context, dir, marker string, limit int) (files []File, nextMarker string, err error) {
+	defer func() { err = qiniuError("ListDir", dir, err) }()
+	return q.list(ctx, dirPrefix(dir), delimiter, marker, limit)
+}
+
+func (q *QINIU) list(ctx context.Context, prefix, delim, marker string, limit int) (files []File, nextMarker string, err error) {
+	var ret qiniuListRet
+
+	// 七牛每次最多列出 1000 个文件
+	if limit <= 0 || limit > 1000 {
+		limit = 1000
 	}
 
-	manager := storage.NewBucketManager(q.mac, cfg)
-	items, _, _, _, err = manager.ListFiles(q.Bucket, prefix, "", "", limit)
+	ret, err = q.listFiles(ctx, prefix, delim, marker, limit)
 	if err != nil {
 		return
 	}
 
-	for _, item := range items {
+	for _, dir := range ret.CommonPrefixes {
+		files = append(files, dirFile(dir))
+	}
+	for _, item := range ret.Items {
+		if delim != "" && item.Key == prefix {
+			continue
+		}
 		files = append(files, File{
-			ModTime: storage.ParsePutTime(item.PutTime),
-			Name:    objectRel(item.Key),
-			Size:    item.Fsize,
-			IsDir:   item.Fsize == 0,
+			ModTime:      storage.ParsePutTime(item.PutTime),
+			Name:         objectRel(item.Key),
+			Size:         item.Fsize,
+			IsDir:        isDirKey(item.Key),
+			ETag:         item.Hash,
+			ContentType:  item.MimeType,
+			StorageClass: qiniuStorageClass(item.Type),
 		})
 	}
+	nextMarker = ret.Marker
 
 	return
 }
+
+// 七牛 SDK 中 BucketManager 的方法内部固定使用 context.Background()，
+// 以下方法直接使用 BucketManager.Client 发起请求，以便 ctx 能够生效
+
+type qiniuListRet struct {
+	Marker         string             `json:"marker"`
+	Items          []storage.ListItem `json:"items"`
+	CommonPrefixes []string           `json:"commonPrefixes"`
+}
+
+func (q *QINIU) rsCall(ctx context.Context, ret interface{}, uri string) (err error) {
+	var host string
+	host, err = q.BucketManager.RsReqHost(q.Bucket)
+	if err != nil {
+		return
+	}
+	return q.BucketManager.Client.CredentialedCall(ctx, q.mac, auth.TokenQiniu, ret, http.MethodPost, host+uri, nil)
+}
+
+func (q *QINIU) batch(ctx context.Context, operations []string) (ret []storage.BatchOpRet, err error) {
+	if len(operations) > 1000 {
+		err = errors.New("batch operation count exceeds the limit of 1000")
+		return
+	}
+	scheme := "http://"
+	if q.BucketManager.Cfg.UseHTTPS {
+		scheme = "https://"
+	}
+	reqURL := fmt.Sprintf("%s%s/batch", scheme, q.BucketManager.Cfg.CentralRsHost)
+	params := map[string][]string{
+		"op": operations,
+	}
+	err = q.BucketManager.Client.CredentialedCallWithForm(ctx, q.mac, auth.TokenQiniu, &ret, http.MethodPost, reqURL, nil, params)
+	return
+}
+
+func (q *QINIU) listFiles(ctx context.Context, prefix, delimiter, marker string, limit int) (ret qiniuListRet, err error) {
+	var host string
+	host, err = q.BucketManager.RsfReqHost(q.Bucket)
+	if err != nil {
+		return
+	}
+	query := make(url.Values)
+	query.Add("bucket", q.Bucket)
+	if prefix != "" {
+		query.Add("prefix", prefix)
+	}
+	if delimiter != "" {
+		query.Add("delimiter", delimiter)
+	}
+	if marker != "" {
+		query.Add("marker", marker)
+	}
+	if limit > 0 {
+		query.Add("limit", strconv.Itoa(limit))
+	}
+	reqURL := host + "/list?" + query.Encode()
+	err = q.BucketManager.Client.CredentialedCall(ctx, q.mac, auth.TokenQiniu, &ret, http.MethodPost, reqURL, nil)
+	return
+}
+
+// 七牛的文件存储类型：0 为标准存储，1 为低频存储，2 为归档存储
+func qiniuStorageClass(fileType int) string {
+	switch fileType {
+	case 1:
+		return StorageIA
+	case 2:
+		return StorageArchive
+	}
+	return StorageStandard
+}
+
+// 通用的存储类型转换为七牛的文件存储类型，不认识的存储类型使用标准存储
+func qiniuFileType(class string) int {
+	switch strings.ToUpper(class) {
+	case StorageIA:
+		return 1
+	case StorageArchive:
+		return 2
+	}
+	return 0
+}
+
+// 将 SDK 返回的错误转换为 StoreError，七牛使用自定义的状态码：
+// 612 文件不存在（下载时为 404），614 文件已存在，573 请求过于频繁，https://developer.qiniu.com/kodo/3928/error-responses
+func qiniuError(op, object string, err error) error {
+	if keepError(err) {
+		return err
+	}
+	if e, ok := err.(*client.ErrorInfo); ok {
+		se := newStoreError("qiniu", op, object, e.Code, "", e.Reqid, err)
+		switch e.Code {
+		case 612, http.StatusNotFound:
+			se.kind = ErrNotExist
+		case 614:
+			se.kind = ErrExist
+		case 573:
+			se.kind = ErrThrottled
+		}
+		return se
+	}
+	return newStoreError("qiniu", op, object, 0, "", "", err)
+}
@@ -20,6 +20,10 @@ func init() {
 	secret := beego.AppConfig.String("qiniu::secretKey")
 	bucket := beego.AppConfig.String("qiniu::bucket")
 	domain := strings.ToLower(beego.AppConfig.String("qiniu::domain"))
+	// 没有配置 qiniu 时跳过测试
+	if key == "" {
+		return
+	}
 	Qiniu, err = NewQINIU(key, secret, bucket, domain)
 	if err != nil {
 		panic(err)
@@ -28,6 +32,9 @@ func init() {
 
 
 func TestQINIU(t *testing.T) {
+	if Qiniu == nil {
+		t.Skip("qiniu is not configured")
+	}
 	// upload
 	t.Log("=====Upload=====", objectSVG, objectSVGGzip)
 	err = Qiniu.Upload(objectSVG, objectSVG,headerSVG)
@@ -69,6 +76,9 @@ func TestQINIU(t *testing.T) {
 }
 
 func TestQINIU_Delete(t *testing.T) {
+	if Qiniu == nil {
+		t.Skip("qiniu is not configured")
+	}
 	if err := Qiniu.Delete(objectSVG, objectSVGGzip); err != nil {
 		t.Error(err)
 	} else {
@@ -0,0 +1,206 @@
+package CloudStore
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Config 为创建云存储所需的配置，字段与 conf/app.conf.example 中各节的配置项一一对应，
+// 不同的云存储只使用其中的一部分；其他配置项放在 Options 中，供第三方驱动使用
+type Config struct {
+	AccessKey string
+	SecretKey string
+	Bucket    string
+	Endpoint  string
+	Domain    string
+	AppID     string // 腾讯云 COS
+	Region    string // 腾讯云 COS
+	Operator  string // 又拍云
+	Password  string // 又拍云
+	Secret    string // 又拍云 token 防盗链密钥，Local、Memory 的签名密钥
+	Root      string // Local 的根目录
+	Options   map[string]string
+}
+
+// ConfigFromMap 从配置文件的一节（如 beego.AppConfig.GetSection("oss")）创建 Config，配置项不区分大小写，
+// 未知的配置项以小写的名称放入 Options
+func ConfigFromMap(section map[string]string) (cfg Config) {
+	fields := cfg.fields()
+	for k, v := range section {
+		if field, ok := fields[strings.ToLower(k)]; ok {
+			*field = v
+			continue
+		}
+		if cfg.Options == nil {
+			cfg.Options = make(map[string]string)
+		}
+		cfg.Options[strings.ToLower(k)] = v
+	}
+	return
+}
+
+// 配置项名称（小写）对应的字段
+func (cfg *Config) fields() map[string]*string {
+	return map[string]*string{
+		"accesskey": &cfg.AccessKey,
+		"secretkey": &cfg.SecretKey,
+		"bucket":    &cfg.Bucket,
+		"endpoint":  &cfg.Endpoint,
+		"domain":    &cfg.Domain,
+		"appid":     &cfg.AppID,
+		"region":    &cfg.Region,
+		"operator":  &cfg.Operator,
+		"password":  &cfg.Password,
+		"secret":    &cfg.Secret,
+		"root":      &cfg.Root,
+	}
+}
+
+// Require 检查必填的配置项，names 为配置项名称，如 "bucket"、"endpoint"
+func (cfg Config) Require(names ...string) error {
+	fields := cfg.fields()
+	var missing []string
+	for _, name := range names {
+		field, ok := fields[strings.ToLower(name)]
+		if ok && *field == "" || !ok && cfg.Options[strings.ToLower(name)] == "" {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing config: %v", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// Driver 根据配置创建云存储
+type Driver func(cfg Config) (CloudStore, error)
+
+var (
+	driversMu sync.RWMutex
+	drivers   = make(map[string]Driver)
+)
+
+// Register 注册名称为 name 的驱动，与 database/sql 一样，重复注册或者 driver 为 nil 时 panic
+func Register(name string, driver Driver) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+	if driver == nil {
+		panic("CloudStore: Register driver is nil")
+	}
+	if _, dup := drivers[name]; dup {
+		panic("CloudStore: Register called twice for driver " + name)
+	}
+	drivers[name] = driver
+}
+
+// Drivers 返回已注册的驱动名称
+func Drivers() (names []string) {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+	for name := range drivers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+// Open 使用名称为 driverName 的驱动创建云存储，内置的驱动有 oss、bos、cos、obs、upyun、qiniu、minio、local 以及 memory
+func Open(driverName string, cfg Config) (store CloudStore, err error) {
+	driversMu.RLock()
+	driver, ok := drivers[driverName]
+	driversMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("CloudStore: unknown driver %q (forgotten import?)", driverName)
+	}
+	store, err = driver(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("CloudStore: open %v: %w", driverName, err)
+	}
+	return
+}
+
+func init() {
+	Register("oss", func(cfg Config) (CloudStore, error) {
+		if err := cfg.Require("accessKey", "secretKey", "endpoint", "bucket"); err != nil {
+			return nil, err
+		}
+		return NewOSS(cfg.AccessKey, cfg.SecretKey, cfg.Endpoint, cfg.Bucket, cfg.Domain)
+	})
+	Register("bos", func(cfg Config) (CloudStore, error) {
+		if err := cfg.Require("accessKey", "secretKey", "bucket", "endpoint"); err != nil {
+			return nil, err
+		}
+		return NewBOS(cfg.AccessKey, cfg.SecretKey, cfg.Bucket, cfg.Endpoint, cfg.Domain)
+	})
+	Register("cos", func(cfg Config) (CloudStore, error) {
+		if err := cfg.Require("accessKey", "secretKey", "bucket", "appID", "region"); err != nil {
+			return nil, err
+		}
+		return NewCOS(cfg.AccessKey, cfg.SecretKey, cfg.Bucket, cfg.AppID, cfg.Region, cfg.Domain)
+	})
+	Register("obs", func(cfg Config) (CloudStore, error) {
+		if err := cfg.Require("accessKey", "secretKey", "bucket", "endpoint"); err != nil {
+			return nil, err
+		}
+		return NewOBS(cfg.AccessKey, cfg.SecretKey, cfg.Bucket, cfg.Endpoint, cfg.Domain)
+	})
+	Register("upyun", func(cfg Config) (CloudStore, error) {
+		if err := cfg.Require("bucket", "operator", "password", "domain"); err != nil {
+			return nil, err
+		}
+		return NewUpYun(cfg.Bucket, cfg.Operator, cfg.Password, cfg.Domain, cfg.Secret), nil
+	})
+	// 七牛在创建时会查询 bucket 所在的区域，需要访问网络
+	Register("qiniu", func(cfg Config) (CloudStore, error) {
+		if err := cfg.Require("accessKey", "secretKey", "bucket", "domain"); err != nil {
+			return nil, err
+		}
+		return NewQINIU(cfg.AccessKey, cfg.SecretKey, cfg.Bucket, cfg.Domain)
+	})
+	Register("minio", func(cfg Config) (CloudStore, error) {
+		if err := cfg.Require("accessKey", "secretKey", "bucket", "endpoint"); err != nil {
+			return nil, err
+		}
+		return NewMinIO(cfg.AccessKey, cfg.SecretKey, cfg.Bucket, cfg.Endpoint, cfg.Domain)
+	})
+	// Options 中的 public 为 true 时允许不带签名下载文件
+	Register("local", func(cfg Config) (CloudStore, error) {
+		if err := cfg.Require("root"); err != nil {
+			return nil, err
+		}
+		public, err := cfg.boolOption("public")
+		if err != nil {
+			return nil, err
+		}
+		l, err := NewLocal(cfg.Root, cfg.Domain, cfg.Secret)
+		if err != nil {
+			return nil, err
+		}
+		l.Public = public
+		return l, nil
+	})
+	Register("memory", func(cfg Config) (CloudStore, error) {
+		public, err := cfg.boolOption("public")
+		if err != nil {
+			return nil, err
+		}
+		m := NewMemory(cfg.Domain, cfg.Secret)
+		m.Public = public
+		return m, nil
+	})
+}
+
+func (cfg Config) boolOption(name string) (b bool, err error) {
+	v, ok := cfg.Options[name]
+	if !ok || v == "" {
+		return
+	}
+	if b, err = strconv.ParseBool(v); err != nil {
+		err = errors.New("invalid " + name + ": " + v)
+	}
+	return
+}
@@ -0,0 +1,81 @@
+package CloudStore
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConfigFromMap(t *testing.T) {
+	cfg := ConfigFromMap(map[string]string{
+		"accesskey": "ak",
+		"SecretKey": "sk",
+		"appID":     "1251298948",
+		"Public":    "true",
+	})
+	if cfg.AccessKey != "ak" || cfg.SecretKey != "sk" || cfg.AppID != "1251298948" {
+		t.Errorf("ConfigFromMap = %+v", cfg)
+	}
+	if cfg.Options["public"] != "true" {
+		t.Errorf("Options = %v", cfg.Options)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	store, err := Open("memory", Config{Secret: "secret", Options: map[string]string{"public": "true"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, ok := store.(*Memory); !ok || !m.Public || m.Secret != "secret" {
+		t.Errorf("Open(memory) = %#v", store)
+	}
+
+	store, err = Open("local", Config{Root: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.(*Local); !ok {
+		t.Errorf("Open(local) = %T", store)
+	}
+
+	// 不需要访问网络的云存储
+	store, err = Open("oss", Config{AccessKey: "ak", SecretKey: "sk", Endpoint: "oss-cn-shenzhen.aliyuncs.com", Bucket: "dochub"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o, ok := store.(*OSS); !ok || o.Domain != "https://dochub.oss-cn-shenzhen.aliyuncs.com" {
+		t.Errorf("Open(oss) = %#v", store)
+	}
+
+	if _, err = Open("oss", Config{AccessKey: "ak"}); err == nil || !strings.Contains(err.Error(), "secretKey, endpoint, bucket") {
+		t.Errorf("Open(oss) with missing config: %v", err)
+	}
+	if _, err = Open("memory", Config{Options: map[string]string{"public": "maybe"}}); err == nil {
+		t.Error("Open(memory) with invalid public option succeeded")
+	}
+	if _, err = Open("unknown", Config{}); err == nil {
+		t.Error("Open(unknown) succeeded")
+	}
+}
+
+func TestRegister(t *testing.T) {
+	Register("registry-test", func(cfg Config) (CloudStore, error) {
+		return NewMemory(cfg.Domain, cfg.Secret), nil
+	})
+	if _, err := Open("registry-test", Config{}); err != nil {
+		t.Error(err)
+	}
+	found := false
+	for _, name := range Drivers() {
+		found = found || name == "registry-test"
+	}
+	if !found {
+		t.Errorf("Drivers() = %v", Drivers())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Register twice did not panic")
+		}
+	}()
+	Register("registry-test", func(cfg Config) (CloudStore, error) { return nil, nil })
+}
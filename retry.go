@@ -0,0 +1,347 @@
+package CloudStore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy 重试策略，零值表示使用默认值
+type RetryPolicy struct {
+	MaxAttempts   int                  // 最多尝试的次数（包括第一次），默认 3
+	BaseDelay     time.Duration        // 第一次重试前的等待时间，之后每次翻倍，默认 200ms
+	MaxDelay      time.Duration        // 等待时间的上限，默认 10s
+	ThrottleDelay time.Duration        // 被限流（ErrThrottled）时的最短等待时间，默认 1s
+	Retryable     func(err error) bool // 判断错误是否可以重试，默认为 IsRetryable
+
+	// OnRetry 在每次重试前调用，attempt 从 1 开始，可以用于记录日志
+	OnRetry func(op, key string, attempt int, err error, delay time.Duration)
+}
+
+func (p RetryPolicy) normalize() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = 3
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = 200 * time.Millisecond
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = 10 * time.Second
+	}
+	if p.ThrottleDelay <= 0 {
+		p.ThrottleDelay = time.Second
+	}
+	if p.Retryable == nil {
+		p.Retryable = IsRetryable
+	}
+	return p
+}
+
+// 第 attempt 次重试前的等待时间：指数退避，并在 [d/2, d) 之间随机，避免多个客户端同时重试
+func (p RetryPolicy) delay(attempt int, err error) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < attempt && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if errors.Is(err, ErrThrottled) && d < p.ThrottleDelay {
+		d = p.ThrottleDelay
+	}
+	if d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// IsRetryable 判断错误是否为临时性的错误：被限流、5xx、请求超时、网络超时以及连接被拒绝或者重置等连接错误可以重试；
+// 文件不存在、没有权限、其他 4xx、域名不存在、证书错误以及 ctx 被取消或者超时不重试
+func IsRetryable(err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return false
+	case errors.Is(err, ErrThrottled):
+		return true
+	case errors.Is(err, ErrNotExist), errors.Is(err, ErrPermission):
+		return false
+	}
+	var e *StoreError
+	if errors.As(err, &e) && e.StatusCode > 0 {
+		return e.StatusCode >= 500 || e.StatusCode == http.StatusRequestTimeout
+	}
+	// *url.Error 也实现了 net.Error，不能据此判断，DNS 解析只在超时或者临时性的失败时重试
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTimeout || dnsErr.IsTemporary
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return true
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE)
+}
+
+// retryStore 在可以重试的错误时重新执行幂等的操作；
+// Move 以及签名相关的操作不重试，Put 只在 reader 实现了 io.Seeker 时重试；
+// 带有 If-Match、If-None-Match 的上传不重试：失败的请求可能已经在云存储上成功，重试会返回 ErrExist 等错误
+type retryStore struct {
+	store  CloudStore
+	policy RetryPolicy
+}
+
+// WithRetry 为 store 加上自动重试，失败时按 policy 指数退避后重试，返回最后一次的错误
+func WithRetry(store CloudStore, policy RetryPolicy) CloudStore {
+	return &retryStore{store: store, policy: policy.normalize()}
+}
+
+// 上传是否带有条件请求头
+func isConditional(headers ...map[string]string) bool {
+	h := parseHeaders(headers...)
+	return h.IfMatch != "" || h.IfNoneMatch != ""
+}
+
+func (r *retryStore) retry(ctx context.Context, op, key string, fn func() error) (err error) {
+	for attempt := 1; ; attempt++ {
+		err = fn()
+		if err == nil || attempt >= r.policy.MaxAttempts || !r.policy.Retryable(err) {
+			return
+		}
+		delay := r.policy.delay(attempt, err)
+		if r.policy.OnRetry != nil {
+			r.policy.OnRetry(op, key, attempt, err, delay)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+func (r *retryStore) Delete(objects ...string) (err error) {
+	return r.DeleteContext(context.Background(), objects...)
+}
+
+// 各云存储删除不存在的文件时不返回错误，重试是安全的
+func (r *retryStore) DeleteContext(ctx context.Context, objects ...string) (err error) {
+	key := ""
+	if len(objects) == 1 {
+		key = objects[0]
+	}
+	return r.retry(ctx, "Delete", key, func() error {
+		return r.store.DeleteContext(ctx, objects...)
+	})
+}
+
+func (r *retryStore) GetSignURL(object string, expire int64) (link string, err error) {
+	return r.GetSignURLContext(context.Background(), object, expire)
+}
+
+func (r *retryStore) GetSignURLContext(ctx context.Context, object string, expire int64) (link string, err error) {
+	return r.store.GetSignURLContext(ctx, object, expire)
+}
+
+func (r *retryStore) IsExist(object string) (err error) {
+	return r.IsExistContext(context.Background(), object)
+}
+
+func (r *retryStore) IsExistContext(ctx context.Context, object string) (err error) {
+	return r.retry(ctx, "IsExist", object, func() error {
+		return r.store.IsExistContext(ctx, object)
+	})
+}
+
+func (r *retryStore) Lists(prefix string) (files []File, err error) {
+	return r.ListsContext(context.Background(), prefix)
+}
+
+func (r *retryStore) ListsContext(ctx context.Context, prefix string) (files []File, err error) {
+	err = r.retry(ctx, "Lists", prefix, func() (err error) {
+		files, err = r.store.ListsContext(ctx, prefix)
+		return
+	})
+	return
+}
+
+func (r *retryStore) Upload(tmpFile string, saveFile string, headers ...map[string]string) (err error) {
+	return r.UploadContext(context.Background(), tmpFile, saveFile, headers...)
+}
+
+func (r *retryStore) UploadContext(ctx context.Context, tmpFile string, saveFile string, headers ...map[string]string) (err error) {
+	if isConditional(headers...) {
+		return r.store.UploadContext(ctx, tmpFile, saveFile, headers...)
+	}
+	return r.retry(ctx, "Upload", saveFile, func() error {
+		return r.store.UploadContext(ctx, tmpFile, saveFile, headers...)
+	})
+}
+
+func (r *retryStore) Download(object string, savePath string) (err error) {
+	return r.DownloadContext(context.Background(), object, savePath)
+}
+
+func (r *retryStore) DownloadContext(ctx context.Context, object string, savePath string) (err error) {
+	return r.retry(ctx, "Download", object, func() error {
+		return r.store.DownloadContext(ctx, object, savePath)
+	})
+}
+
+func (r *retryStore) GetInfo(object string) (info File, err error) {
+	return r.GetInfoContext(context.Background(), object)
+}
+
+func (r *retryStore) GetInfoContext(ctx context.Context, object string) (info File, err error) {
+	err = r.retry(ctx, "GetInfo", object, func() (err error) {
+		info, err = r.store.GetInfoContext(ctx, object)
+		return
+	})
+	return
+}
+
+func (r *retryStore) GetInfoIf(object string, cond Conditions) (info File, err error) {
+	return r.GetInfoIfContext(context.Background(), object, cond)
+}
+
+func (r *retryStore) GetInfoIfContext(ctx context.Context, object string, cond Conditions) (info File, err error) {
+	err = r.retry(ctx, "GetInfoIf", object, func() (err error) {
+		info, err = r.store.GetInfoIfContext(ctx, object, cond)
+		return
+	})
+	return
+}
+
+func (r *retryStore) Put(object string, reader io.Reader, size int64, headers ...map[string]string) (err error) {
+	return r.PutContext(context.Background(), object, reader, size, headers...)
+}
+
+// reader 实现了 io.Seeker 时，重试前回到开始上传时的位置
+func (r *retryStore) PutContext(ctx context.Context, object string, reader io.Reader, size int64, headers ...map[string]string) (err error) {
+	seeker, ok := reader.(io.Seeker)
+	if !ok || isConditional(headers...) {
+		return r.store.PutContext(ctx, object, reader, size, headers...)
+	}
+	offset, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return r.store.PutContext(ctx, object, reader, size, headers...)
+	}
+	first := true
+	return r.retry(ctx, "Put", object, func() error {
+		if !first {
+			if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+				return err
+			}
+		}
+		first = false
+		return r.store.PutContext(ctx, object, reader, size, headers...)
+	})
+}
+
+func (r *retryStore) Get(object string) (reader io.ReadCloser, info File, err error) {
+	return r.GetContext(context.Background(), object)
+}
+
+// 只重试打开文件，读取数据时的错误由调用方处理
+func (r *retryStore) GetContext(ctx context.Context, object string) (reader io.ReadCloser, info File, err error) {
+	err = r.retry(ctx, "Get", object, func() (err error) {
+		reader, info, err = r.store.GetContext(ctx, object)
+		return
+	})
+	return
+}
+
+func (r *retryStore) GetIf(object string, cond Conditions) (reader io.ReadCloser, info File, err error) {
+	return r.GetIfContext(context.Background(), object, cond)
+}
+
+func (r *retryStore) GetIfContext(ctx context.Context, object string, cond Conditions) (reader io.ReadCloser, info File, err error) {
+	err = r.retry(ctx, "GetIf", object, func() (err error) {
+		reader, info, err = r.store.GetIfContext(ctx, object, cond)
+		return
+	})
+	return
+}
+
+func (r *retryStore) ListPage(prefix, marker string, limit int) (files []File, nextMarker string, err error) {
+	return r.ListPageContext(context.Background(), prefix, marker, limit)
+}
+
+func (r *retryStore) ListPageContext(ctx context.Context, prefix, marker string, limit int) (files []File, nextMarker string, err error) {
+	err = r.retry(ctx, "ListPage", prefix, func() (err error) {
+		files, nextMarker, err = r.store.ListPageContext(ctx, prefix, marker, limit)
+		return
+	})
+	return
+}
+
+func (r *retryStore) ListDir(dir, marker string, limit int) (files []File, nextMarker string, err error) {
+	return r.ListDirContext(context.Background(), dir, marker, limit)
+}
+
+func (r *retryStore) ListDirContext(ctx context.Context, dir, marker string, limit int) (files []File, nextMarker string, err error) {
+	err = r.retry(ctx, "ListDir", dir, func() (err error) {
+		files, nextMarker, err = r.store.ListDirContext(ctx, dir, marker, limit)
+		return
+	})
+	return
+}
+
+func (r *retryStore) Copy(src, dst string) (err error) {
+	return r.CopyContext(context.Background(), src, dst)
+}
+
+func (r *retryStore) CopyContext(ctx context.Context, src, dst string) (err error) {
+	return r.retry(ctx, "Copy", dst, func() error {
+		return r.store.CopyContext(ctx, src, dst)
+	})
+}
+
+func (r *retryStore) Move(src, dst string) (err error) {
+	return r.MoveContext(context.Background(), src, dst)
+}
+
+// 移动可能已经复制完成但删除源文件失败，重试会得到源文件不存在的错误，因此不重试
+func (r *retryStore) MoveContext(ctx context.Context, src, dst string) (err error) {
+	return r.store.MoveContext(ctx, src, dst)
+}
+
+func (r *retryStore) UploadMultipart(tmpFile, saveFile string, opts MultipartOptions, headers ...map[string]string) (err error) {
+	return r.UploadMultipartContext(context.Background(), tmpFile, saveFile, opts, headers...)
+}
+
+// 开启 Checkpoint 时重试会跳过已上传的分片
+func (r *retryStore) UploadMultipartContext(ctx context.Context, tmpFile, saveFile string, opts MultipartOptions, headers ...map[string]string) (err error) {
+	if isConditional(headers...) {
+		return r.store.UploadMultipartContext(ctx, tmpFile, saveFile, opts, headers...)
+	}
+	return r.retry(ctx, "UploadMultipart", saveFile, func() error {
+		return r.store.UploadMultipartContext(ctx, tmpFile, saveFile, opts, headers...)
+	})
+}
+
+func (r *retryStore) GetSignUploadURL(object string, expire int64, headers ...map[string]string) (upload SignedUpload, err error) {
+	return r.GetSignUploadURLContext(context.Background(), object, expire, headers...)
+}
+
+func (r *retryStore) GetSignUploadURLContext(ctx context.Context, object string, expire int64, headers ...map[string]string) (upload SignedUpload, err error) {
+	return r.store.GetSignUploadURLContext(ctx, object, expire, headers...)
+}
+
+func (r *retryStore) PostPolicy(keyPrefix string, maxSize int64, contentTypes []string, expire int64) (form PostForm, err error) {
+	return r.PostPolicyContext(context.Background(), keyPrefix, maxSize, contentTypes, expire)
+}
+
+func (r *retryStore) PostPolicyContext(ctx context.Context, keyPrefix string, maxSize int64, contentTypes []string, expire int64) (form PostForm, err error) {
+	return r.store.PostPolicyContext(ctx, keyPrefix, maxSize, contentTypes, expire)
+}
@@ -0,0 +1,197 @@
+package CloudStore
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// flakyStore 在前 failures 次调用时返回 err
+type flakyStore struct {
+	CloudStore
+	failures int
+	err      error
+	calls    int
+}
+
+func (f *flakyStore) fail() error {
+	f.calls++
+	if f.calls <= f.failures {
+		return f.err
+	}
+	return nil
+}
+
+func (f *flakyStore) GetInfoContext(ctx context.Context, object string) (info File, err error) {
+	if err = f.fail(); err != nil {
+		return
+	}
+	return f.CloudStore.GetInfoContext(ctx, object)
+}
+
+func (f *flakyStore) GetInfoIfContext(ctx context.Context, object string, cond Conditions) (info File, err error) {
+	if err = f.fail(); err != nil {
+		return
+	}
+	return f.CloudStore.GetInfoIfContext(ctx, object, cond)
+}
+
+func (f *flakyStore) GetIfContext(ctx context.Context, object string, cond Conditions) (reader io.ReadCloser, info File, err error) {
+	if err = f.fail(); err != nil {
+		return
+	}
+	return f.CloudStore.GetIfContext(ctx, object, cond)
+}
+
+func (f *flakyStore) PutContext(ctx context.Context, object string, reader io.Reader, size int64, headers ...map[string]string) (err error) {
+	// 读取一部分数据之后失败
+	if f.calls < f.failures {
+		io.CopyN(ioutil.Discard, reader, 2)
+	}
+	if err = f.fail(); err != nil {
+		return
+	}
+	return f.CloudStore.PutContext(ctx, object, reader, size, headers...)
+}
+
+func (f *flakyStore) MoveContext(ctx context.Context, src, dst string) (err error) {
+	if err = f.fail(); err != nil {
+		return
+	}
+	return f.CloudStore.MoveContext(ctx, src, dst)
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+var fastRetry = RetryPolicy{BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond, ThrottleDelay: 2 * time.Millisecond}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{newStoreError("oss", "GetInfo", "a", 503, "ServiceUnavailable", "", errors.New("unavailable")), true},
+		{newStoreError("oss", "GetInfo", "a", 500, "", "", errors.New("internal")), true},
+		{newStoreError("oss", "GetInfo", "a", 503, "SlowDown", "", errors.New("slow down")), true},
+		{newStoreError("qiniu", "GetInfo", "a", 429, "", "", errors.New("too many requests")), true},
+		{newStoreError("oss", "GetInfo", "a", 404, "NoSuchKey", "", errors.New("not found")), false},
+		{newStoreError("oss", "GetInfo", "a", 403, "AccessDenied", "", errors.New("denied")), false},
+		{newStoreError("oss", "GetInfo", "a", 400, "InvalidArgument", "", errors.New("bad request")), false},
+		{newStoreError("oss", "GetInfo", "a", 0, "", "", fmt.Errorf("read: %w", io.ErrUnexpectedEOF)), true},
+		{newStoreError("oss", "GetInfo", "a", 0, "", "", context.Canceled), false},
+		{errors.New("open /tmp/a: no such file or directory"), false},
+		{&url.Error{Op: "Get", URL: "https://a", Err: &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}}, true},
+		{&url.Error{Op: "Get", URL: "https://a", Err: &net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "no such host", Name: "a", IsNotFound: true}}}, false},
+		{&url.Error{Op: "Get", URL: "https://a", Err: &net.DNSError{Err: "i/o timeout", Name: "a", IsTimeout: true}}, true},
+		{&url.Error{Op: "Get", URL: "https://a", Err: x509.UnknownAuthorityError{}}, false},
+		{&url.Error{Op: "Get", URL: "https://a", Err: timeoutError{}}, true},
+	}
+	for _, tt := range tests {
+		if got := IsRetryable(tt.err); got != tt.want {
+			t.Errorf("IsRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestWithRetry(t *testing.T) {
+	m := NewMemory("", "")
+	if err := m.Put("a.txt", strings.NewReader("hello"), 5); err != nil {
+		t.Fatal(err)
+	}
+
+	unavailable := newStoreError("memory", "GetInfo", "a.txt", 503, "", "", errors.New("unavailable"))
+	f := &flakyStore{CloudStore: m, failures: 2, err: unavailable}
+	var retries []int
+	policy := fastRetry
+	policy.OnRetry = func(op, key string, attempt int, err error, delay time.Duration) {
+		retries = append(retries, attempt)
+	}
+	if _, err := WithRetry(f, policy).GetInfo("a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if f.calls != 3 || len(retries) != 2 {
+		t.Errorf("calls = %v, retries = %v", f.calls, retries)
+	}
+
+	// 超过最大次数时返回最后一次的错误
+	f = &flakyStore{CloudStore: m, failures: 5, err: unavailable}
+	if _, err := WithRetry(f, fastRetry).GetInfo("a.txt"); err != unavailable || f.calls != 3 {
+		t.Errorf("GetInfo = %v, calls = %v", err, f.calls)
+	}
+
+	// 不可重试的错误
+	denied := newStoreError("memory", "GetInfo", "a.txt", 403, "AccessDenied", "", errors.New("denied"))
+	f = &flakyStore{CloudStore: m, failures: 1, err: denied}
+	if _, err := WithRetry(f, fastRetry).GetInfo("a.txt"); !errors.Is(err, ErrPermission) || f.calls != 1 {
+		t.Errorf("GetInfo = %v, calls = %v", err, f.calls)
+	}
+
+	// 被限流
+	throttled := newStoreError("memory", "GetInfo", "a.txt", 503, "SlowDown", "", errors.New("slow down"))
+	f = &flakyStore{CloudStore: m, failures: 1, err: throttled}
+	if _, err := WithRetry(f, fastRetry).GetInfo("a.txt"); err != nil || f.calls != 2 {
+		t.Errorf("GetInfo = %v, calls = %v", err, f.calls)
+	}
+
+	// Move 不重试
+	f = &flakyStore{CloudStore: m, failures: 1, err: unavailable}
+	if err := WithRetry(f, fastRetry).Move("a.txt", "b.txt"); err != unavailable || f.calls != 1 {
+		t.Errorf("Move = %v, calls = %v", err, f.calls)
+	}
+}
+
+func TestWithRetryPut(t *testing.T) {
+	m := NewMemory("", "")
+	unavailable := newStoreError("memory", "Put", "a.txt", 503, "", "", errors.New("unavailable"))
+
+	// 重试前回到开始的位置
+	f := &flakyStore{CloudStore: m, failures: 2, err: unavailable}
+	if err := WithRetry(f, fastRetry).Put("a.txt", strings.NewReader("hello"), 5); err != nil {
+		t.Fatal(err)
+	}
+	reader, _, err := m.Get("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	if b, _ := ioutil.ReadAll(reader); string(b) != "hello" {
+		t.Errorf("content = %q", b)
+	}
+
+	// 不能 Seek 的 reader 不重试
+	f = &flakyStore{CloudStore: m, failures: 1, err: unavailable}
+	if err := WithRetry(f, fastRetry).Put("b.txt", ioutil.NopCloser(strings.NewReader("hello")), 5); err != unavailable || f.calls != 1 {
+		t.Errorf("Put = %v, calls = %v", err, f.calls)
+	}
+
+	// 条件上传不重试，第一次请求可能已经成功
+	f = &flakyStore{CloudStore: m, failures: 1, err: unavailable}
+	createOnly := map[string]string{HeaderIfNoneMatch: "*"}
+	if err := WithRetry(f, fastRetry).Put("c.txt", strings.NewReader("hello"), 5, createOnly); err != unavailable || f.calls != 1 {
+		t.Errorf("Put(If-None-Match) = %v, calls = %v", err, f.calls)
+	}
+}
+
+func TestWithRetryContext(t *testing.T) {
+	unavailable := newStoreError("memory", "GetInfo", "a.txt", 503, "", "", errors.New("unavailable"))
+	f := &flakyStore{CloudStore: NewMemory("", ""), failures: 5, err: unavailable}
+	ctx, cancel := context.WithCancel(context.Background())
+	policy := RetryPolicy{BaseDelay: time.Hour, OnRetry: func(string, string, int, error, time.Duration) { cancel() }}
+	if _, err := WithRetry(f, policy).GetInfoContext(ctx, "a.txt"); err != unavailable || f.calls != 1 {
+		t.Errorf("GetInfo = %v, calls = %v", err, f.calls)
+	}
+}
@@ -0,0 +1,721 @@
+// Package storetest 为 CloudStore 各实现的一致性测试，断言各云存储对相同操作的行为一致：
+// 文件不存在时的错误、条件请求、header 和自定义元数据的保存、gzip 压缩的文件、按前缀列出文件、复制移动以及签名链接的有效期等。
+//
+//	func TestMyStore(t *testing.T) {
+//		storetest.Run(t, func(t *testing.T) CloudStore.CloudStore {
+//			return newMyStore(t)
+//		})
+//	}
+//
+// 测试使用的文件都在一个随机的前缀下，测试结束后会被删除，因此也可以用于真实的云存储。
+package storetest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TruthHun/CloudStore"
+)
+
+// Factory 为每个子测试创建一个 CloudStore，需要的清理工作使用 t.Cleanup 注册
+type Factory func(t *testing.T) CloudStore.CloudStore
+
+var tests = []struct {
+	name string
+	fn   func(t *testing.T, s *suite)
+}{
+	{"MissingKey", testMissingKey},
+	{"PutGet", testPutGet},
+	{"PutUnknownSize", testPutUnknownSize},
+	{"Overwrite", testOverwrite},
+	{"ConditionalRead", testConditionalRead},
+	{"ConditionalPut", testConditionalPut},
+	{"HeaderRoundTrip", testHeaderRoundTrip},
+	{"GzipContentEncoding", testGzipContentEncoding},
+	{"StandardHeaders", testStandardHeaders},
+	{"Metadata", testMetadata},
+	{"StorageClass", testStorageClass},
+	{"ListPrefix", testListPrefix},
+	{"ListDir", testListDir},
+	{"CopyMove", testCopyMove},
+	{"Delete", testDelete},
+	{"SignURL", testSignURL},
+	{"SignURLExpiry", testSignURLExpiry},
+	{"SignUpload", testSignUpload},
+	{"SignCanceled", testSignCanceled},
+	{"Canceled", testCanceled},
+}
+
+// Run 依次运行全部一致性测试，skip 为需要跳过的子测试名称，用于云存储本身不支持的功能
+func Run(t *testing.T, factory Factory, skip ...string) {
+	skipped := make(map[string]bool)
+	for _, name := range skip {
+		skipped[name] = true
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			if skipped[test.name] {
+				t.Skip("not supported")
+			}
+			s := &suite{
+				store:  factory(t),
+				prefix: fmt.Sprintf("storetest/%d-%d/", time.Now().UnixNano(), rand.Int63()),
+			}
+			t.Cleanup(func() { s.cleanup(t) })
+			test.fn(t, s)
+		})
+	}
+}
+
+type suite struct {
+	store   CloudStore.CloudStore
+	prefix  string
+	objects []string
+}
+
+func (s *suite) key(name string) string {
+	return s.prefix + name
+}
+
+// 上传文件并记录下来，测试结束后删除
+func (s *suite) put(t *testing.T, name string, content []byte, headers ...map[string]string) string {
+	t.Helper()
+	object := s.key(name)
+	if err := s.store.Put(object, bytes.NewReader(content), int64(len(content)), headers...); err != nil {
+		t.Fatalf("Put(%q): %v", object, err)
+	}
+	s.objects = append(s.objects, object)
+	return object
+}
+
+func (s *suite) get(t *testing.T, object string) []byte {
+	t.Helper()
+	reader, _, err := s.store.Get(object)
+	if err != nil {
+		t.Fatalf("Get(%q): %v", object, err)
+	}
+	defer reader.Close()
+	b, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("Get(%q): read: %v", object, err)
+	}
+	return b
+}
+
+func (s *suite) cleanup(t *testing.T) {
+	if len(s.objects) == 0 {
+		return
+	}
+	if err := s.store.Delete(s.objects...); err != nil {
+		t.Logf("cleanup: %v", err)
+	}
+}
+
+// 各云存储返回的 header 大小写不一致，按不区分大小写的方式查找
+func header(info CloudStore.File, key string) string {
+	for k, v := range info.Header {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return ""
+}
+
+func testMissingKey(t *testing.T, s *suite) {
+	object := s.key("missing.txt")
+	if err := s.store.IsExist(object); !errors.Is(err, CloudStore.ErrNotExist) {
+		t.Errorf("IsExist: got %v, want ErrNotExist", err)
+	}
+	if _, err := s.store.GetInfo(object); !errors.Is(err, CloudStore.ErrNotExist) {
+		t.Errorf("GetInfo: got %v, want ErrNotExist", err)
+	}
+	if reader, _, err := s.store.Get(object); !errors.Is(err, CloudStore.ErrNotExist) {
+		if err == nil {
+			reader.Close()
+		}
+		t.Errorf("Get: got %v, want ErrNotExist", err)
+	}
+	var storeErr *CloudStore.StoreError
+	if _, err := s.store.GetInfo(object); !errors.As(err, &storeErr) {
+		t.Errorf("GetInfo: got %T, want *CloudStore.StoreError", err)
+	}
+}
+
+func testPutGet(t *testing.T, s *suite) {
+	content := []byte("hello, cloud store")
+	object := s.put(t, "hello.txt", content)
+
+	if err := s.store.IsExist(object); err != nil {
+		t.Errorf("IsExist: %v", err)
+	}
+	info, err := s.store.GetInfo(object)
+	if err != nil {
+		t.Fatalf("GetInfo: %v", err)
+	}
+	if info.Size != int64(len(content)) {
+		t.Errorf("GetInfo: size = %v, want %v", info.Size, len(content))
+	}
+	if info.IsDir {
+		t.Error("GetInfo: IsDir = true")
+	}
+	if info.ModTime.IsZero() || time.Since(info.ModTime) > time.Hour {
+		t.Errorf("GetInfo: ModTime = %v", info.ModTime)
+	}
+	// 七牛的 hash 不是 MD5，本地存储不返回 ETag
+	if sum := fmt.Sprintf("%x", md5.Sum(content)); len(info.ETag) == len(sum) && !strings.EqualFold(info.ETag, sum) {
+		t.Errorf("GetInfo: ETag = %q, want %q", info.ETag, sum)
+	}
+	if got := s.get(t, object); !bytes.Equal(got, content) {
+		t.Errorf("Get: content = %q, want %q", got, content)
+	}
+}
+
+// 上传已存在的文件时直接覆盖，内容和 header 都使用新的
+// size 小于 0 时读取到 EOF，reader 不能 Seek 也不知道大小
+func testPutUnknownSize(t *testing.T, s *suite) {
+	content := []byte("content of unknown size")
+	object := s.key("unknown-size.txt")
+	s.objects = append(s.objects, object)
+	reader := ioutil.NopCloser(bytes.NewReader(content))
+	if err := s.store.Put(object, reader, -1); err != nil {
+		t.Fatalf("Put(size -1): %v", err)
+	}
+	if got := s.get(t, object); !bytes.Equal(got, content) {
+		t.Errorf("Get = %q, want %q", got, content)
+	}
+}
+
+func testOverwrite(t *testing.T, s *suite) {
+	object := s.put(t, "overwrite.txt", []byte("old content"), map[string]string{"Content-Type": "text/plain"})
+	s.put(t, "overwrite.txt", []byte("new"), map[string]string{"Content-Type": "text/csv"})
+
+	if got := s.get(t, object); string(got) != "new" {
+		t.Errorf("Get: content = %q, want new", got)
+	}
+	info, err := s.store.GetInfo(object)
+	if err != nil {
+		t.Fatalf("GetInfo: %v", err)
+	}
+	if info.Size != 3 || info.ContentType != "text/csv" {
+		t.Errorf("GetInfo: size = %v, Content-Type = %q, want 3, text/csv", info.Size, info.ContentType)
+	}
+}
+
+func testConditionalRead(t *testing.T, s *suite) {
+	object := s.put(t, "conditional.txt", []byte("conditional"))
+	info, err := s.store.GetInfo(object)
+	if err != nil {
+		t.Fatalf("GetInfo: %v", err)
+	}
+	const otherETag = "0123456789abcdef0123456789abcdef"
+
+	reader, got, err := s.store.GetIf(object, CloudStore.Conditions{IfMatch: info.ETag, IfNoneMatch: otherETag})
+	if err != nil {
+		t.Fatalf("GetIf(If-Match: current ETag): %v", err)
+	}
+	content, err := ioutil.ReadAll(reader)
+	reader.Close()
+	if err != nil || string(content) != "conditional" || got.ETag != info.ETag {
+		t.Errorf("GetIf: content = %q, ETag = %q, err = %v, want conditional, %q", content, got.ETag, err, info.ETag)
+	}
+
+	for _, c := range []struct {
+		name string
+		cond CloudStore.Conditions
+		want error
+	}{
+		{"If-Match mismatch", CloudStore.Conditions{IfMatch: otherETag}, CloudStore.ErrPreconditionFailed},
+		{"If-None-Match current", CloudStore.Conditions{IfNoneMatch: info.ETag}, CloudStore.ErrNotModified},
+		{"If-None-Match *", CloudStore.Conditions{IfNoneMatch: "*"}, CloudStore.ErrNotModified},
+		{"If-Modified-Since future", CloudStore.Conditions{IfModifiedSince: info.ModTime.Add(time.Hour)}, CloudStore.ErrNotModified},
+		{"If-Unmodified-Since past", CloudStore.Conditions{IfUnmodifiedSince: info.ModTime.Add(-time.Hour)}, CloudStore.ErrPreconditionFailed},
+		{"If-Modified-Since past", CloudStore.Conditions{IfModifiedSince: info.ModTime.Add(-time.Hour)}, nil},
+	} {
+		if _, err := s.store.GetInfoIf(object, c.cond); !errors.Is(err, c.want) {
+			t.Errorf("GetInfoIf(%s): err = %v, want %v", c.name, err, c.want)
+		}
+		reader, _, err := s.store.GetIf(object, c.cond)
+		if err == nil {
+			reader.Close()
+		}
+		if !errors.Is(err, c.want) {
+			t.Errorf("GetIf(%s): err = %v, want %v", c.name, err, c.want)
+		}
+	}
+
+	if _, err := s.store.GetInfoIf(s.key("missing.txt"), CloudStore.Conditions{IfMatch: info.ETag}); !errors.Is(err, CloudStore.ErrNotExist) {
+		t.Errorf("GetInfoIf(missing): err = %v, want ErrNotExist", err)
+	}
+}
+
+func testConditionalPut(t *testing.T, s *suite) {
+	object := s.key("create-only.txt")
+	s.objects = append(s.objects, object)
+	put := func(content string, header map[string]string) error {
+		return s.store.Put(object, strings.NewReader(content), int64(len(content)), header)
+	}
+
+	if err := put("v1", map[string]string{CloudStore.HeaderIfMatch: "0123456789abcdef0123456789abcdef"}); !errors.Is(err, CloudStore.ErrPreconditionFailed) {
+		t.Errorf("Put(If-Match, missing): err = %v, want ErrPreconditionFailed", err)
+	}
+	if err := put("v1", map[string]string{CloudStore.HeaderIfNoneMatch: "*"}); err != nil {
+		t.Fatalf("Put(If-None-Match: *, missing): %v", err)
+	}
+	err := put("v2", map[string]string{CloudStore.HeaderIfNoneMatch: "*"})
+	if !errors.Is(err, CloudStore.ErrExist) || !errors.Is(err, CloudStore.ErrPreconditionFailed) {
+		t.Errorf("Put(If-None-Match: *, exists): err = %v, want ErrExist", err)
+	}
+	if got := s.get(t, object); string(got) != "v1" {
+		t.Errorf("Get after create-only: content = %q, want v1", got)
+	}
+
+	info, err := s.store.GetInfo(object)
+	if err != nil {
+		t.Fatalf("GetInfo: %v", err)
+	}
+	if err := put("v2", map[string]string{CloudStore.HeaderIfMatch: "0123456789abcdef0123456789abcdef"}); !errors.Is(err, CloudStore.ErrPreconditionFailed) {
+		t.Errorf("Put(If-Match mismatch): err = %v, want ErrPreconditionFailed", err)
+	}
+	if err := put("v2", map[string]string{CloudStore.HeaderIfMatch: info.ETag}); err != nil {
+		t.Errorf("Put(If-Match: current ETag): %v", err)
+	}
+	if got := s.get(t, object); string(got) != "v2" {
+		t.Errorf("Get after If-Match: content = %q, want v2", got)
+	}
+}
+
+func testHeaderRoundTrip(t *testing.T, s *suite) {
+	contentType := "text/html; charset=utf-8"
+	object := s.put(t, "page.html", []byte("<h1>hello</h1>"), map[string]string{"Content-Type": contentType})
+
+	info, err := s.store.GetInfo(object)
+	if err != nil {
+		t.Fatalf("GetInfo: %v", err)
+	}
+	if got := header(info, "Content-Type"); !strings.EqualFold(strings.Replace(got, " ", "", -1), strings.Replace(contentType, " ", "", -1)) {
+		t.Errorf("GetInfo: Content-Type = %q, want %q", got, contentType)
+	}
+	if info.ContentType != header(info, "Content-Type") {
+		t.Errorf("GetInfo: ContentType = %q, header %q", info.ContentType, header(info, "Content-Type"))
+	}
+}
+
+func testGzipContentEncoding(t *testing.T, s *suite) {
+	content := []byte(strings.Repeat("<svg></svg>", 100))
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write(content)
+	w.Close()
+
+	object := s.put(t, "image.svg", buf.Bytes(), map[string]string{"Content-Encoding": "gzip"}, map[string]string{"Content-Type": "image/svg+xml"})
+	info, err := s.store.GetInfo(object)
+	if err != nil {
+		t.Fatalf("GetInfo: %v", err)
+	}
+	if got := header(info, "Content-Encoding"); got != "gzip" {
+		t.Errorf("GetInfo: Content-Encoding = %q, want gzip", got)
+	}
+	if info.ContentEncoding != "gzip" {
+		t.Errorf("GetInfo: ContentEncoding = %q, want gzip", info.ContentEncoding)
+	}
+	if got := header(info, "Content-Type"); got != "image/svg+xml" {
+		t.Errorf("GetInfo: Content-Type = %q, want image/svg+xml", got)
+	}
+
+	// 保存的是压缩后的内容，部分 SDK 下载时会自动解压
+	got := s.get(t, object)
+	if r, err := gzip.NewReader(bytes.NewReader(got)); err == nil {
+		got, _ = ioutil.ReadAll(r)
+	}
+	if !bytes.Equal(got, content) {
+		t.Errorf("Get: content mismatch, got %v bytes", len(got))
+	}
+}
+
+func testStandardHeaders(t *testing.T, s *suite) {
+	want := map[string]string{
+		"Cache-Control":       "max-age=3600",
+		"Content-Disposition": `attachment; filename="report.txt"`,
+	}
+	object := s.put(t, "report.txt", []byte("report"), map[string]string{"content-type": "text/plain"}, want)
+	info, err := s.store.GetInfo(object)
+	if err != nil {
+		t.Fatalf("GetInfo: %v", err)
+	}
+	for k, v := range want {
+		if got := header(info, k); got != v {
+			t.Errorf("GetInfo: %v = %q, want %q", k, got, v)
+		}
+	}
+}
+
+// 上传时可以使用 X-Meta- 或者各云存储自己的前缀，返回的自定义元数据统一使用 X-Meta- 前缀
+func testMetadata(t *testing.T, s *suite) {
+	object := s.put(t, "meta.txt", []byte("metadata"),
+		CloudStore.Metadata{"author": "TruthHun"}.Header(),
+		map[string]string{"x-meta-book-id": "42"})
+	info, err := s.store.GetInfo(object)
+	if err != nil {
+		t.Fatalf("GetInfo: %v", err)
+	}
+	if got := info.Header["X-Meta-Author"]; got != "TruthHun" {
+		t.Errorf("GetInfo: X-Meta-Author = %q, want TruthHun, header %v", got, info.Header)
+	}
+	meta := CloudStore.MetadataFromHeader(info.Header)
+	if meta["author"] != "TruthHun" || meta["book-id"] != "42" || len(meta) != 2 {
+		t.Errorf("MetadataFromHeader = %v", meta)
+	}
+	if !reflect.DeepEqual(info.Metadata, meta) {
+		t.Errorf("GetInfo: Metadata = %v, want %v", info.Metadata, meta)
+	}
+}
+
+func testStorageClass(t *testing.T, s *suite) {
+	object := s.put(t, "ia.txt", []byte("infrequent access"), map[string]string{CloudStore.HeaderStorageClass: CloudStore.StorageIA})
+	info, err := s.store.GetInfo(object)
+	if err != nil {
+		t.Fatalf("GetInfo: %v", err)
+	}
+	if got := info.Header[CloudStore.HeaderStorageClass]; got != CloudStore.StorageIA {
+		t.Errorf("GetInfo: %v = %q, want %q", CloudStore.HeaderStorageClass, got, CloudStore.StorageIA)
+	}
+	if info.StorageClass != CloudStore.StorageIA {
+		t.Errorf("GetInfo: StorageClass = %q, want %q", info.StorageClass, CloudStore.StorageIA)
+	}
+
+	// 列表接口返回存储类型时应该与 GetInfo 一致
+	files, err := s.store.Lists(object)
+	if err != nil {
+		t.Fatalf("Lists: %v", err)
+	}
+	for _, file := range files {
+		if file.Name == object && file.StorageClass != "" && file.StorageClass != info.StorageClass {
+			t.Errorf("Lists: StorageClass = %q, want %q", file.StorageClass, info.StorageClass)
+		}
+	}
+}
+
+func names(files []CloudStore.File) []string {
+	var names []string
+	for _, file := range files {
+		name := file.Name
+		if file.IsDir {
+			name += "/"
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func testListPrefix(t *testing.T, s *suite) {
+	for _, name := range []string{"list/a.txt", "list/b.txt", "list/sub/c.txt", "listing.txt", "other.txt"} {
+		s.put(t, name, []byte(name))
+	}
+
+	files, err := s.store.Lists(s.key("list/"))
+	if err != nil {
+		t.Fatalf("Lists: %v", err)
+	}
+	want := []string{s.key("list/a.txt"), s.key("list/b.txt"), s.key("list/sub/c.txt")}
+	if got := names(files); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Lists(list/) = %v, want %v", got, want)
+	}
+	for _, file := range files {
+		if file.Size != int64(len(strings.TrimPrefix(file.Name, s.prefix))) {
+			t.Errorf("Lists: %v size = %v", file.Name, file.Size)
+		}
+		// 列表接口返回 ETag 时应该与 GetInfo 一致
+		if file.ETag == "" {
+			continue
+		}
+		if info, err := s.store.GetInfo(file.Name); err != nil {
+			t.Errorf("GetInfo(%v): %v", file.Name, err)
+		} else if !strings.EqualFold(info.ETag, file.ETag) {
+			t.Errorf("Lists: %v ETag = %q, GetInfo ETag = %q", file.Name, file.ETag, info.ETag)
+		}
+	}
+
+	// prefix 不是目录时，按字符串前缀匹配
+	files, err = s.store.Lists(s.key("list"))
+	if err != nil {
+		t.Fatalf("Lists: %v", err)
+	}
+	if len(files) != 4 {
+		t.Errorf("Lists(list) = %v, want 4 files", names(files))
+	}
+
+	// 分页
+	var all []CloudStore.File
+	marker := ""
+	for i := 0; ; i++ {
+		var page []CloudStore.File
+		page, marker, err = s.store.ListPage(s.prefix, marker, 2)
+		if err != nil {
+			t.Fatalf("ListPage: %v", err)
+		}
+		if len(page) > 2 {
+			t.Fatalf("ListPage: got %v files, want at most 2", len(page))
+		}
+		all = append(all, page...)
+		if marker == "" || i > 10 {
+			break
+		}
+	}
+	if len(all) != 5 {
+		t.Errorf("ListPage = %v, want 5 files", names(all))
+	}
+
+	// Iterator 按页读取全部文件
+	all = nil
+	it := CloudStore.NewIterator(context.Background(), s.store, s.prefix, 2)
+	for it.Next() {
+		all = append(all, it.File())
+	}
+	if err = it.Err(); err != nil {
+		t.Fatalf("Iterator: %v", err)
+	}
+	if len(all) != 5 {
+		t.Errorf("Iterator = %v, want 5 files", names(all))
+	}
+}
+
+func testListDir(t *testing.T, s *suite) {
+	for _, name := range []string{"dir/a.txt", "dir/b.txt", "dir/sub1/c.txt", "dir/sub2/d.txt", "dir/sub2/e/f.txt"} {
+		s.put(t, name, []byte(name))
+	}
+
+	var all []CloudStore.File
+	marker := ""
+	for i := 0; ; i++ {
+		page, next, err := s.store.ListDir(s.key("dir"), marker, 2)
+		if err != nil {
+			t.Fatalf("ListDir: %v", err)
+		}
+		all = append(all, page...)
+		if marker = next; marker == "" || i > 10 {
+			break
+		}
+	}
+	want := []string{s.key("dir/a.txt"), s.key("dir/b.txt"), s.key("dir/sub1/"), s.key("dir/sub2/")}
+	if got := names(all); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("ListDir = %v, want %v", got, want)
+	}
+}
+
+func testCopyMove(t *testing.T, s *suite) {
+	content := []byte("copy me")
+	src := s.put(t, "src.txt", content, map[string]string{"Content-Type": "text/plain"})
+	dst, moved := s.key("copy/dst.txt"), s.key("copy/moved.txt")
+	s.objects = append(s.objects, dst, moved)
+
+	if err := s.store.Copy(src, dst); err != nil {
+		t.Fatalf("Copy: %v", err)
+	}
+	if got := s.get(t, dst); !bytes.Equal(got, content) {
+		t.Errorf("Copy: content = %q, want %q", got, content)
+	}
+	if info, err := s.store.GetInfo(dst); err != nil || !strings.HasPrefix(header(info, "Content-Type"), "text/plain") {
+		t.Errorf("Copy: Content-Type = %q, %v", header(info, "Content-Type"), err)
+	}
+	if err := s.store.IsExist(src); err != nil {
+		t.Errorf("Copy: source removed: %v", err)
+	}
+
+	if err := s.store.Move(src, moved); err != nil {
+		t.Fatalf("Move: %v", err)
+	}
+	if got := s.get(t, moved); !bytes.Equal(got, content) {
+		t.Errorf("Move: content = %q, want %q", got, content)
+	}
+	if err := s.store.IsExist(src); !errors.Is(err, CloudStore.ErrNotExist) {
+		t.Errorf("Move: source still exists: %v", err)
+	}
+
+	if err := s.store.Copy(s.key("missing.txt"), dst); !errors.Is(err, CloudStore.ErrNotExist) {
+		t.Errorf("Copy missing file: got %v, want ErrNotExist", err)
+	}
+}
+
+func testDelete(t *testing.T, s *suite) {
+	a := s.put(t, "delete/a.txt", []byte("a"))
+	b := s.put(t, "delete/b.txt", []byte("b"))
+	if err := s.store.Delete(a, b, s.key("delete/missing.txt")); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	for _, object := range []string{a, b} {
+		if err := s.store.IsExist(object); !errors.Is(err, CloudStore.ErrNotExist) {
+			t.Errorf("IsExist(%q) after Delete: %v", object, err)
+		}
+	}
+	if err := s.store.Delete(); err != nil {
+		t.Errorf("Delete(): %v", err)
+	}
+}
+
+func httpGet(t *testing.T, link string) (status int, body []byte) {
+	t.Helper()
+	resp, err := http.Get(link)
+	if err != nil {
+		t.Fatalf("GET %v: %v", link, err)
+	}
+	defer resp.Body.Close()
+	body, _ = ioutil.ReadAll(resp.Body)
+	return resp.StatusCode, body
+}
+
+func testSignURL(t *testing.T, s *suite) {
+	content := []byte("signed content")
+	object := s.put(t, "signed.txt", content)
+
+	link, err := s.store.GetSignURL(object, 600)
+	if err != nil {
+		t.Fatalf("GetSignURL: %v", err)
+	}
+	status, body := httpGet(t, link)
+	if status != http.StatusOK || !bytes.Equal(body, content) {
+		t.Errorf("GET %v: status %v, body %q", link, status, body)
+	}
+}
+
+// 使用 GetSignUploadURL 返回的信息直接上传文件，上传时设置的 header 需要保存
+func testSignUpload(t *testing.T, s *suite) {
+	content := []byte("uploaded with a signed url")
+	object := s.key("signed-upload.txt")
+	s.objects = append(s.objects, object)
+	upload, err := s.store.GetSignUploadURL(object, 600, map[string]string{"Content-Type": "text/plain"})
+	if err != nil {
+		t.Fatalf("GetSignUploadURL: %v", err)
+	}
+	if status, body := httpUpload(t, upload, content); status != http.StatusOK && status != http.StatusNoContent {
+		t.Fatalf("%v %v: status %v, body %q", upload.Method, upload.URL, status, body)
+	}
+	if got := s.get(t, object); !bytes.Equal(got, content) {
+		t.Errorf("Get = %q, want %q", got, content)
+	}
+	if info, err := s.store.GetInfo(object); err != nil || !strings.HasPrefix(header(info, "Content-Type"), "text/plain") {
+		t.Errorf("GetInfo: Content-Type = %q, %v", header(info, "Content-Type"), err)
+	}
+}
+
+// Form 不为空时使用表单上传，Content-Type 设置在 file 字段上
+func httpUpload(t *testing.T, upload CloudStore.SignedUpload, content []byte) (status int, body []byte) {
+	t.Helper()
+	var req *http.Request
+	if upload.Form == nil {
+		req, _ = http.NewRequest(upload.Method, upload.URL, bytes.NewReader(content))
+		for k, v := range upload.Header {
+			req.Header.Set(k, v)
+		}
+	} else {
+		var buf bytes.Buffer
+		w := multipart.NewWriter(&buf)
+		for k, v := range upload.Form {
+			w.WriteField(k, v)
+		}
+		h := make(textproto.MIMEHeader)
+		h.Set("Content-Disposition", `form-data; name="file"; filename="file"`)
+		h.Set("Content-Type", "text/plain")
+		part, _ := w.CreatePart(h)
+		part.Write(content)
+		w.Close()
+		req, _ = http.NewRequest(upload.Method, upload.URL, &buf)
+		req.Header.Set("Content-Type", w.FormDataContentType())
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%v %v: %v", upload.Method, upload.URL, err)
+	}
+	defer resp.Body.Close()
+	body, _ = ioutil.ReadAll(resp.Body)
+	return resp.StatusCode, body
+}
+
+func testSignURLExpiry(t *testing.T, s *suite) {
+	object := s.put(t, "expired.txt", []byte("expired"))
+
+	link, err := s.store.GetSignURL(object, 1)
+	if err != nil {
+		t.Fatalf("GetSignURL: %v", err)
+	}
+	time.Sleep(2100 * time.Millisecond)
+	if status, _ := httpGet(t, link); status < 400 || status >= 500 {
+		t.Errorf("GET expired %v: status %v, want 4xx", link, status)
+	}
+}
+
+// ctx 已经被取消时返回 context.Canceled，不上传文件，也不创建下载的文件
+func testCanceled(t *testing.T, s *suite) {
+	store, ok := s.store.(CloudStore.CloudStoreContext)
+	if !ok {
+		t.Skip("store does not implement CloudStoreContext")
+	}
+	object := s.put(t, "canceled.txt", []byte("canceled"))
+	created := s.key("canceled-put.txt")
+	s.objects = append(s.objects, created)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := store.PutContext(ctx, created, strings.NewReader("new"), 3); !errors.Is(err, context.Canceled) {
+		t.Errorf("PutContext: err = %v, want context.Canceled", err)
+	}
+	if err := s.store.IsExist(created); !errors.Is(err, CloudStore.ErrNotExist) {
+		t.Errorf("IsExist after canceled PutContext: %v", err)
+	}
+	if reader, _, err := store.GetContext(ctx, object); !errors.Is(err, context.Canceled) {
+		if err == nil {
+			reader.Close()
+		}
+		t.Errorf("GetContext: err = %v, want context.Canceled", err)
+	}
+	if _, err := store.GetInfoContext(ctx, object); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetInfoContext: err = %v, want context.Canceled", err)
+	}
+	savePath := filepath.Join(t.TempDir(), "canceled.txt")
+	if err := store.DownloadContext(ctx, object, savePath); !errors.Is(err, context.Canceled) {
+		t.Errorf("DownloadContext: err = %v, want context.Canceled", err)
+	}
+	if _, err := os.Stat(savePath); !os.IsNotExist(err) {
+		t.Errorf("DownloadContext created %v: %v", savePath, err)
+	}
+	if err := store.DeleteContext(ctx, object); !errors.Is(err, context.Canceled) {
+		t.Errorf("DeleteContext: err = %v, want context.Canceled", err)
+	}
+	if err := s.store.IsExist(object); err != nil {
+		t.Errorf("IsExist after canceled DeleteContext: %v", err)
+	}
+}
+
+// 签名在本地计算，ctx 已经被取消时也应当返回错误，与其他操作一致
+func testSignCanceled(t *testing.T, s *suite) {
+	store, ok := s.store.(CloudStore.CloudStoreContext)
+	if !ok {
+		t.Skip("store does not implement CloudStoreContext")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	object := s.key("canceled.txt")
+	if _, err := store.GetSignURLContext(ctx, object, 60); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetSignURLContext: err = %v, want context.Canceled", err)
+	}
+	if _, err := store.GetSignUploadURLContext(ctx, object, 60); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetSignUploadURLContext: err = %v, want context.Canceled", err)
+	}
+}
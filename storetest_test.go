@@ -0,0 +1,498 @@
+package CloudStore_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/TruthHun/CloudStore"
+	"github.com/TruthHun/CloudStore/internal/fakes"
+	"github.com/TruthHun/CloudStore/storetest"
+	"github.com/qiniu/api.v7/v7/client"
+	"github.com/qiniu/api.v7/v7/storage"
+)
+
+func TestMemoryConformance(t *testing.T) {
+	t.Parallel()
+	storetest.Run(t, func(t *testing.T) CloudStore.CloudStore {
+		m := CloudStore.NewMemory("", "secret")
+		srv := httptest.NewServer(m)
+		t.Cleanup(srv.Close)
+		m.Domain = srv.URL
+		return m
+	})
+}
+
+// 签名链接由第一个副本生成，因此第一个副本需要提供下载服务
+func TestMirrorConformance(t *testing.T) {
+	t.Parallel()
+	storetest.Run(t, func(t *testing.T) CloudStore.CloudStore {
+		m := CloudStore.NewMemory("", "secret")
+		srv := httptest.NewServer(m)
+		t.Cleanup(srv.Close)
+		m.Domain = srv.URL
+		return CloudStore.NewMirror(0, m, CloudStore.NewMemory("", "secret"))
+	})
+}
+
+func TestLocalConformance(t *testing.T) {
+	t.Parallel()
+	storetest.Run(t, func(t *testing.T) CloudStore.CloudStore {
+		l, err := CloudStore.NewLocal(t.TempDir(), "", "secret")
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv := httptest.NewServer(l)
+		t.Cleanup(srv.Close)
+		l.Domain = srv.URL
+		return l
+	})
+}
+
+// 文件内容重命名失败时不能留下新的 header 和临时文件
+func TestLocalPutRenameFailure(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	l, err := CloudStore.NewLocal(dir, "", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a.txt 为非空的目录，重命名到 a.txt 会失败
+	if err = os.MkdirAll(filepath.Join(dir, "a.txt", "b"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err = l.Put("a.txt", strings.NewReader("new"), 3, map[string]string{"Content-Type": "text/csv"}); err == nil {
+		t.Fatal("Put over a directory: err = nil")
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if e.Name() != "a.txt" {
+			t.Errorf("unexpected file %v", e.Name())
+		}
+	}
+}
+
+// 并发的条件上传中只有一个能成功创建文件，所有上传读取完内容之后才继续，使它们同时检查条件
+func TestLocalConcurrentCreateOnly(t *testing.T) {
+	t.Parallel()
+	l, err := CloudStore.NewLocal(t.TempDir(), "", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const n = 20
+	var (
+		wg      sync.WaitGroup
+		read    sync.WaitGroup
+		created int32
+	)
+	read.Add(n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			content := strconv.Itoa(i)
+			reader := &barrierReader{Reader: strings.NewReader(content), barrier: &read}
+			err := l.Put("a.txt", reader, -1, map[string]string{"If-None-Match": "*"})
+			switch {
+			case err == nil:
+				atomic.AddInt32(&created, 1)
+			case !errors.Is(err, CloudStore.ErrExist):
+				t.Errorf("Put(If-None-Match: *): %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if created != 1 {
+		t.Errorf("%d puts created a.txt, want 1", created)
+	}
+}
+
+// barrierReader 读取到 EOF 时等待其他 reader 也读取完
+type barrierReader struct {
+	io.Reader
+	barrier *sync.WaitGroup
+	done    bool
+}
+
+func (r *barrierReader) Read(p []byte) (n int, err error) {
+	n, err = r.Reader.Read(p)
+	if err == io.EOF && !r.done {
+		r.done = true
+		r.barrier.Done()
+		r.barrier.Wait()
+	}
+	return
+}
+
+// 移动失败时不能修改 dst 的 header，src 的 header 也要保留
+func TestLocalMoveRenameFailure(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	l, err := CloudStore.NewLocal(dir, "", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = l.Put("a.txt", strings.NewReader("a"), 1, map[string]string{"Content-Type": "text/csv"}); err != nil {
+		t.Fatal(err)
+	}
+	// b.txt 为非空的目录，重命名到 b.txt 会失败
+	if err = os.MkdirAll(filepath.Join(dir, "b.txt", "c"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err = l.Move("a.txt", "b.txt"); err == nil {
+		t.Fatal("Move over a directory: err = nil")
+	}
+	if _, err = os.Stat(filepath.Join(dir, "b.txt.cloudstore-meta")); !os.IsNotExist(err) {
+		t.Errorf("dst header written before rename: %v", err)
+	}
+	info, err := l.GetInfo("a.txt")
+	if err != nil || info.Header["Content-Type"] != "text/csv" {
+		t.Errorf("GetInfo(a.txt) = %v, %v, want Content-Type text/csv", info.Header, err)
+	}
+}
+
+func TestOSSConformance(t *testing.T) {
+	t.Parallel()
+	storetest.Run(t, func(t *testing.T) CloudStore.CloudStore {
+		srv := fakes.NewOSS("bucket")
+		t.Cleanup(srv.Close)
+		o, err := CloudStore.NewOSS("ak", "sk", srv.URL, "bucket", srv.URL+"/bucket")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return o
+	})
+}
+
+func TestCOSConformance(t *testing.T) {
+	t.Parallel()
+	storetest.Run(t, func(t *testing.T) CloudStore.CloudStore {
+		srv := fakes.NewCOS()
+		t.Cleanup(srv.Close)
+		c, err := CloudStore.NewCOS("ak", "sk", "bucket", "1250000000", "ap-guangzhou", srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Client.BaseURL.BucketURL, _ = url.Parse(srv.URL)
+		return c
+	})
+}
+
+// 使用分隔符时 COS 可能不返回 NextMarker，本页只有目录时以最后一个目录作为下一页的 marker
+func TestCOSListDirWithoutNextMarker(t *testing.T) {
+	t.Parallel()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml")
+		w.Write([]byte(`<ListBucketResult><Name>bucket</Name><Prefix>docs/</Prefix><MaxKeys>2</MaxKeys>` +
+			`<Delimiter>/</Delimiter><IsTruncated>true</IsTruncated>` +
+			`<CommonPrefixes><Prefix>docs/a/</Prefix></CommonPrefixes>` +
+			`<CommonPrefixes><Prefix>docs/b/</Prefix></CommonPrefixes></ListBucketResult>`))
+	}))
+	t.Cleanup(srv.Close)
+	c, err := CloudStore.NewCOS("ak", "sk", "bucket", "1250000000", "ap-guangzhou", srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Client.BaseURL.BucketURL, _ = url.Parse(srv.URL)
+	files, next, err := c.ListDir("docs/", "", 2)
+	if err != nil {
+		t.Fatalf("ListDir: %v", err)
+	}
+	if len(files) != 2 || next != "docs/b/" {
+		t.Errorf("ListDir = %v, next %q, want 2 dirs, next docs/b/", files, next)
+	}
+}
+
+// 逐个删除时保留每个文件的错误类型
+func TestCOSDeleteError(t *testing.T) {
+	t.Parallel()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ok.txt" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`<Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`))
+	}))
+	t.Cleanup(srv.Close)
+	c, err := CloudStore.NewCOS("ak", "sk", "bucket", "1250000000", "ap-guangzhou", srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Client.BaseURL.BucketURL, _ = url.Parse(srv.URL)
+	err = c.Delete("a.txt", "ok.txt", "b.txt")
+	var de *CloudStore.DeleteError
+	if !errors.As(err, &de) || len(de.Errors) != 2 {
+		t.Fatalf("Delete: err = %v, want DeleteError with 2 errors", err)
+	}
+	if !errors.Is(err, CloudStore.ErrPermission) {
+		t.Errorf("Delete: err = %v, want ErrPermission", err)
+	}
+	var se *CloudStore.StoreError
+	if !errors.As(err, &se) || se.Key != "a.txt" || se.Code != "AccessDenied" {
+		t.Errorf("Delete: StoreError = %+v, want a.txt AccessDenied", se)
+	}
+}
+
+func TestOBSConformance(t *testing.T) {
+	t.Parallel()
+	storetest.Run(t, func(t *testing.T) CloudStore.CloudStore {
+		srv := fakes.NewOBS("bucket")
+		t.Cleanup(srv.Close)
+		o, err := CloudStore.NewOBS("ak", "sk", "bucket", srv.URL, srv.URL+"/bucket")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return o
+	})
+}
+
+// 批量删除时 OBS 在响应内容中返回各个文件的错误，不存在的文件不算失败
+func TestOBSDeleteError(t *testing.T) {
+	t.Parallel()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml")
+		w.Write([]byte(`<DeleteResult><Deleted><Key>ok.txt</Key></Deleted>` +
+			`<Error><Key>a.txt</Key><Code>AccessDenied</Code><Message>Access Denied</Message></Error>` +
+			`<Error><Key>missing.txt</Key><Code>NoSuchKey</Code><Message>Not Found</Message></Error></DeleteResult>`))
+	}))
+	t.Cleanup(srv.Close)
+	o, err := CloudStore.NewOBS("ak", "sk", "bucket", srv.URL, srv.URL+"/bucket")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = o.Delete("a.txt", "ok.txt", "missing.txt")
+	var de *CloudStore.DeleteError
+	if !errors.As(err, &de) || len(de.Errors) != 1 {
+		t.Fatalf("Delete: err = %v, want DeleteError with 1 error", err)
+	}
+	var se *CloudStore.StoreError
+	if !errors.Is(err, CloudStore.ErrPermission) || !errors.As(err, &se) || se.Key != "a.txt" {
+		t.Errorf("Delete: err = %v, want ErrPermission for a.txt", err)
+	}
+}
+
+// 大小未知时 minio-go 使用分片上传，fake 不支持分片上传
+func TestMinIOConformance(t *testing.T) {
+	t.Parallel()
+	storetest.Run(t, func(t *testing.T) CloudStore.CloudStore {
+		srv := fakes.NewMinIO("bucket")
+		t.Cleanup(srv.Close)
+		m, err := CloudStore.NewMinIO("ak", "sk", "bucket", srv.Listener.Addr().String(), srv.URL+"/bucket")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}, "PutUnknownSize")
+}
+
+func TestBOSConformance(t *testing.T) {
+	t.Parallel()
+	storetest.Run(t, func(t *testing.T) CloudStore.CloudStore {
+		srv := fakes.NewBOS("bucket")
+		t.Cleanup(srv.Close)
+		b, err := CloudStore.NewBOS("ak", "sk", "bucket", srv.URL, srv.URL+"/bucket")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	})
+}
+
+// 大小未知的 reader 需要完整上传，Content-MD5 由 fake 校验
+func TestBOSPutUnknownSize(t *testing.T) {
+	t.Parallel()
+	srv := fakes.NewBOS("bucket")
+	t.Cleanup(srv.Close)
+	b, err := CloudStore.NewBOS("ak", "sk", "bucket", srv.URL, srv.URL+"/bucket")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fp, err := ioutil.TempFile(t.TempDir(), "bos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fp.Close()
+	fp.WriteString("skip:file content")
+	fp.Seek(5, io.SeekStart)
+
+	tests := []struct {
+		object string
+		reader io.Reader
+		size   int64
+		want   string
+	}{
+		{"reader.txt", io.MultiReader(strings.NewReader("reader "), strings.NewReader("content")), -1, "reader content"},
+		{"limited.txt", io.MultiReader(strings.NewReader("limited content")), 7, "limited"},
+		{"file.txt", fp, -1, "file content"},
+	}
+	for _, tt := range tests {
+		if err = b.Put(tt.object, tt.reader, tt.size); err != nil {
+			t.Errorf("Put(%q): %v", tt.object, err)
+			continue
+		}
+		reader, _, err := b.Get(tt.object)
+		if err != nil {
+			t.Errorf("Get(%q): %v", tt.object, err)
+			continue
+		}
+		data, _ := ioutil.ReadAll(reader)
+		reader.Close()
+		if string(data) != tt.want {
+			t.Errorf("Get(%q) = %q, want %q", tt.object, data, tt.want)
+		}
+	}
+	if err = b.Put("short.txt", io.MultiReader(strings.NewReader("short")), 10); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Put(short reader): err = %v, want io.ErrUnexpectedEOF", err)
+	}
+}
+
+// 批量删除的错误不能被忽略，也不能修改调用方传入的 objects
+func TestBOSDeleteError(t *testing.T) {
+	t.Parallel()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"code":"AccessDenied","message":"AccessDenied","requestId":"1"}`))
+	}))
+	t.Cleanup(srv.Close)
+	b, err := CloudStore.NewBOS("ak", "sk", "bucket", srv.URL, srv.URL+"/bucket")
+	if err != nil {
+		t.Fatal(err)
+	}
+	objects := []string{"/a.txt", "./b.txt"}
+	if err = b.Delete(objects...); !errors.Is(err, CloudStore.ErrPermission) {
+		t.Errorf("Delete: err = %v, want ErrPermission", err)
+	}
+	if objects[0] != "/a.txt" || objects[1] != "./b.txt" {
+		t.Errorf("Delete modified objects: %q", objects)
+	}
+}
+
+// 七牛上传时只能设置 MimeType、存储类型和自定义元数据，不支持 Content-Encoding、Cache-Control 等 header；
+// 测试替换了全局的 client.DefaultClient，因此不能并行运行。上传域名只能通过 fakes.Transport 访问，
+// 表单上传由 TestQiniuSignUpload 测试
+func TestQiniuConformance(t *testing.T) {
+	storage.SetRegionCachePath(filepath.Join(t.TempDir(), "query.cache.json"))
+	defaultClient := client.DefaultClient
+	t.Cleanup(func() { client.DefaultClient = defaultClient })
+	storetest.Run(t, func(t *testing.T) CloudStore.CloudStore {
+		return newFakeQiniu(t)
+	}, "GzipContentEncoding", "StandardHeaders", "SignUpload")
+}
+
+// 按 GetSignUploadURL 返回的表单上传，Content-Type 设置在 file 字段上
+func TestQiniuSignUpload(t *testing.T) {
+	storage.SetRegionCachePath(filepath.Join(t.TempDir(), "query.cache.json"))
+	defaultClient := client.DefaultClient
+	t.Cleanup(func() { client.DefaultClient = defaultClient })
+	q := newFakeQiniu(t)
+
+	upload, err := q.GetSignUploadURL("signed.txt", 600, map[string]string{"Content-Type": "text/plain"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for k, v := range upload.Form {
+		w.WriteField(k, v)
+	}
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", `form-data; name="file"; filename="signed.txt"`)
+	h.Set("Content-Type", "text/plain")
+	part, _ := w.CreatePart(h)
+	part.Write([]byte("signed upload"))
+	w.Close()
+	req, _ := http.NewRequest(upload.Method, upload.URL, &body)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	resp, err := client.DefaultClient.Client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("%v %v: status %v", upload.Method, upload.URL, resp.StatusCode)
+	}
+
+	// 凭证的 scope 限定为 signed.txt，不能用于上传其他文件
+	w = multipart.NewWriter(&body)
+	w.WriteField("key", "other.txt")
+	w.WriteField("token", upload.Form["token"])
+	part, _ = w.CreateFormFile("file", "other.txt")
+	part.Write([]byte("other"))
+	w.Close()
+	req, _ = http.NewRequest(upload.Method, upload.URL, &body)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	if resp, err = client.DefaultClient.Client.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("upload other.txt: status %v, want %v", resp.StatusCode, http.StatusUnauthorized)
+	}
+
+	info, err := q.GetInfo("signed.txt")
+	if err != nil || info.Size != int64(len("signed upload")) || !strings.HasPrefix(info.Header["Content-Type"], "text/plain") {
+		t.Errorf("GetInfo = %+v, %v", info, err)
+	}
+}
+
+func newFakeQiniu(t *testing.T) *CloudStore.QINIU {
+	srv := fakes.NewQiniu("bucket")
+	t.Cleanup(srv.Close)
+	client.DefaultClient = client.Client{Client: &http.Client{Transport: fakes.Transport(srv)}}
+	q, err := CloudStore.NewQINIU("ak", "sk", "bucket", srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return q
+}
+
+func TestQiniuInsertOnly(t *testing.T) {
+	storage.SetRegionCachePath(filepath.Join(t.TempDir(), "query.cache.json"))
+	defaultClient := client.DefaultClient
+	t.Cleanup(func() { client.DefaultClient = defaultClient })
+	q := newFakeQiniu(t)
+
+	insertOnly := map[string]string{CloudStore.HeaderIfNoneMatch: "*"}
+	if err := q.Put("a.txt", strings.NewReader("old"), 3, insertOnly); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	err := q.Put("a.txt", strings.NewReader("new"), 3, insertOnly)
+	if !errors.Is(err, CloudStore.ErrExist) {
+		t.Errorf("Put existing file: got %v, want ErrExist", err)
+	}
+	if info, err := q.GetInfo("a.txt"); err != nil || info.Size != 3 {
+		t.Errorf("GetInfo = %+v, %v", info, err)
+	}
+}
+
+// 又拍云不支持上传时设置 Content-Encoding、Cache-Control 等 header 以及存储类型，访问时由 CDN 自行压缩；
+// fake 没有实现 FORM API，不测试表单上传
+func TestUpYunConformance(t *testing.T) {
+	t.Parallel()
+	storetest.Run(t, func(t *testing.T) CloudStore.CloudStore {
+		srv := fakes.NewUpYun("bucket")
+		t.Cleanup(srv.Close)
+		u := CloudStore.NewUpYun("bucket", "operator", "password", srv.URL, "secret")
+		u.Client.Hosts = map[string]string{"v0.api.upyun.com": srv.Listener.Addr().String()}
+		return u
+	}, "GzipContentEncoding", "StandardHeaders", "StorageClass", "SignUpload")
+}
@@ -0,0 +1,221 @@
+package CloudStore
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 一次删除的文件数量，各云存储批量删除的上限为 1000
+const syncDeleteBatch = 1000
+
+// SyncOptions 同步的参数，零值表示使用默认值
+type SyncOptions struct {
+	Parallel int  // 同时复制的文件数，默认 4
+	DryRun   bool // 只生成报告，不复制和删除文件
+	Delete   bool // 删除 dst 中有而 src 中没有的文件
+
+	// OnFile 在每个文件处理完成后调用，action 为 copy、skip 或者 delete，可以用于显示进度
+	OnFile func(action, key string, err error)
+}
+
+// SyncReport 同步的结果，DryRun 时 Copied、Deleted 为将要复制和删除的文件
+type SyncReport struct {
+	Copied   []string         // 复制的文件
+	Skipped  int              // 没有变化而跳过的文件数
+	Deleted  []string         // 删除的文件
+	Failed   map[string]error // 复制或者删除失败的文件
+	Bytes    int64            // 复制的字节数
+	Duration time.Duration
+}
+
+func (r *SyncReport) String() string {
+	return fmt.Sprintf("copied %d (%d bytes), skipped %d, deleted %d, failed %d in %v",
+		len(r.Copied), r.Bytes, r.Skipped, len(r.Deleted), len(r.Failed), r.Duration.Round(time.Millisecond))
+}
+
+// Sync 把 src 中 prefix 下新增或者有变化的文件复制到 dst 的同名文件，见 SyncContext
+func Sync(src, dst CloudStore, prefix string, opts SyncOptions) (report *SyncReport, err error) {
+	return SyncContext(context.Background(), src, dst, prefix, opts)
+}
+
+// SyncContext 把 src 中 prefix 下新增或者有变化的文件复制到 dst 的同名文件，可以用于在不同的云存储之间迁移文件。
+// 文件大小不同、两边都有 MD5（ETag 或 Content-MD5）且不同、或者 src 的修改时间晚于 dst 时视为有变化；
+// 复制时保留 Content-Type、Content-Encoding 等标准 header 以及自定义元数据。
+// 列出文件失败时返回错误且不会删除文件；部分文件失败时返回的错误中包含失败的数量，详见 report.Failed
+func SyncContext(ctx context.Context, src, dst CloudStore, prefix string, opts SyncOptions) (report *SyncReport, err error) {
+	begin := time.Now()
+	report = &SyncReport{Failed: make(map[string]error)}
+	defer func() { report.Duration = time.Since(begin) }()
+	if opts.Parallel <= 0 {
+		opts.Parallel = 4
+	}
+
+	dstFiles := make(map[string]File)
+	it := NewIterator(ctx, dst, prefix, 0)
+	for it.Next() {
+		if f := it.File(); !f.IsDir {
+			dstFiles[f.Name] = f
+		}
+	}
+	if err = it.Err(); err != nil {
+		return report, fmt.Errorf("CloudStore: sync: list dst: %w", err)
+	}
+
+	var (
+		lock sync.Mutex
+		wg   sync.WaitGroup
+		jobs = make(chan File)
+	)
+	done := func(action, key string, n int64, e error) {
+		lock.Lock()
+		switch {
+		case e != nil:
+			report.Failed[key] = e
+		case action == "copy":
+			report.Copied = append(report.Copied, key)
+			report.Bytes += n
+		case action == "skip":
+			report.Skipped++
+		case action == "delete":
+			report.Deleted = append(report.Deleted, key)
+		}
+		lock.Unlock()
+		if opts.OnFile != nil {
+			opts.OnFile(action, key, e)
+		}
+	}
+	for i := 0; i < opts.Parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for f := range jobs {
+				if opts.DryRun {
+					done("copy", f.Name, f.Size, nil)
+					continue
+				}
+				n, e := CopyObjectContext(ctx, src, f.Name, dst, f.Name)
+				done("copy", f.Name, n, e)
+			}
+		}()
+	}
+
+	it = NewIterator(ctx, src, prefix, 0)
+	for it.Next() {
+		f := it.File()
+		if f.IsDir {
+			continue
+		}
+		d, ok := dstFiles[f.Name]
+		delete(dstFiles, f.Name)
+		if ok && !fileChanged(f, d) {
+			done("skip", f.Name, 0, nil)
+			continue
+		}
+		jobs <- f
+	}
+	close(jobs)
+	wg.Wait()
+	if err = it.Err(); err != nil {
+		return report, fmt.Errorf("CloudStore: sync: list src: %w", err)
+	}
+
+	if opts.Delete {
+		extraneous := make([]string, 0, len(dstFiles))
+		for name := range dstFiles {
+			extraneous = append(extraneous, name)
+		}
+		sort.Strings(extraneous)
+		for len(extraneous) > 0 && ctx.Err() == nil {
+			n := len(extraneous)
+			if n > syncDeleteBatch {
+				n = syncDeleteBatch
+			}
+			var e error
+			if !opts.DryRun {
+				e = dst.DeleteContext(ctx, extraneous[:n]...)
+			}
+			for _, name := range extraneous[:n] {
+				done("delete", name, 0, e)
+			}
+			extraneous = extraneous[n:]
+		}
+	}
+
+	sort.Strings(report.Copied)
+	if err = ctx.Err(); err == nil && len(report.Failed) > 0 {
+		err = fmt.Errorf("CloudStore: sync: %d files failed", len(report.Failed))
+	}
+	return
+}
+
+// 以数据流的方式复制一个文件，不经过本地临时文件
+// CopyObject 把 src 中的 srcKey 复制到 dst 的 dstKey，见 CopyObjectContext
+func CopyObject(src CloudStore, srcKey string, dst CloudStore, dstKey string, headers ...map[string]string) (n int64, err error) {
+	return CopyObjectContext(context.Background(), src, srcKey, dst, dstKey, headers...)
+}
+
+// CopyObjectContext 把 src 中的 srcKey 复制到 dst 的 dstKey，用于在不同的云存储之间复制文件，同一个云存储中使用 Copy。
+// 复制时保留 Content-Type、Content-Encoding 等标准 header 以及自定义元数据，headers 会覆盖其中同名的 header；
+// n 为复制的字节数
+func CopyObjectContext(ctx context.Context, src CloudStore, srcKey string, dst CloudStore, dstKey string, headers ...map[string]string) (n int64, err error) {
+	reader, info, err := src.GetContext(ctx, srcKey)
+	if err != nil {
+		return
+	}
+	defer reader.Close()
+
+	header := MetadataFromHeader(info.Header).Header()
+	h := http.Header{}
+	for k, v := range info.Header {
+		h.Set(k, v)
+	}
+	for _, k := range standardHeaders {
+		if v := h.Get(k); v != "" {
+			header[k] = v
+		}
+	}
+	for _, m := range headers {
+		for k, v := range m {
+			header[k] = v
+		}
+	}
+	if err = dst.PutContext(ctx, dstKey, reader, info.Size, header); err != nil {
+		return
+	}
+	return info.Size, nil
+}
+
+// src 与 dst 中的同名文件是否不同
+func fileChanged(src, dst File) bool {
+	if src.Size != dst.Size {
+		return true
+	}
+	if srcMD5, dstMD5 := fileMD5(src), fileMD5(dst); srcMD5 != "" && dstMD5 != "" {
+		return srcMD5 != dstMD5
+	}
+	return !src.ModTime.IsZero() && !dst.ModTime.IsZero() && src.ModTime.After(dst.ModTime)
+}
+
+// 文件内容的 MD5（十六进制），分片上传的 ETag、七牛的 hash 等不是 MD5，返回空字符串
+func fileMD5(f File) string {
+	h := http.Header{}
+	for k, v := range f.Header {
+		h.Set(k, v)
+	}
+	if etag := strings.ToLower(f.ETag); len(etag) == 32 {
+		if _, err := hex.DecodeString(etag); err == nil {
+			return etag
+		}
+	}
+	if b, err := base64.StdEncoding.DecodeString(h.Get("Content-MD5")); err == nil && len(b) == 16 {
+		return hex.EncodeToString(b)
+	}
+	return ""
+}
@@ -0,0 +1,128 @@
+package CloudStore
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSync(t *testing.T) {
+	src, dst := NewMemory("", ""), NewMemory("", "")
+	put := func(store *Memory, key, content string, headers ...map[string]string) {
+		if err := store.Put(key, strings.NewReader(content), int64(len(content)), headers...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	put(src, "docs/a.txt", "aaa", map[string]string{"Content-Type": "text/plain", "Content-Encoding": "gzip"}, Metadata{"author": "TruthHun"}.Header())
+	put(src, "docs/b.txt", "bbb")
+	put(src, "docs/c.txt", "ccc")
+	put(src, "other/d.txt", "ddd")
+	put(dst, "docs/b.txt", "bbb")     // 没有变化
+	put(dst, "docs/c.txt", "cc")      // 大小不同
+	put(dst, "docs/extra.txt", "eee") // dst 中多余的文件
+
+	// DryRun 不修改 dst
+	report, err := Sync(src, dst, "docs/", SyncOptions{DryRun: true, Delete: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(report.Copied) != "[docs/a.txt docs/c.txt]" || report.Skipped != 1 || fmt.Sprint(report.Deleted) != "[docs/extra.txt]" {
+		t.Errorf("dry run report = %+v", report)
+	}
+	if err = dst.IsExist("docs/a.txt"); !errors.Is(err, ErrNotExist) {
+		t.Errorf("dry run copied docs/a.txt: %v", err)
+	}
+
+	report, err = Sync(src, dst, "docs/", SyncOptions{Delete: true, Parallel: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(report.Copied) != "[docs/a.txt docs/c.txt]" || report.Bytes != 6 || fmt.Sprint(report.Deleted) != "[docs/extra.txt]" {
+		t.Errorf("report = %v", report)
+	}
+	reader, info, err := dst.Get("docs/a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ioutil.ReadAll(reader)
+	reader.Close()
+	if string(data) != "aaa" || info.Header["Content-Encoding"] != "gzip" || info.Header["Content-Type"] != "text/plain" || info.Header["X-Meta-Author"] != "TruthHun" {
+		t.Errorf("docs/a.txt = %q, %v", data, info.Header)
+	}
+	if err = dst.IsExist("docs/extra.txt"); !errors.Is(err, ErrNotExist) {
+		t.Errorf("docs/extra.txt is not deleted: %v", err)
+	}
+	if err = dst.IsExist("other/d.txt"); !errors.Is(err, ErrNotExist) {
+		t.Errorf("other/d.txt is copied: %v", err)
+	}
+
+	// 再次同步时没有需要复制的文件
+	report, err = Sync(src, dst, "docs/", SyncOptions{})
+	if err != nil || len(report.Copied) != 0 || report.Skipped != 3 {
+		t.Errorf("report = %v, %v", report, err)
+	}
+}
+
+func TestSyncFailed(t *testing.T) {
+	src, dst := NewMemory("", ""), NewMemory("", "")
+	src.Put("a.txt", strings.NewReader("aaa"), 3)
+	unavailable := newStoreError("memory", "Put", "a.txt", 503, "", "", errors.New("unavailable"))
+	report, err := Sync(src, &flakyStore{CloudStore: dst, failures: 1, err: unavailable}, "", SyncOptions{})
+	if err == nil || report.Failed["a.txt"] != unavailable || len(report.Copied) != 0 {
+		t.Errorf("report = %v, %v", report, err)
+	}
+}
+
+// 复制到其他名称时保留 header 和自定义元数据，headers 覆盖同名的 header
+func TestCopyObject(t *testing.T) {
+	src, dst := NewMemory("", ""), NewMemory("", "")
+	src.Put("a.txt", strings.NewReader("aaa"), 3, map[string]string{"Content-Type": "text/plain", "Cache-Control": "no-cache"}, Metadata{"author": "TruthHun"}.Header())
+	n, err := CopyObject(src, "a.txt", dst, "b.txt", map[string]string{"Cache-Control": "max-age=60"})
+	if err != nil || n != 3 {
+		t.Fatalf("CopyObject = %v, %v", n, err)
+	}
+	info, err := dst.GetInfo("b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Header["Content-Type"] != "text/plain" || info.Header["Cache-Control"] != "max-age=60" || info.Header["X-Meta-Author"] != "TruthHun" {
+		t.Errorf("b.txt header = %v", info.Header)
+	}
+}
+
+func TestFileChanged(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		src, dst File
+		want     bool
+	}{
+		{File{Size: 1}, File{Size: 2}, true},
+		{File{Size: 1, ModTime: now}, File{Size: 1, ModTime: now.Add(-time.Hour)}, true},
+		{File{Size: 1, ModTime: now.Add(-time.Hour)}, File{Size: 1, ModTime: now}, false},
+		// MD5 相同时不比较修改时间
+		{
+			File{Size: 1, ModTime: now, ETag: "0CC175B9C0F1B6A831C399E269772661"},
+			File{Size: 1, ModTime: now.Add(-time.Hour), Header: map[string]string{"Content-Md5": "DMF1ucDxtqgxw5niaXcmYQ=="}},
+			false,
+		},
+		{
+			File{Size: 1, ETag: "0cc175b9c0f1b6a831c399e269772661"},
+			File{Size: 1, ETag: "92eb5ffee6ae2fec3ad71c777531578f"},
+			true,
+		},
+		// 分片上传的 ETag 不是 MD5
+		{
+			File{Size: 1, ETag: "0cc175b9c0f1b6a831c399e269772661-2"},
+			File{Size: 1, ETag: "92eb5ffee6ae2fec3ad71c777531578f"},
+			false,
+		},
+	}
+	for i, tt := range tests {
+		if got := fileChanged(tt.src, tt.dst); got != tt.want {
+			t.Errorf("%d: fileChanged = %v, want %v", i, got, tt.want)
+		}
+	}
+}
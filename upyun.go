@@ -1,10 +1,23 @@
 package CloudStore
 
 import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
 	"os"
-	"path/filepath"
+	"path"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -41,47 +54,250 @@ func NewUpYun(bucket, operator, password, domain, secret string) *UpYun {
 }
 
 func (u *UpYun) IsExist(object string) (err error) {
-	_, err = u.Client.GetInfo(objectAbs(object))
-	return
+	return u.IsExistContext(context.Background(), object)
+}
+
+// 又拍云 SDK 不支持 context，以下 XXXContext 方法在 ctx 结束时提前返回
+func (u *UpYun) IsExistContext(ctx context.Context, object string) (err error) {
+	defer func() { err = upyunError("IsExist", object, err) }()
+	return doContext(ctx, func() (e error) {
+		_, e = u.Client.GetInfo(objectAbs(object))
+		return
+	})
 }
 
 func (u *UpYun) Upload(tmpFile, saveFile string, headers ...map[string]string) (err error) {
-	_, err = os.Stat(tmpFile)
-	h := make(map[string]string)
-	if err != nil {
+	return u.UploadContext(context.Background(), tmpFile, saveFile, headers...)
+}
+
+func (u *UpYun) UploadContext(ctx context.Context, tmpFile, saveFile string, headers ...map[string]string) (err error) {
+	defer func() { err = upyunError("Upload", saveFile, err) }()
+	if useMultipart(tmpFile) {
+		return u.UploadMultipartContext(ctx, tmpFile, saveFile, MultipartOptions{}, headers...)
+	}
+	if _, err = os.Stat(tmpFile); err != nil {
+		return
+	}
+	if err = checkUploadConditions(ctx, u.GetInfoContext, saveFile, parseHeaders(headers...)); err != nil {
+		return
+	}
+	h := upyunHeader(headers...)
+	return doContext(ctx, func() error {
+		return u.Client.Put(&upyun.PutObjectConfig{
+			Path:      objectAbs(saveFile),
+			LocalPath: tmpFile,
+			Headers:   h,
+		})
+	})
+}
+
+func (u *UpYun) Put(object string, reader io.Reader, size int64, headers ...map[string]string) (err error) {
+	return u.PutContext(context.Background(), object, reader, size, headers...)
+}
+
+func (u *UpYun) PutContext(ctx context.Context, object string, reader io.Reader, size int64, headers ...map[string]string) (err error) {
+	defer func() { err = upyunError("Put", object, err) }()
+	// 又拍云不支持条件上传，上传之前检查
+	if err = checkUploadConditions(ctx, u.GetInfoContext, object, parseHeaders(headers...)); err != nil {
+		return
+	}
+	h := upyunHeader(headers...)
+	if size < 0 {
+		size = readerSize(reader)
+	}
+	if size >= 0 {
+		h["Content-Length"] = strconv.FormatInt(size, 10)
+	}
+	return doContextReader(ctx, reader, func(reader io.Reader) error {
+		return u.Client.Put(&upyun.PutObjectConfig{
+			Path:    objectAbs(object),
+			Reader:  reader,
+			Headers: h,
+		})
+	})
+}
+
+// 又拍云上传时只能设置 Content-Type、Content-MD5（十六进制）以及 x-upyun-meta- 开头的自定义元数据，
+// 缓存等其他 header 需要在控制台中配置
+func upyunHeader(headers ...map[string]string) map[string]string {
+	h := parseHeaders(headers...)
+	header := make(map[string]string)
+	if h.ContentType != "" {
+		header[HeaderContentType] = h.ContentType
+	}
+	if b, err := base64.StdEncoding.DecodeString(h.ContentMD5); err == nil && len(b) == md5.Size {
+		header[HeaderContentMD5] = hex.EncodeToString(b)
+	}
+	for k, v := range h.Metadata {
+		header[upyunDialect.metaPrefix+k] = v
+	}
+	return header
+}
+
+func (u *UpYun) UploadMultipart(tmpFile, saveFile string, opts MultipartOptions, headers ...map[string]string) (err error) {
+	return u.UploadMultipartContext(context.Background(), tmpFile, saveFile, opts, headers...)
+}
+
+// 使用又拍云的并行式断点续传，SDK 中的断点续传只能串行上传，这里直接调用 REST API
+// http://docs.upyun.com/api/rest_api/#_3
+func (u *UpYun) UploadMultipartContext(ctx context.Context, tmpFile, saveFile string, opts MultipartOptions, headers ...map[string]string) (err error) {
+	defer func() { err = upyunError("UploadMultipart", saveFile, err) }()
+	return uploadMultipart(ctx, u, tmpFile, saveFile, opts, headers...)
+}
+
+// 又拍云不支持条件上传，开始上传以及完成上传之前各检查一次
+func (u *UpYun) initMultipart(ctx context.Context, object string, size, partSize int64, header map[string]string) (uploadID string, err error) {
+	if err = checkUploadConditions(ctx, u.GetInfoContext, object, parseHeaders(header)); err != nil {
 		return
 	}
-	for _, header := range headers {
-		for k, v := range header {
+	h := map[string]string{
+		"X-Upyun-Multi-Disorder":  "true",
+		"X-Upyun-Multi-Stage":     "initiate",
+		"X-Upyun-Multi-Length":    strconv.FormatInt(size, 10),
+		"X-Upyun-Multi-Part-Size": strconv.FormatInt(partSize, 10),
+	}
+	for k, v := range upyunHeader(header) {
+		switch k {
+		case HeaderContentType:
+			h["X-Upyun-Multi-Type"] = v
+		case HeaderContentMD5:
+		default:
 			h[k] = v
 		}
 	}
-	err = u.Client.Put(&upyun.PutObjectConfig{
-		Path:      objectAbs(saveFile),
-		LocalPath: tmpFile,
-		Headers:   h,
-	})
+	var resp *http.Response
+	resp, _, err = u.rest(ctx, http.MethodPut, object, h, nil)
+	if err != nil {
+		return
+	}
+	uploadID = resp.Header.Get("X-Upyun-Multi-Uuid")
+	return
+}
+
+// 又拍云的分片编号从 0 开始
+func (u *UpYun) uploadPart(ctx context.Context, object, uploadID string, number int, reader io.Reader, size int64) (etag string, err error) {
+	var data []byte
+	data, err = ioutil.ReadAll(reader)
+	if err != nil {
+		return
+	}
+	_, _, err = u.rest(ctx, http.MethodPut, object, map[string]string{
+		"X-Upyun-Multi-Stage": "upload",
+		"X-Upyun-Multi-Uuid":  uploadID,
+		"X-Upyun-Part-Id":     strconv.Itoa(number - 1),
+	}, data)
+	return
+}
+
+func (u *UpYun) completeMultipart(ctx context.Context, object, uploadID string, size int64, parts []uploadedPart, header map[string]string) (err error) {
+	if err = checkUploadConditions(ctx, u.GetInfoContext, object, parseHeaders(header)); err != nil {
+		return
+	}
+	_, _, err = u.rest(ctx, http.MethodPut, object, map[string]string{
+		"X-Upyun-Multi-Stage": "complete",
+		"X-Upyun-Multi-Uuid":  uploadID,
+	}, nil)
 	return
 }
 
+// 未完成的分片上传会在又拍云服务端自动过期，不需要取消
+func (u *UpYun) abortMultipart(ctx context.Context, object, uploadID string) (err error) {
+	return
+}
+
+func (u *UpYun) Get(object string) (reader io.ReadCloser, info File, err error) {
+	return u.GetContext(context.Background(), object)
+}
+
+// 又拍云 SDK 只支持把文件内容写入 io.Writer，这里通过 io.Pipe 转为 io.Reader
+func (u *UpYun) GetContext(ctx context.Context, object string) (reader io.ReadCloser, info File, err error) {
+	defer func() { err = upyunError("Get", object, err) }()
+	info, err = u.GetInfoContext(ctx, object)
+	if err != nil {
+		return
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		_, errGet := u.Client.Get(&upyun.GetObjectConfig{
+			Path:   objectAbs(object),
+			Writer: pw,
+		})
+		pw.CloseWithError(errGet)
+	}()
+	reader = &contextReader{ctx: ctx, ReadCloser: pr}
+	return
+}
+
+func (u *UpYun) GetIf(object string, cond Conditions) (reader io.ReadCloser, info File, err error) {
+	return u.GetIfContext(context.Background(), object, cond)
+}
+
+// 又拍云不支持条件请求，在客户端检查
+func (u *UpYun) GetIfContext(ctx context.Context, object string, cond Conditions) (reader io.ReadCloser, info File, err error) {
+	defer func() { err = upyunError("GetIf", object, err) }()
+	return getIf(ctx, u.GetContext, object, cond)
+}
+
 func (u *UpYun) Delete(objects ...string) (err error) {
-	var errs []string
+	return u.DeleteContext(context.Background(), objects...)
+}
+
+func (u *UpYun) DeleteContext(ctx context.Context, objects ...string) (err error) {
+	defer func() { err = upyunError("Delete", strings.Join(objects, ", "), err) }()
+	var errs []error
 	for _, object := range objects {
-		err = u.Client.Delete(&upyun.DeleteObjectConfig{
-			Path: objectAbs(object),
+		path := objectAbs(object)
+		e := doContext(ctx, func() error {
+			return u.Client.Delete(&upyun.DeleteObjectConfig{
+				Path: path,
+			})
 		})
-		if err != nil {
-			errs = append(errs, err.Error())
+		// 与其他云存储一致，删除不存在的文件不算失败
+		if e = upyunError("Delete", object, e); e != nil && !errors.Is(e, ErrNotExist) {
+			errs = append(errs, e)
 		}
 	}
-	if len(errs) > 0 {
-		err = errors.New(strings.Join(errs, "; "))
-	}
+	return deleteError(errs)
+}
+
+func (u *UpYun) Copy(src, dst string) (err error) {
+	return u.CopyContext(context.Background(), src, dst)
+}
+
+// 又拍云 SDK 没有复制和移动的方法，直接调用 REST API
+// http://docs.upyun.com/api/rest_api/#_7
+func (u *UpYun) CopyContext(ctx context.Context, src, dst string) (err error) {
+	defer func() { err = upyunError("Copy", src, err) }()
+	_, _, err = u.rest(ctx, http.MethodPut, dst, map[string]string{
+		"X-Upyun-Copy-Source":        path.Join("/", u.Bucket, objectRel(src)),
+		"X-Upyun-Metadata-Directive": "copy",
+	}, nil)
+	return
+}
+
+func (u *UpYun) Move(src, dst string) (err error) {
+	return u.MoveContext(context.Background(), src, dst)
+}
+
+func (u *UpYun) MoveContext(ctx context.Context, src, dst string) (err error) {
+	defer func() { err = upyunError("Move", src, err) }()
+	_, _, err = u.rest(ctx, http.MethodPut, dst, map[string]string{
+		"X-Upyun-Move-Source":        path.Join("/", u.Bucket, objectRel(src)),
+		"X-Upyun-Metadata-Directive": "copy",
+	}, nil)
 	return
 }
 
-// https://help.upyun.com/knowledge-base/cdn-token-limite/
 func (u *UpYun) GetSignURL(object string, expire int64) (link string, err error) {
+	return u.GetSignURLContext(context.Background(), object, expire)
+}
+
+// https://help.upyun.com/knowledge-base/cdn-token-limite/
+func (u *UpYun) GetSignURLContext(ctx context.Context, object string, expire int64) (link string, err error) {
+	defer func() { err = upyunError("GetSignURL", object, err) }()
+	if err = ctx.Err(); err != nil {
+		return
+	}
 	path := objectAbs(object)
 	if expire <= 0 {
 		return u.Domain + path, nil
@@ -92,47 +308,358 @@ func (u *UpYun) GetSignURL(object string, expire int64) (link string, err error)
 	return u.Domain + path + "?_upt=" + sign, nil
 }
 
+func (u *UpYun) GetSignUploadURL(object string, expire int64, headers ...map[string]string) (upload SignedUpload, err error) {
+	return u.GetSignUploadURLContext(context.Background(), object, expire, headers...)
+}
+
+// 又拍云 REST API 的签名有效期只有 30 分钟，这里返回 FORM API 表单上传所需的 policy 和签名
+// http://docs.upyun.com/api/form_api/
+func (u *UpYun) GetSignUploadURLContext(ctx context.Context, object string, expire int64, headers ...map[string]string) (upload SignedUpload, err error) {
+	defer func() { err = upyunError("GetSignUploadURL", object, err) }()
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	if expire <= 0 {
+		expire = oneHour
+	}
+	options := map[string]interface{}{
+		"bucket":     u.Bucket,
+		"save-key":   objectAbs(object),
+		"expiration": time.Now().Unix() + expire,
+	}
+	for k, v := range upyunHeader(headers...) {
+		options[strings.ToLower(k)] = v
+	}
+	var b []byte
+	b, err = json.Marshal(options)
+	if err != nil {
+		return
+	}
+	policy := base64.StdEncoding.EncodeToString(b)
+	upload = SignedUpload{
+		Method: http.MethodPost,
+		URL:    "http://" + u.endpoint() + "/" + u.Bucket,
+		Header: make(map[string]string),
+		Form: map[string]string{
+			"policy": policy,
+			"authorization": u.Client.MakeUnifiedAuth(&upyun.UnifiedAuthConfig{
+				Method: http.MethodPost,
+				Uri:    "/" + u.Bucket,
+				Policy: policy,
+			}),
+		},
+	}
+	return
+}
+
+func (u *UpYun) PostPolicy(keyPrefix string, maxSize int64, contentTypes []string, expire int64) (form PostForm, err error) {
+	return u.PostPolicyContext(context.Background(), keyPrefix, maxSize, contentTypes, expire)
+}
+
+// 又拍云 FORM API 只能按扩展名限制文件类型，这里将 contentTypes 转换为对应的扩展名，没有对应扩展名的文件类型返回错误
+// http://docs.upyun.com/api/form_api/
+func (u *UpYun) PostPolicyContext(ctx context.Context, keyPrefix string, maxSize int64, contentTypes []string, expire int64) (form PostForm, err error) {
+	defer func() { err = upyunError("PostPolicy", keyPrefix, err) }()
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	if expire <= 0 {
+		expire = oneHour
+	}
+	options := map[string]interface{}{
+		"bucket":     u.Bucket,
+		"save-key":   objectAbs(keyPrefix) + "{filename}{.suffix}",
+		"expiration": time.Now().Unix() + expire,
+	}
+	if keyPrefix == "" || strings.HasSuffix(keyPrefix, "/") {
+		options["save-key"] = path.Join("/", keyPrefix) + "/{filename}{.suffix}"
+	}
+	if maxSize > 0 {
+		options["content-length-range"] = fmt.Sprintf("0,%d", maxSize)
+	}
+	var exts []string
+	for _, contentType := range contentTypes {
+		all, _ := mime.ExtensionsByType(contentType)
+		if len(all) == 0 {
+			err = fmt.Errorf("post policy: no file extension is known for content type %v", contentType)
+			return
+		}
+		for _, ext := range all {
+			exts = append(exts, strings.TrimPrefix(ext, "."))
+		}
+	}
+	if len(exts) > 0 {
+		options["allow-file-type"] = strings.Join(exts, ",")
+	}
+
+	var b []byte
+	b, err = json.Marshal(options)
+	if err != nil {
+		return
+	}
+	policy := base64.StdEncoding.EncodeToString(b)
+	form = PostForm{
+		URL: "http://" + u.endpoint() + "/" + u.Bucket,
+		Fields: map[string]string{
+			"policy": policy,
+			"authorization": u.Client.MakeUnifiedAuth(&upyun.UnifiedAuthConfig{
+				Method: http.MethodPost,
+				Uri:    "/" + u.Bucket,
+				Policy: policy,
+			}),
+		},
+	}
+	return
+}
+
 func (u *UpYun) Lists(prefix string) (files []File, err error) {
-	chans := make(chan *upyun.FileInfo, 1000)
-	prefix = objectRel(prefix)
-	u.Client.List(&upyun.GetObjectsConfig{
-		Path:        prefix,
-		ObjectsChan: chans,
-	})
-	var file File
-	for obj := range chans {
-		file = File{
-			ModTime: obj.Time,
-			Size:    obj.Size,
-			IsDir:   obj.IsDir,
-			Header:  obj.Meta, // 注意：这里获取不到文件的header
-			Name:    filepath.Join(prefix, objectRel(obj.Name)),
+	return u.ListsContext(context.Background(), prefix)
+}
+
+func (u *UpYun) ListsContext(ctx context.Context, prefix string) (files []File, err error) {
+	defer func() { err = upyunError("Lists", prefix, err) }()
+	return listAll(ctx, u, prefix)
+}
+
+func (u *UpYun) ListPage(prefix, marker string, limit int) (files []File, nextMarker string, err error) {
+	return u.ListPageContext(context.Background(), prefix, marker, limit)
+}
+
+// 又拍云的文件存放在真实的文件夹中，只能逐个文件夹列出。marker 中记录了待列出的文件夹
+// 以及当前文件夹的迭代位置，子文件夹会被加入待列出的队列，因此返回的是 prefix 下的全部文件
+func (u *UpYun) ListPageContext(ctx context.Context, prefix, marker string, limit int) (files []File, nextMarker string, err error) {
+	defer func() { err = upyunError("ListPage", prefix, err) }()
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	rel := objectRel(prefix)
+	m := upyunMarker{Dirs: []string{rel}}
+	// prefix 不是文件夹时，从上一级文件夹开始列出，再按字符串前缀过滤
+	if rel != "" && !strings.HasSuffix(rel, "/") {
+		if m.Dirs[0] = path.Dir(rel); m.Dirs[0] == "." {
+			m.Dirs[0] = ""
+		}
+	}
+	if marker != "" {
+		if err = decodeMarker(marker, &m); err != nil {
+			return
 		}
-		files = append(files, file)
+	}
+	for len(m.Dirs) > 0 && len(files) < limit {
+		var (
+			items []File
+			iter  string
+		)
+		dir := m.Dirs[0]
+		items, iter, err = u.listDir(ctx, dir, m.Iter, limit-len(files))
+		if err != nil {
+			return nil, "", err
+		}
+		for _, item := range items {
+			if !strings.HasPrefix(item.Name, rel) {
+				continue
+			}
+			if item.IsDir {
+				m.Dirs = append(m.Dirs, item.Name)
+				continue
+			}
+			files = append(files, item)
+		}
+		if iter == "" || iter == upyunListEOF {
+			m.Dirs, m.Iter = m.Dirs[1:], ""
+		} else {
+			m.Iter = iter
+		}
+	}
+	if len(m.Dirs) > 0 {
+		nextMarker = encodeMarker(m)
 	}
 	return
 }
 
-func (u *UpYun) Download(object string, savePath string) (err error) {
-	_, err = u.Client.Get(&upyun.GetObjectConfig{
-		Path:      objectAbs(object),
-		LocalPath: savePath,
-	})
+func (u *UpYun) ListDir(dir, marker string, limit int) (files []File, nextMarker string, err error) {
+	return u.ListDirContext(context.Background(), dir, marker, limit)
+}
+
+// 又拍云原生支持文件夹，直接列出该文件夹，marker 即为又拍云返回的迭代位置
+func (u *UpYun) ListDirContext(ctx context.Context, dir, marker string, limit int) (files []File, nextMarker string, err error) {
+	defer func() { err = upyunError("ListDir", dir, err) }()
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	files, nextMarker, err = u.listDir(ctx, objectRel(dir), marker, limit)
+	if nextMarker == upyunListEOF {
+		nextMarker = ""
+	}
+	return
+}
+
+// 又拍云列表接口返回的迭代结束标志
+const upyunListEOF = "g2gCZAAEbmV4dGQAA2VvZg"
+
+type upyunMarker struct {
+	Dirs []string `json:"dirs"`
+	Iter string   `json:"iter"`
+}
+
+// 列出一个文件夹下的文件和子文件夹，SDK 的 List 方法不返回迭代位置，这里直接调用 REST API
+// http://docs.upyun.com/api/rest_api/#_13
+func (u *UpYun) listDir(ctx context.Context, dir, iter string, limit int) (files []File, nextIter string, err error) {
+	if limit > 10000 {
+		limit = 10000
+	}
+	headers := map[string]string{
+		"X-List-Limit": strconv.Itoa(limit),
+	}
+	if iter != "" {
+		headers["X-List-Iter"] = iter
+	}
+
+	var (
+		resp *http.Response
+		b    []byte
+	)
+	resp, b, err = u.rest(ctx, http.MethodGet, dir, headers, nil)
+	if err != nil {
+		// 文件夹不存在
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			err = nil
+		}
+		return
+	}
+
+	// 每行一个文件：文件名\t类型(N 文件, F 文件夹)\t大小\t修改时间
+	for _, line := range strings.Split(string(b), "\n") {
+		items := strings.Split(line, "\t")
+		if len(items) != 4 {
+			continue
+		}
+		size, _ := strconv.ParseInt(items[2], 10, 64)
+		modTime, _ := strconv.ParseInt(items[3], 10, 64)
+		files = append(files, File{
+			Name:    path.Join(dir, items[0]),
+			IsDir:   items[1] == "F",
+			Size:    size,
+			ModTime: time.Unix(modTime, 0),
+		})
+	}
+	nextIter = resp.Header.Get("X-Upyun-List-Iter")
 	return
 }
 
+const upyunHost = "v0.api.upyun.com"
+
+// 与 SDK 一致，可以通过 UpYunConfig.Hosts 替换又拍云 API 的地址
+func (u *UpYun) endpoint() string {
+	if host := u.Client.Hosts[upyunHost]; host != "" {
+		return host
+	}
+	return upyunHost
+}
+
+// 直接调用又拍云 REST API，返回状态码不是 2xx 时 err 不为 nil，此时 resp 仍然有效
+func (u *UpYun) rest(ctx context.Context, method, object string, headers map[string]string, data []byte) (resp *http.Response, body []byte, err error) {
+	uri := (&url.URL{Path: path.Join("/", u.Bucket, objectRel(object))}).EscapedPath()
+	date := time.Now().UTC().Format(http.TimeFormat)
+
+	var req *http.Request
+	req, err = http.NewRequestWithContext(ctx, method, "http://"+u.endpoint()+uri, bytes.NewReader(data))
+	if err != nil {
+		return
+	}
+	req.Host = upyunHost
+	req.Header.Set("Date", date)
+	req.Header.Set("Authorization", u.Client.MakeUnifiedAuth(&upyun.UnifiedAuthConfig{
+		Method:  method,
+		Uri:     uri,
+		DateStr: date,
+	}))
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	body, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+	if resp.StatusCode/100 != 2 {
+		err = fmt.Errorf("%s %d %s", method, resp.StatusCode, string(body))
+	}
+	return
+}
+
+func (u *UpYun) Download(object string, savePath string) (err error) {
+	return u.DownloadContext(context.Background(), object, savePath)
+}
+
+func (u *UpYun) DownloadContext(ctx context.Context, object string, savePath string) (err error) {
+	defer func() { err = upyunError("Download", object, err) }()
+	// SDK 的 LocalPath 直接写入 savePath，取消之后仍然会在后台继续写入，这里使用 Get 的数据流
+	var reader io.ReadCloser
+	reader, _, err = u.GetContext(ctx, object)
+	if err != nil {
+		return
+	}
+	defer reader.Close()
+	return saveTo(reader, savePath)
+}
+
 func (u *UpYun) GetInfo(object string) (info File, err error) {
-	var fileInfo *upyun.FileInfo
-	fileInfo, err = u.Client.GetInfo(objectAbs(object))
+	return u.GetInfoContext(context.Background(), object)
+}
+
+func (u *UpYun) GetInfoContext(ctx context.Context, object string) (info File, err error) {
+	defer func() { err = upyunError("GetInfo", object, err) }()
+	// SDK 的 GetInfo 只返回自定义元数据，这里直接发送 HEAD 请求以便取得 Content-Type
+	var resp *http.Response
+	resp, _, err = u.rest(ctx, http.MethodHead, object, nil, nil)
 	if err != nil {
 		return
 	}
 	info = File{
-		ModTime: fileInfo.Time,
-		Name:    objectRel(fileInfo.Name),
-		Size:    fileInfo.Size,
-		IsDir:   fileInfo.IsDir,
-		Header:  fileInfo.Meta,
+		Name:   objectRel(object),
+		IsDir:  resp.Header.Get("X-Upyun-File-Type") == "folder",
+		Header: make(map[string]string),
+	}
+	info.Size, _ = strconv.ParseInt(resp.Header.Get("X-Upyun-File-Size"), 10, 64)
+	modTime, _ := strconv.ParseInt(resp.Header.Get("X-Upyun-File-Date"), 10, 64)
+	info.ModTime = time.Unix(modTime, 0)
+	for k := range resp.Header {
+		if lk := strings.ToLower(k); lk == "content-type" || lk == "etag" || strings.HasPrefix(lk, upyunDialect.metaPrefix) {
+			info.Header[k] = resp.Header.Get(k)
+		}
 	}
+	upyunDialect.setHeader(&info, info.Header)
 	return
 }
+
+func (u *UpYun) GetInfoIf(object string, cond Conditions) (info File, err error) {
+	return u.GetInfoIfContext(context.Background(), object, cond)
+}
+
+// 又拍云不支持条件请求，在客户端检查
+func (u *UpYun) GetInfoIfContext(ctx context.Context, object string, cond Conditions) (info File, err error) {
+	defer func() { err = upyunError("GetInfoIf", object, err) }()
+	return getInfoIf(ctx, u.GetInfoContext, object, cond)
+}
+
+// 又拍云 SDK 返回的错误只有文本，如 "HEAD 404 ..."，这里从中解析出 HTTP 状态码
+var upyunStatusRegexp = regexp.MustCompile(`\b(?:GET|PUT|HEAD|DELETE|POST|PATCH) (\d{3})\b`)
+
+// 将 SDK 返回的错误转换为 StoreError
+func upyunError(op, object string, err error) error {
+	if keepError(err) {
+		return err
+	}
+	var statusCode int
+	if match := upyunStatusRegexp.FindStringSubmatch(err.Error()); len(match) == 2 {
+		statusCode, _ = strconv.Atoi(match[1])
+	}
+	return newStoreError("upyun", op, object, statusCode, "", "", err)
+}
@@ -18,10 +18,17 @@ func init() {
 	password := beego.AppConfig.String("upyun::password")
 	domain := strings.ToLower(beego.AppConfig.String("upyun::domain"))
 	secret := strings.ToLower(beego.AppConfig.String("upyun::secret"))
+	// 没有配置 upyun 时跳过测试
+	if operator == "" {
+		return
+	}
 	Up = NewUpYun(bucket, operator, password, domain, secret)
 }
 
 func TestUpYun(t *testing.T) {
+	if Up == nil {
+		t.Skip("upyun is not configured")
+	}
 	// upload
 	t.Log("=====Upload=====", objectSVG, objectSVGGzip)
 	err = Up.Upload(objectSVG, objectSVG, headerSVG)
@@ -63,6 +70,9 @@ func TestUpYun(t *testing.T) {
 }
 
 func TestUpYun_Delete(t *testing.T) {
+	if Up == nil {
+		t.Skip("upyun is not configured")
+	}
 	if err := Up.Delete(objectSVG, objectSVGGzip); err != nil {
 		t.Error(err)
 	} else {
@@ -3,16 +3,23 @@ package CloudStore
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
 	"crypto/md5"
 	"encoding/hex"
 	"encoding/json"
+	"io"
 	"io/ioutil"
+	"net/http"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 )
 
 var (
 	sevenDays int64 = 7 * 24 * 3600
+	oneHour   int64 = 3600
 )
 
 // 绝对路径，abs => absolute
@@ -60,3 +67,110 @@ func toJSON(v interface{}) (jsonStr string) {
 	}
 	return string(p)
 }
+
+// 部分 SDK 不支持 context，在 ctx 被取消或超时的时候提前返回 ctx.Err()，
+// 注意：此时 fn 中的请求仍会在后台继续执行直到结束
+func doContext(ctx context.Context, fn func() error) (err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- fn()
+	}()
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	return
+}
+
+// doContextReader 用于会读取调用者 reader 的请求：ctx 被取消之后 reader 的读取返回 ctx.Err()，
+// 使请求尽快失败，并且等待 fn 结束之后才返回，之后调用者（例如重试时）可以安全地 Seek 或者重新读取 reader
+func doContextReader(ctx context.Context, reader io.Reader, fn func(reader io.Reader) error) (err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	err = fn(&contextReader{ctx: ctx, ReadCloser: ioutil.NopCloser(reader)})
+	if ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	return
+}
+
+// readerSize 获取常见 reader 剩余内容的大小，无法获取时返回 -1；
+// 经过 doContextReader 包装之后 SDK 无法再根据 reader 的类型获取大小，需要提前获取
+func readerSize(reader io.Reader) int64 {
+	switch v := reader.(type) {
+	case *bytes.Buffer:
+		return int64(v.Len())
+	case *bytes.Reader:
+		return int64(v.Len())
+	case *strings.Reader:
+		return int64(v.Len())
+	case *os.File:
+		info, err := v.Stat()
+		if err != nil || !info.Mode().IsRegular() {
+			return -1
+		}
+		offset, err := v.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return -1
+		}
+		return info.Size() - offset
+	}
+	return -1
+}
+
+// saveTo 把 reader 的内容先写入 savePath 所在目录的临时文件，完成之后再重命名为 savePath；
+// 读取失败或者 ctx 被取消时删除临时文件，savePath 不会出现不完整的内容
+func saveTo(reader io.Reader, savePath string) (err error) {
+	var tmp *os.File
+	tmp, err = ioutil.TempFile(filepath.Dir(savePath), filepath.Base(savePath)+".*.tmp")
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(tmp.Name())
+		}
+	}()
+	_, err = io.Copy(tmp, reader)
+	if err == nil {
+		err = tmp.Chmod(0644)
+	}
+	if errClose := tmp.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		return
+	}
+	return os.Rename(tmp.Name(), savePath)
+}
+
+// 在每次读取之前检查 ctx，用于不支持 context 的 SDK 返回的数据流
+type contextReader struct {
+	ctx context.Context
+	io.ReadCloser
+}
+
+func (r *contextReader) Read(p []byte) (n int, err error) {
+	if err = r.ctx.Err(); err != nil {
+		return
+	}
+	return r.ReadCloser.Read(p)
+}
+
+// 根据 HTTP 响应头生成文件信息，d 为对应云存储的 header 差异
+func fileFromHeader(object string, header http.Header, d headerDialect) (info File) {
+	info = File{Name: object}
+	headerMap := make(map[string]string)
+	for k := range header {
+		headerMap[k] = header.Get(k)
+	}
+	d.setHeader(&info, headerMap)
+	info.Size, _ = strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+	info.ModTime, _ = time.Parse(http.TimeFormat, header.Get("Last-Modified"))
+	return
+}
@@ -1,6 +1,14 @@
 package CloudStore
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
 
 var (
 	objectSVG      = "test_data/test.svg"             //未经过gzip压缩的svg图片
@@ -25,3 +33,73 @@ func TestCompressByGzip(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+// 读取失败时保留原来的文件，也不留下临时文件
+func TestSaveTo(t *testing.T) {
+	dir := t.TempDir()
+	savePath := filepath.Join(dir, "a.txt")
+	if err := saveTo(strings.NewReader("old"), savePath); err != nil {
+		t.Fatalf("saveTo: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	reader := io.MultiReader(strings.NewReader("partial"), &contextReader{ctx: ctx, ReadCloser: ioutil.NopCloser(strings.NewReader("rest"))})
+	if err := saveTo(reader, savePath); !errors.Is(err, context.Canceled) {
+		t.Errorf("saveTo canceled: err = %v, want context.Canceled", err)
+	}
+	if b, err := ioutil.ReadFile(savePath); err != nil || string(b) != "old" {
+		t.Errorf("content = %q, %v, want old", b, err)
+	}
+	if entries, _ := ioutil.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("files = %v, want only a.txt", entries)
+	}
+}
+
+// ctx 被取消之后读取失败，并且等待 fn 结束之后才返回
+func TestDoContextReader(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	returned := false
+	err := doContextReader(ctx, strings.NewReader("content"), func(reader io.Reader) error {
+		defer func() { returned = true }()
+		p := make([]byte, 3)
+		if _, err := reader.Read(p); err != nil {
+			t.Errorf("read before cancel: %v", err)
+		}
+		cancel()
+		if _, err := reader.Read(p); !errors.Is(err, context.Canceled) {
+			t.Errorf("read after cancel: err = %v, want context.Canceled", err)
+		}
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+	if !returned {
+		t.Error("doContextReader returned before fn")
+	}
+}
+
+func TestReaderSize(t *testing.T) {
+	fp, err := ioutil.TempFile(t.TempDir(), "size")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fp.Close()
+	fp.WriteString("content")
+	fp.Seek(3, io.SeekStart)
+
+	tests := []struct {
+		reader io.Reader
+		want   int64
+	}{
+		{strings.NewReader("content"), 7},
+		{fp, 4},
+		{io.LimitReader(strings.NewReader("content"), 3), -1},
+	}
+	for i, tt := range tests {
+		if got := readerSize(tt.reader); got != tt.want {
+			t.Errorf("%d: readerSize = %d, want %d", i, got, tt.want)
+		}
+	}
+}